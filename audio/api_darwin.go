@@ -34,6 +34,10 @@ const (
 	kAudioFormatFlagIsFloat = 1 << 0 // 0x1
 )
 
+const (
+	kAudioQueueProperty_CurrentDevice = 0x61716364 // 'aqcd'
+)
+
 type _AudioStreamBasicDescription struct {
 	mSampleRate       float64
 	mFormatID         uint32
@@ -81,6 +85,8 @@ func initializeAPI() error {
 	purego.RegisterLibFunc(&_AudioQueueEnqueueBuffer, toolbox, "AudioQueueEnqueueBuffer")
 	purego.RegisterLibFunc(&_AudioQueueStart, toolbox, "AudioQueueStart")
 	purego.RegisterLibFunc(&_AudioQueuePause, toolbox, "AudioQueuePause")
+	purego.RegisterLibFunc(&_AudioQueueSetProperty, toolbox, "AudioQueueSetProperty")
+	purego.RegisterLibFunc(&_AudioQueueDispose, toolbox, "AudioQueueDispose")
 	return nil
 }
 
@@ -93,3 +99,7 @@ var _AudioQueueEnqueueBuffer func(inAQ _AudioQueueRef, inBuffer _AudioQueueBuffe
 var _AudioQueueStart func(inAQ _AudioQueueRef, inStartTime *_AudioTimeStamp) uintptr
 
 var _AudioQueuePause func(inAQ _AudioQueueRef) uintptr
+
+var _AudioQueueSetProperty func(inAQ _AudioQueueRef, inID uint32, inData unsafe.Pointer, inDataSize uint32) uintptr
+
+var _AudioQueueDispose func(inAQ _AudioQueueRef, inImmediate bool) uintptr
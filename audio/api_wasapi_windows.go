@@ -29,6 +29,7 @@ var (
 
 var (
 	procCoCreateInstance = ole32.NewProc("CoCreateInstance")
+	procPropVariantClear = ole32.NewProc("PropVariantClear")
 )
 
 type _REFERENCE_TIME int64
@@ -46,10 +47,13 @@ const (
 	_AUDCLNT_STREAMFLAGS_NOPERSIST      = 0x00080000
 	_COINIT_APARTMENTTHREADED           = 0x2
 	_COINIT_MULTITHREADED               = 0
+	_DEVICE_STATE_ACTIVE                = 0x1
 	_REFTIMES_PER_SEC                   = 10000000
 	_SPEAKER_FRONT_CENTER               = 0x4
 	_SPEAKER_FRONT_LEFT                 = 0x1
 	_SPEAKER_FRONT_RIGHT                = 0x2
+	_STGM_READ                          = 0x0
+	_VT_LPWSTR                          = 31
 	_WAVE_FORMAT_EXTENSIBLE             = 0xfffe
 )
 
@@ -58,6 +62,10 @@ var (
 	_KSDATAFORMAT_SUBTYPE_PCM        = windows.GUID{0x00000001, 0x0000, 0x0010, [...]byte{0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71}}
 )
 
+var (
+	_PKEY_Device_FriendlyName = _PROPERTYKEY{windows.GUID{0xa45c254e, 0xdf1c, 0x4efd, [...]byte{0x80, 0x20, 0x67, 0xd1, 0x46, 0xa8, 0x50, 0xe0}}, 14}
+)
+
 type _AUDCLNT_ERR uint32
 
 const (
@@ -162,8 +170,29 @@ type _AudioClientProperties struct {
 	Options    _AUDCLNT_STREAMOPTIONS
 }
 
+type _PROPERTYKEY struct {
+	fmtid windows.GUID
+	pid   uint32
+}
+
 type _PROPVARIANT struct {
-	// TODO: Implmeent this
+	vt         uint16
+	wReserved1 uint16
+	wReserved2 uint16
+	wReserved3 uint16
+	val        [2]uintptr // union
+}
+
+func (p *_PROPVARIANT) String() string {
+	if p.vt != _VT_LPWSTR {
+		return ""
+	}
+	return windows.UTF16PtrToString(*(**uint16)(unsafe.Pointer(&p.val[0])))
+}
+
+func _PropVariantClear(pvar *_PROPVARIANT) {
+	procPropVariantClear.Call(uintptr(unsafe.Pointer(pvar)))
+	runtime.KeepAlive(pvar)
 }
 
 type _WAVEFORMATEXTENSIBLE struct {
@@ -254,6 +283,18 @@ func (i *_IAudioClient2) GetDevicePeriod() (_REFERENCE_TIME, _REFERENCE_TIME, er
 	return defaultDevicePeriod, minimumDevicePeriod, nil
 }
 
+func (i *_IAudioClient2) GetStreamLatency() (_REFERENCE_TIME, error) {
+	var latency _REFERENCE_TIME
+	r, _, _ := syscall.Syscall(i.vtbl.GetStreamLatency, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&latency)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		if isAudclntErr(uint32(r)) {
+			return 0, fmt.Errorf("oto: IAudioClient2::GetStreamLatency failed: %w", _AUDCLNT_ERR(r))
+		}
+		return 0, fmt.Errorf("oto: IAudioClient2::GetStreamLatency failed: HRESULT(%d)", uint32(r))
+	}
+	return latency, nil
+}
+
 func (i *_IAudioClient2) GetService(riid *windows.GUID) (unsafe.Pointer, error) {
 	var v unsafe.Pointer
 	r, _, _ := syscall.Syscall(i.vtbl.GetService, 3, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(riid)), uintptr(unsafe.Pointer(&v)))
@@ -441,10 +482,63 @@ func (i *_IMMDevice) GetId() (string, error) {
 	return windows.UTF16PtrToString(strId), nil
 }
 
+func (i *_IMMDevice) GetState() (uint32, error) {
+	var state uint32
+	r, _, _ := syscall.Syscall(i.vtbl.GetState, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&state)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return 0, fmt.Errorf("oto: IMMDevice::GetState failed: HRESULT(%d)", uint32(r))
+	}
+	return state, nil
+}
+
+func (i *_IMMDevice) OpenPropertyStore(stgmAccess uint32) (*_IPropertyStore, error) {
+	var store *_IPropertyStore
+	r, _, _ := syscall.Syscall(i.vtbl.OpenPropertyStore, 3, uintptr(unsafe.Pointer(i)), uintptr(stgmAccess), uintptr(unsafe.Pointer(&store)))
+	if uint32(r) != uint32(windows.S_OK) {
+		return nil, fmt.Errorf("oto: IMMDevice::OpenPropertyStore failed: HRESULT(%d)", uint32(r))
+	}
+	return store, nil
+}
+
 func (i *_IMMDevice) Release() {
 	syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
 }
 
+type _IMMDeviceCollection struct {
+	vtbl *_IMMDeviceCollection_Vtbl
+}
+
+type _IMMDeviceCollection_Vtbl struct {
+	QueryInterface uintptr
+	AddRef         uintptr
+	Release        uintptr
+
+	GetCount uintptr
+	Item     uintptr
+}
+
+func (i *_IMMDeviceCollection) GetCount() (uint32, error) {
+	var count uint32
+	r, _, _ := syscall.Syscall(i.vtbl.GetCount, 2, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(&count)), 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return 0, fmt.Errorf("oto: IMMDeviceCollection::GetCount failed: HRESULT(%d)", uint32(r))
+	}
+	return count, nil
+}
+
+func (i *_IMMDeviceCollection) Item(nDevice uint32) (*_IMMDevice, error) {
+	var device *_IMMDevice
+	r, _, _ := syscall.Syscall(i.vtbl.Item, 3, uintptr(unsafe.Pointer(i)), uintptr(nDevice), uintptr(unsafe.Pointer(&device)))
+	if uint32(r) != uint32(windows.S_OK) {
+		return nil, fmt.Errorf("oto: IMMDeviceCollection::Item failed: HRESULT(%d)", uint32(r))
+	}
+	return device, nil
+}
+
+func (i *_IMMDeviceCollection) Release() {
+	syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+}
+
 type _IMMDeviceEnumerator struct {
 	vtbl *_IMMDeviceEnumerator_Vtbl
 }
@@ -474,6 +568,63 @@ func (i *_IMMDeviceEnumerator) GetDefaultAudioEndPoint(dataFlow _EDataFlow, role
 	return endPoint, nil
 }
 
+func (i *_IMMDeviceEnumerator) EnumAudioEndpoints(dataFlow _EDataFlow, dwStateMask uint32) (*_IMMDeviceCollection, error) {
+	var devices *_IMMDeviceCollection
+	r, _, _ := syscall.Syscall6(i.vtbl.EnumAudioEndpoints, 4, uintptr(unsafe.Pointer(i)),
+		uintptr(dataFlow), uintptr(dwStateMask), uintptr(unsafe.Pointer(&devices)), 0, 0)
+	if uint32(r) != uint32(windows.S_OK) {
+		return nil, fmt.Errorf("oto: IMMDeviceEnumerator::EnumAudioEndpoints failed: HRESULT(%d)", uint32(r))
+	}
+	return devices, nil
+}
+
+func (i *_IMMDeviceEnumerator) GetDevice(id string) (*_IMMDevice, error) {
+	pwstrId, err := windows.UTF16PtrFromString(id)
+	if err != nil {
+		return nil, err
+	}
+	var device *_IMMDevice
+	r, _, _ := syscall.Syscall(i.vtbl.GetDevice, 3, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(pwstrId)), uintptr(unsafe.Pointer(&device)))
+	runtime.KeepAlive(pwstrId)
+	if uint32(r) != uint32(windows.S_OK) {
+		if isWin32Err(uint32(r)) {
+			return nil, fmt.Errorf("oto: IMMDeviceEnumerator::GetDevice failed: %w", _E_NOTFOUND)
+		}
+		return nil, fmt.Errorf("oto: IMMDeviceEnumerator::GetDevice failed: HRESULT(%d)", uint32(r))
+	}
+	return device, nil
+}
+
 func (i *_IMMDeviceEnumerator) Release() {
 	syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
 }
+
+type _IPropertyStore struct {
+	vtbl *_IPropertyStore_Vtbl
+}
+
+type _IPropertyStore_Vtbl struct {
+	QueryInterface uintptr
+	AddRef         uintptr
+	Release        uintptr
+
+	GetCount uintptr
+	GetAt    uintptr
+	GetValue uintptr
+	SetValue uintptr
+	Commit   uintptr
+}
+
+func (i *_IPropertyStore) GetValue(key *_PROPERTYKEY) (*_PROPVARIANT, error) {
+	var v _PROPVARIANT
+	r, _, _ := syscall.Syscall(i.vtbl.GetValue, 3, uintptr(unsafe.Pointer(i)), uintptr(unsafe.Pointer(key)), uintptr(unsafe.Pointer(&v)))
+	runtime.KeepAlive(key)
+	if uint32(r) != uint32(windows.S_OK) {
+		return nil, fmt.Errorf("oto: IPropertyStore::GetValue failed: HRESULT(%d)", uint32(r))
+	}
+	return &v, nil
+}
+
+func (i *_IPropertyStore) Release() {
+	syscall.Syscall(i.vtbl.Release, 1, uintptr(unsafe.Pointer(i)), 0, 0)
+}
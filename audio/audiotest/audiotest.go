@@ -0,0 +1,77 @@
+// Package audiotest helps testing code that plays audio, without an audio device.
+package audiotest
+
+import (
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio"
+)
+
+// Driver is an audio.Driver that plays nothing. Tests pull the mix themselves with Read.
+type Driver struct {
+	// Options are what the context opened the driver with.
+	Options audio.DriverOptions
+
+	Suspended bool
+	Closed    bool
+
+	// Latency and Info are reported as the output of the driver, see audio.OutputReporter.
+	Latency time.Duration
+	Info    audio.OutputInfo
+}
+
+func (d *Driver) Open(options audio.DriverOptions) (chan struct{}, error) {
+	d.Options = options
+	ready := make(chan struct{})
+	close(ready)
+	return ready, nil
+}
+
+func (d *Driver) Suspend() error {
+	d.Suspended = true
+	return nil
+}
+
+func (d *Driver) Resume() error {
+	d.Suspended = false
+	return nil
+}
+
+func (d *Driver) Close() error {
+	d.Closed = true
+	return nil
+}
+
+func (d *Driver) Err() error {
+	return nil
+}
+
+func (d *Driver) OutputLatency() time.Duration {
+	return d.Latency
+}
+
+func (d *Driver) OutputInfo() audio.OutputInfo {
+	return d.Info
+}
+
+// Read pulls the next frames of the mix into buf, like a device would.
+func (d *Driver) Read(buf []float32) {
+	d.Options.ReadFloat32s(buf)
+}
+
+// InitContext closes the current context, if any, and creates one that plays to a new Driver.
+// options.Driver is ignored. It waits until the context is ready.
+func InitContext(options *audio.NewContextOptions) (*Driver, error) {
+	if err := audio.CloseContext(); err != nil {
+		return nil, err
+	}
+	o := *options
+	d := &Driver{}
+	o.Driver = d
+	ready, err := audio.InitContext(&o)
+	if err != nil {
+		return nil, err
+	}
+	<-ready
+	return d, nil
+}
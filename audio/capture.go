@@ -0,0 +1,218 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errCaptureClosed = errors.New("audio: capture was closed")
+
+// CaptureOptions represents options for OpenCapture.
+type CaptureOptions struct {
+	// SampleRate specifies the number of frames to capture per second.
+	// If 0 is specified, the sample rate of the context is used, or 48000 if there is no context.
+	SampleRate int
+
+	// ChannelCount is either 1 (mono) or 2 (stereo). If 0 is specified, mono is used.
+	ChannelCount int
+
+	// BufferSize specifies how much captured audio is kept until it is read.
+	// If Read isn't called often enough, newer frames are dropped.
+	// If 0 is specified, one second is used.
+	BufferSize time.Duration
+
+	// Source replaces the input device with a synthetic one, which is useful for headless tests.
+	// It is called from a background goroutine at the pace of the sample rate,
+	// and should fill buf with the next interleaved frames.
+	// See CaptureSamples for a Source that plays back already loaded audio.
+	Source func(buf []float32)
+}
+
+// Capture records audio from an input device such as a microphone.
+//
+// All the functions of a Capture are concurrent-safe, but only one goroutine should Read from it.
+type Capture struct {
+	sampleRate   int
+	channelCount int
+
+	ring    *ringBuffer
+	device  captureDevice
+	dropped atomic.Int64
+	err     atomicError
+
+	m      sync.Mutex
+	closed bool
+}
+
+// captureDevice is implemented by the input backends.
+type captureDevice interface {
+	close() error
+}
+
+// OpenCapture starts capturing audio from the default input device, or from options.Source if set.
+// The captured frames are read with Capture.Read. A nil options uses the defaults of CaptureOptions.
+func OpenCapture(options *CaptureOptions) (*Capture, error) {
+	if options == nil {
+		options = &CaptureOptions{}
+	}
+	c := &Capture{
+		sampleRate:   options.SampleRate,
+		channelCount: options.ChannelCount,
+	}
+	if c.sampleRate == 0 {
+		if mux != nil {
+			c.sampleRate = mux.sampleRate
+		} else {
+			c.sampleRate = 48000
+		}
+	}
+	if c.channelCount == 0 {
+		c.channelCount = 1
+	}
+	if c.channelCount != 1 && c.channelCount != 2 {
+		return nil, fmt.Errorf("audio: capture channel count must be 1 or 2 but was %d", c.channelCount)
+	}
+	bufferSize := options.BufferSize
+	if bufferSize == 0 {
+		bufferSize = time.Second
+	}
+	frames := max(int(bufferSize.Seconds()*float64(c.sampleRate)), 1)
+	c.ring = newRingBuffer(frames * c.channelCount)
+
+	var err error
+	if options.Source != nil {
+		c.device = newSyntheticCapture(c, options.Source)
+	} else {
+		c.device, err = openCaptureDevice(c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SampleRate returns the number of frames captured per second.
+func (c *Capture) SampleRate() int {
+	return c.sampleRate
+}
+
+// ChannelCount returns the number of interleaved channels per frame.
+func (c *Capture) ChannelCount() int {
+	return c.channelCount
+}
+
+// Read fills buf with the oldest captured samples and returns the number of samples read.
+// It never blocks; if nothing has been captured since the last call, 0 is returned.
+//
+//	[buf]       = [frame 1] [frame 2] [frame 3] ...
+//	[frame *]   = [channel 1] [channel 2] ...
+func (c *Capture) Read(buf []float32) int {
+	buf = buf[:len(buf)-len(buf)%c.channelCount]
+	return c.ring.read(buf)
+}
+
+// Available returns the number of samples that can be read without waiting.
+func (c *Capture) Available() int {
+	return c.ring.available()
+}
+
+// Dropped returns the number of frames that were dropped because the buffer was full.
+func (c *Capture) Dropped() int {
+	return int(c.dropped.Load())
+}
+
+// Err returns the error that stopped the capture, if any.
+func (c *Capture) Err() error {
+	return c.err.Load()
+}
+
+// Close stops capturing. Samples that were already captured can still be read.
+func (c *Capture) Close() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.err.TryStore(errCaptureClosed)
+	return c.device.close()
+}
+
+// push is called by the backends with newly captured samples.
+func (c *Capture) push(buf []float32) {
+	n := c.ring.write(buf)
+	if n < len(buf) {
+		c.dropped.Add(int64((len(buf) - n) / c.channelCount))
+	}
+}
+
+type syntheticCapture struct {
+	done     chan struct{}
+	finished chan struct{}
+}
+
+func newSyntheticCapture(c *Capture, source func(buf []float32)) *syntheticCapture {
+	s := &syntheticCapture{
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.finished)
+		const interval = 10 * time.Millisecond
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var buf []float32
+		start := time.Now()
+		var captured int64
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+			due := int64(time.Since(start).Seconds() * float64(c.sampleRate))
+			n := int(due-captured) * c.channelCount
+			if n <= 0 {
+				continue
+			}
+			if cap(buf) < n {
+				buf = make([]float32, n)
+			}
+			buf = buf[:n]
+			clear(buf)
+			source(buf)
+			c.push(buf)
+			captured = due
+		}
+	}()
+	return s
+}
+
+func (s *syntheticCapture) close() error {
+	close(s.done)
+	<-s.finished
+	return nil
+}
+
+// CaptureSamples returns a CaptureOptions.Source that delivers already loaded audio,
+// e.g. a WAV file of someone talking, followed by silence unless loop is set.
+// The samples must have the same layout as the capture.
+func CaptureSamples(data []float32, loop bool) func(buf []float32) {
+	pos := 0
+	return func(buf []float32) {
+		for i := range buf {
+			if pos >= len(data) {
+				if !loop || len(data) == 0 {
+					return
+				}
+				pos = 0
+			}
+			buf[i] = data[pos]
+			pos++
+		}
+	}
+}
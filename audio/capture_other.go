@@ -0,0 +1,9 @@
+//go:build android || darwin || js || windows || nintendosdk || playstation5
+
+package audio
+
+import "errors"
+
+func openCaptureDevice(c *Capture) (captureDevice, error) {
+	return nil, errors.New("audio: capturing from an input device is not supported on this platform yet, use CaptureOptions.Source")
+}
@@ -0,0 +1,87 @@
+package audio_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio"
+)
+
+func TestCaptureSynthetic(t *testing.T) {
+	data := make([]float32, 2*4800)
+	for i := range data {
+		data[i] = float32(i)
+	}
+	c, err := audio.OpenCapture(&audio.CaptureOptions{
+		SampleRate:   48000,
+		ChannelCount: 2,
+		Source:       audio.CaptureSamples(data, false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var captured []float32
+	buf := make([]float32, 1001)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(captured) < len(data) && time.Now().Before(deadline) {
+		n := c.Read(buf)
+		if n%2 != 0 {
+			t.Fatalf("read %d samples, which is not a whole number of frames", n)
+		}
+		captured = append(captured, buf[:n]...)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(captured) < len(data) {
+		t.Fatalf("captured only %d of %d samples", len(captured), len(data))
+	}
+	for i, v := range data {
+		if captured[i] != v {
+			t.Fatalf("sample %d should be %f but was %f", i, v, captured[i])
+		}
+	}
+	if c.Dropped() != 0 {
+		t.Errorf("no frames should have been dropped, but %d were", c.Dropped())
+	}
+}
+
+func TestCaptureDropsWhenFull(t *testing.T) {
+	c, err := audio.OpenCapture(&audio.CaptureOptions{
+		SampleRate: 48000,
+		BufferSize: 10 * time.Millisecond,
+		Source:     func(buf []float32) {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	_ = c.Close()
+	if c.Available() != 480 {
+		t.Errorf("the buffer should be full with 480 samples but had %d", c.Available())
+	}
+	if c.Dropped() == 0 {
+		t.Errorf("frames should have been dropped")
+	}
+}
+
+func TestCaptureInvalidChannelCount(t *testing.T) {
+	_, err := audio.OpenCapture(&audio.CaptureOptions{ChannelCount: 3, Source: func(buf []float32) {}})
+	if err == nil {
+		t.Fatalf("3 channels should not be supported")
+	}
+}
+
+func TestCaptureDefaultOptions(t *testing.T) {
+	// there may be no input device, but nil options must not panic
+	c, err := audio.OpenCapture(nil)
+	if err != nil {
+		t.Skipf("no input device: %v", err)
+	}
+	defer c.Close()
+	if c.SampleRate() != audio.SampleRate() || c.ChannelCount() != 1 {
+		t.Errorf("should capture mono at %d Hz but captures %d channels at %d Hz", audio.SampleRate(), c.ChannelCount(), c.SampleRate())
+	}
+}
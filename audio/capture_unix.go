@@ -0,0 +1,109 @@
+//go:build !android && !darwin && !js && !windows && !nintendosdk && !playstation5
+
+package audio
+
+// #include <alsa/asoundlib.h>
+import "C"
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/Lundis/go-gameaudio/loaders/resample"
+)
+
+type alsaCapture struct {
+	handle   *C.snd_pcm_t
+	closing  atomic.Bool
+	finished chan struct{}
+}
+
+func openCaptureDevice(c *Capture) (captureDevice, error) {
+	a := &alsaCapture{
+		finished: make(chan struct{}),
+	}
+
+	cname := C.CString("default")
+	defer C.free(unsafe.Pointer(cname))
+	if err := C.snd_pcm_open(&a.handle, cname, C.SND_PCM_STREAM_CAPTURE, 0); err < 0 {
+		return nil, alsaError("snd_pcm_open", err)
+	}
+
+	const periods = 2
+	periodSize := C.snd_pcm_uframes_t(c.sampleRate / 100)
+	bufferSize := periodSize * periods
+	sampleRate := C.unsigned(c.sampleRate)
+	if err := alsaPcmHwParams(a.handle, &sampleRate, c.channelCount, true, &bufferSize, &periodSize); err != nil {
+		C.snd_pcm_close(a.handle)
+		return nil, err
+	}
+
+	deviceRate := int(sampleRate)
+	go func() {
+		defer close(a.finished)
+		if deviceRate != c.sampleRate {
+			a.captureAt(c, deviceRate, int(periodSize))
+			return
+		}
+		buf32 := make([]float32, int(periodSize)*c.channelCount)
+		for !a.closing.Load() {
+			n, err := a.read(buf32, c.channelCount)
+			if err != nil {
+				c.err.TryStore(err)
+				return
+			}
+			c.push(buf32[:n])
+		}
+	}()
+	return a, nil
+}
+
+// captureAt captures from a device that runs at deviceRate even with ALSA's conversion, and converts the frames
+// to the rate of the Capture, like playback does with DriverOptions.ReadFloat32sAt.
+func (a *alsaCapture) captureAt(c *Capture, deviceRate, periodSize int) {
+	s := resample.NewStream(deviceRate, c.sampleRate, c.channelCount)
+	buf32 := make([]float32, max(periodSize*c.sampleRate/deviceRate, 1)*c.channelCount)
+	var err error
+	for !a.closing.Load() {
+		s.Read(buf32, func(src []float32) {
+			for len(src) > 0 {
+				if err != nil || a.closing.Load() {
+					clear(src)
+					return
+				}
+				var n int
+				n, err = a.read(src, c.channelCount)
+				src = src[n:]
+			}
+		})
+		if err != nil {
+			c.err.TryStore(err)
+			return
+		}
+		if !a.closing.Load() {
+			c.push(buf32)
+		}
+	}
+}
+
+// read reads captured frames into buf and returns the number of samples read.
+// It recovers from overruns, after which it returns 0.
+func (a *alsaCapture) read(buf []float32, channelCount int) (int, error) {
+	n := C.snd_pcm_readi(a.handle, unsafe.Pointer(&buf[0]), C.snd_pcm_uframes_t(len(buf)/channelCount))
+	if n < 0 {
+		if err := C.snd_pcm_recover(a.handle, C.int(n), 1); err < 0 {
+			return 0, alsaError("snd_pcm_readi or snd_pcm_recover", err)
+		}
+		return 0, nil
+	}
+	return int(n) * channelCount, nil
+}
+
+func (a *alsaCapture) close() error {
+	a.closing.Store(true)
+	<-a.finished
+	if err := C.snd_pcm_close(a.handle); err < 0 {
+		return alsaError("snd_pcm_close", err)
+	}
+	return nil
+}
@@ -20,36 +20,63 @@ type channelSettings struct {
 	paused bool
 }
 
+// SetVolume sets the volume of the channel in the context's mixer.
 func (cid ChannelId) SetVolume(volume float32) {
-	settings := getChannelSettings(cid)
-	settings.volume = volume
-	setChannelSettings(cid, settings)
+	defaultChannels.setVolume(cid, volume)
 }
 
+// Volume returns the volume of the channel in the context's mixer.
 func (cid ChannelId) Volume() float32 {
-	settings := getChannelSettings(cid)
-	return settings.volume
+	return defaultChannels.get(cid).volume
 }
 
+// Pause pauses the channel in the context's mixer.
 func (cid ChannelId) Pause() {
-	settings := getChannelSettings(cid)
-	settings.paused = true
-	setChannelSettings(cid, settings)
+	defaultChannels.setPaused(cid, true)
 }
 
+// Resume continues the channel in the context's mixer after Pause.
 func (cid ChannelId) Resume() {
-	settings := getChannelSettings(cid)
-	settings.paused = false
-	setChannelSettings(cid, settings)
+	defaultChannels.setPaused(cid, false)
 }
 
-var channelSettingsMap = make(map[ChannelId]channelSettings)
-var settingsLock sync.RWMutex
+// SetChannelVolume sets the volume of a channel of this mixer.
+func (m *Mixer) SetChannelVolume(cid ChannelId, volume float32) {
+	m.channels.setVolume(cid, volume)
+}
+
+// ChannelVolume returns the volume of a channel of this mixer.
+func (m *Mixer) ChannelVolume(cid ChannelId) float32 {
+	return m.channels.get(cid).volume
+}
+
+// PauseChannel pauses a channel of this mixer.
+func (m *Mixer) PauseChannel(cid ChannelId) {
+	m.channels.setPaused(cid, true)
+}
+
+// ResumeChannel continues a channel of this mixer after PauseChannel.
+func (m *Mixer) ResumeChannel(cid ChannelId) {
+	m.channels.setPaused(cid, false)
+}
+
+// channelTable holds the channel settings of a mixer.
+type channelTable struct {
+	m        sync.RWMutex
+	settings map[ChannelId]channelSettings
+}
+
+// defaultChannels belongs to the context's mixer. It exists before InitContext, so channels can be set up early.
+var defaultChannels = channelTable{settings: make(map[ChannelId]channelSettings)}
+
+func newChannelTable() *channelTable {
+	return &channelTable{settings: make(map[ChannelId]channelSettings)}
+}
 
-func getChannelSettings(id ChannelId) channelSettings {
-	settingsLock.RLock()
-	s, ok := channelSettingsMap[id]
-	settingsLock.RUnlock()
+func (t *channelTable) get(id ChannelId) channelSettings {
+	t.m.RLock()
+	s, ok := t.settings[id]
+	t.m.RUnlock()
 	if ok {
 		return s
 	}
@@ -57,8 +84,22 @@ func getChannelSettings(id ChannelId) channelSettings {
 		volume: 1,
 	}
 }
-func setChannelSettings(id ChannelId, s channelSettings) {
-	settingsLock.Lock()
-	channelSettingsMap[id] = s
-	settingsLock.Unlock()
+
+func (t *channelTable) setVolume(id ChannelId, volume float32) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	s := t.settings[id]
+	s.volume = volume
+	t.settings[id] = s
+}
+
+func (t *channelTable) setPaused(id ChannelId, paused bool) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	s, ok := t.settings[id]
+	if !ok {
+		s.volume = 1
+	}
+	s.paused = paused
+	t.settings[id] = s
 }
@@ -16,6 +16,7 @@
 package audio
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -23,6 +24,8 @@ import (
 
 var (
 	contextCreationMutex sync.Mutex
+	driver               Driver
+	ahead                *mixAhead
 )
 
 const ChannelCount = 2
@@ -41,18 +44,45 @@ type NewContextOptions struct {
 	// Too big buffer size can increase the latency time.
 	// On the other hand, too small buffer size can cause glitch noises due to buffer shortage.
 	BufferSize time.Duration
+
+	// ApplicationName is shown in the system mixer where the audio driver supports it, e.g. PulseAudio and PipeWire.
+	// If empty, the name of the executable is used.
+	ApplicationName string
+
+	// Device is the ID of the output device to play to, as returned by Devices.
+	// If empty, or if the device isn't available, the default device is used.
+	Device string
+
+	// OnDeviceStateChange is called from a background goroutine when the output device is lost or reopened.
+	// The context keeps trying to reopen a lost device, so games only need this to tell the player.
+	OnDeviceStateChange func(state DeviceState, err error)
+
+	// OnSourceError is called from a background goroutine when a DynamicSound fill function or an OnEndCallback
+	// panics, or when a DynamicSound produces NaN, infinite or runaway samples.
+	// The offending source is muted and removed from the mix, and the rest keeps playing.
+	OnSourceError func(err *SourceError)
+
+	// Driver plays the mix. If nil, the system's audio device is used.
+	Driver Driver
+
+	// MixAhead makes a dedicated goroutine mix up to this much audio before the driver needs it.
+	// A GC pause or a slow DynamicSound then doesn't become a glitch, at the cost of this much extra latency.
+	// If the driver reads more at once than was mixed ahead, the rest is mixed while it waits.
+	//
+	// If 0, the driver mixes synchronously whenever it needs more audio.
+	MixAhead time.Duration
 }
 
 // InitContext creates a new context with given options.
 // A context creates and holds ready-to-use Sound objects.
 // InitContext returns a channel that is closed when the context is ready, and an error if it exists.
 //
-// Creating multiple contexts is NOT supported.
+// Creating multiple contexts is NOT supported, but a new one can be created after CloseContext.
 func InitContext(options *NewContextOptions) (chan struct{}, error) {
 	contextCreationMutex.Lock()
 	defer contextCreationMutex.Unlock()
 
-	if mux != nil {
+	if driver != nil {
 		return nil, fmt.Errorf("context was already created")
 	}
 
@@ -64,14 +94,95 @@ func InitContext(options *NewContextOptions) (chan struct{}, error) {
 		bufferSizeInBytes = int(int64(options.BufferSize) * int64(bytesPerSecond) / int64(time.Second))
 		bufferSizeInBytes = bufferSizeInBytes / bytesPerSample * bytesPerSample
 	}
-	initMux(options.SampleRate, ChannelCount)
-	ready, err := newContext(bufferSizeInBytes)
+	initMux(options.SampleRate, ChannelCount, options.OnSourceError)
+	ResetStats()
+	deviceState.callback = options.OnDeviceStateChange
+
+	d := options.Driver
+	if d == nil {
+		d = newDefaultDriver()
+	}
+	read := mux.ReadFloat32s
+	if options.MixAhead > 0 {
+		ahead = newMixAhead(read, options.SampleRate, ChannelCount, options.MixAhead, bufferSizeInBytes/(ChannelCount*4))
+		read = ahead.ReadFloat32s
+	}
+	ready, err := d.Open(DriverOptions{
+		SampleRate:        options.SampleRate,
+		ChannelCount:      ChannelCount,
+		BufferSizeInBytes: bufferSizeInBytes,
+		ApplicationName:   options.ApplicationName,
+		Device:            options.Device,
+		ReadFloat32s: func(buf []float32) {
+			recordCallback(len(buf)/ChannelCount, options.SampleRate)
+			read(buf)
+		},
+		ReportXrun: recordXrun,
+		mix:        read,
+	})
 	if err != nil {
+		if ahead != nil {
+			ahead.close()
+			ahead = nil
+		}
 		return nil, err
 	}
+	driver = d
 	return ready, nil
 }
 
+// errNoContext is returned by the functions that control the output when there is no context.
+var errNoContext = errors.New("audio: there is no context, see InitContext")
+
+// Suspend pauses the output, e.g. when the game is minimized.
+func Suspend() error {
+	contextCreationMutex.Lock()
+	defer contextCreationMutex.Unlock()
+	if driver == nil {
+		return errNoContext
+	}
+	return driver.Suspend()
+}
+
+// Resume continues the output after Suspend.
+func Resume() error {
+	contextCreationMutex.Lock()
+	defer contextCreationMutex.Unlock()
+	if driver == nil {
+		return errNoContext
+	}
+	return driver.Resume()
+}
+
+// Err returns the error that stopped the output, if any. Without a context there is no output, which is an error too.
+func Err() error {
+	contextCreationMutex.Lock()
+	defer contextCreationMutex.Unlock()
+	if driver == nil {
+		return errNoContext
+	}
+	return driver.Err()
+}
+
+// CloseContext stops the output. Sounds and channels are kept for the next InitContext.
+func CloseContext() error {
+	contextCreationMutex.Lock()
+	defer contextCreationMutex.Unlock()
+
+	if driver == nil {
+		return nil
+	}
+	err := driver.Close()
+	driver = nil
+	if ahead != nil {
+		ahead.close()
+		ahead = nil
+	}
+	return err
+}
+
+// SampleRate returns the rate that the mix runs at, which is the rate to load assets at.
+// The device can run at another rate, see CurrentOutput; the mix is then converted on its way out.
 func SampleRate() int {
 	return mux.sampleRate
 }
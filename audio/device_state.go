@@ -0,0 +1,81 @@
+package audio
+
+import (
+	"sync"
+	"time"
+)
+
+// DeviceState tells whether audio currently reaches an output device.
+type DeviceState int
+
+const (
+	// DeviceStateOpen means that the output device is open and playing.
+	DeviceStateOpen DeviceState = iota
+
+	// DeviceStateLost means that the output device failed, e.g. because it was unplugged, or couldn't be opened at all.
+	// The device is reopened in the background, and the playing sounds continue on it once it is back.
+	DeviceStateLost
+)
+
+func (s DeviceState) String() string {
+	switch s {
+	case DeviceStateOpen:
+		return "open"
+	case DeviceStateLost:
+		return "lost"
+	}
+	return "unknown"
+}
+
+const (
+	minReopenDelay = 100 * time.Millisecond
+	maxReopenDelay = 5 * time.Second
+)
+
+var deviceState struct {
+	m        sync.Mutex
+	state    DeviceState
+	err      error
+	callback func(state DeviceState, err error)
+}
+
+// CurrentDeviceState returns the state of the output device, and the error that made it fail if it is lost.
+func CurrentDeviceState() (DeviceState, error) {
+	deviceState.m.Lock()
+	defer deviceState.m.Unlock()
+	return deviceState.state, deviceState.err
+}
+
+// setDeviceState records the state and calls NewContextOptions.OnDeviceStateChange if it changed.
+func setDeviceState(state DeviceState, err error) {
+	deviceState.m.Lock()
+	changed := deviceState.state != state
+	deviceState.state = state
+	deviceState.err = err
+	callback := deviceState.callback
+	deviceState.m.Unlock()
+
+	if changed && callback != nil {
+		callback(state, err)
+	}
+}
+
+// reopenWithBackoff calls open until it succeeds, waiting longer and longer between the attempts.
+// It returns false if stop is closed first.
+func reopenWithBackoff(open func() error, stop <-chan struct{}) bool {
+	delay := minReopenDelay
+	for {
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return false
+		}
+		err := open()
+		if err == nil {
+			setDeviceState(DeviceStateOpen, nil)
+			return true
+		}
+		setDeviceState(DeviceStateLost, err)
+		delay = min(delay*2, maxReopenDelay)
+	}
+}
@@ -0,0 +1,25 @@
+package audio
+
+// Device describes an audio output device.
+type Device struct {
+	// ID identifies the device in NewContextOptions.Device.
+	ID string
+
+	// Name is a human-readable name, suitable for an options menu.
+	Name string
+
+	// Default is set for the device that the system currently plays to by default.
+	Default bool
+}
+
+// Devices lists the available audio output devices.
+// It can be called before InitContext, e.g. to let players pick a device in an options menu.
+//
+// On platforms that don't support device selection, only the default device is returned.
+func Devices() ([]Device, error) {
+	return devices()
+}
+
+func defaultDeviceOnly() []Device {
+	return []Device{{Name: "Default", Default: true}}
+}
@@ -0,0 +1,33 @@
+package audio_test
+
+import (
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/audio"
+)
+
+func TestDevices(t *testing.T) {
+	devices, err := audio.Devices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := 0
+	for _, d := range devices {
+		if d.Name == "" {
+			t.Errorf("device %q has no name", d.ID)
+		}
+		if d.Default {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		t.Errorf("expected at most one default device but got %d: %v", defaults, devices)
+	}
+}
+
+func TestDeviceOption(t *testing.T) {
+	d := useTestContext(t, &audio.NewContextOptions{SampleRate: 44100, Device: "usb-headset"})
+	if d.Options.Device != "usb-headset" {
+		t.Errorf("the driver should have been asked for %q but got %q", "usb-headset", d.Options.Device)
+	}
+}
@@ -0,0 +1,77 @@
+package audio
+
+import (
+	"github.com/Lundis/go-gameaudio/loaders/resample"
+)
+
+// Driver plays the mix on an output.
+// The built-in drivers play to the system's audio devices. Pass your own in NewContextOptions.Driver
+// to route the mix somewhere else, like your engine's audio layer, a file, or a test harness.
+type Driver interface {
+	// Open starts playing. The driver calls options.ReadFloat32s whenever it needs more frames,
+	// from one goroutine at a time.
+	// Open returns a channel that is closed when the driver is ready.
+	Open(options DriverOptions) (chan struct{}, error)
+
+	// Suspend pauses the output.
+	Suspend() error
+
+	// Resume continues the output after Suspend.
+	Resume() error
+
+	// Close stops the output for good.
+	Close() error
+
+	// Err returns the error that stopped the output, if any.
+	Err() error
+}
+
+// DriverOptions is what a Driver needs to know to play the mix.
+type DriverOptions struct {
+	SampleRate   int
+	ChannelCount int
+
+	// BufferSizeInBytes is the requested size of the device buffer in bytes, of ChannelCount 32 bit floats per frame.
+	// 0 means the driver's default.
+	BufferSizeInBytes int
+
+	// ApplicationName and Device are passed on from NewContextOptions.
+	ApplicationName string
+	Device          string
+
+	// ReadFloat32s fills buf with the next interleaved frames of the mix.
+	ReadFloat32s func(buf []float32)
+
+	// ReportXrun is called by the driver when the device ran out of audio. It is counted in Stats.
+	ReportXrun func()
+
+	// mix is ReadFloat32s without counting a callback in Stats, for ReadFloat32sAt. It is nil outside of InitContext.
+	mix func(buf []float32)
+}
+
+// ReadFloat32sAt returns a function like ReadFloat32s that converts the mix to sampleRate.
+// Drivers use it when the device doesn't accept the requested rate; assets and the mix stay at the requested rate.
+func (o DriverOptions) ReadFloat32sAt(sampleRate int) func(buf []float32) {
+	if sampleRate == o.SampleRate || sampleRate <= 0 {
+		return o.ReadFloat32s
+	}
+	s := resample.NewStream(o.SampleRate, sampleRate, o.ChannelCount)
+	if o.mix == nil {
+		read := o.ReadFloat32s
+		return func(buf []float32) {
+			s.Read(buf, read)
+		}
+	}
+	// the resampler reads the mix in its own blocks, so the callback is counted here
+	mix := o.mix
+	return func(buf []float32) {
+		recordCallback(len(buf)/o.ChannelCount, sampleRate)
+		s.Read(buf, mix)
+	}
+}
+
+// NewDefaultDriver returns the driver that InitContext uses if NewContextOptions.Driver is nil.
+// Wrap it to e.g. record the output while still playing it.
+func NewDefaultDriver() Driver {
+	return newDefaultDriver()
+}
@@ -0,0 +1,371 @@
+// Copyright 2021 The Oto Authors
+// Copyright 2025 Lundis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !android && !darwin && !js && !windows && !nintendosdk && !playstation5
+
+package audio
+
+// #cgo pkg-config: alsa
+//
+// #include <alsa/asoundlib.h>
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+type alsaContext struct {
+	options *DriverOptions
+
+	suspended bool
+
+	handle *C.snd_pcm_t
+
+	cond *sync.Cond
+
+	err atomicError
+
+	ready   chan struct{}
+	stopped chan struct{}
+
+	info OutputInfo
+	read func(buf []float32)
+	// delay is the number of frames in the device buffer after the last write
+	delay atomic.Int64
+}
+
+var errALSAClosed = errors.New("oto: ALSA context is closed")
+
+func alsaError(name string, err C.int) error {
+	return fmt.Errorf("oto: ALSA error at %s: %s", name, C.GoString(C.snd_strerror(err)))
+}
+
+// alsaDevices lists the output PCMs, except for "default" and "null".
+func alsaDevices() []Device {
+	const getAllDevices = -1
+
+	cPCMInterfaceName := C.CString("pcm")
+	defer C.free(unsafe.Pointer(cPCMInterfaceName))
+
+	var hints *unsafe.Pointer
+	err := C.snd_device_name_hint(getAllDevices, cPCMInterfaceName, &hints)
+	if err != 0 {
+		return nil
+	}
+	defer C.snd_device_name_free_hint(hints)
+
+	var devices []Device
+
+	cIoHintName := C.CString("IOID")
+	defer C.free(unsafe.Pointer(cIoHintName))
+	cNameHintName := C.CString("NAME")
+	defer C.free(unsafe.Pointer(cNameHintName))
+	cDescHintName := C.CString("DESC")
+	defer C.free(unsafe.Pointer(cDescHintName))
+
+	for it := hints; *it != nil; it = (*unsafe.Pointer)(unsafe.Pointer(uintptr(unsafe.Pointer(it)) + unsafe.Sizeof(uintptr(0)))) {
+		io := C.snd_device_name_get_hint(*it, cIoHintName)
+		defer func() {
+			if io != nil {
+				C.free(unsafe.Pointer(io))
+			}
+		}()
+		if C.GoString(io) == "Input" {
+			continue
+		}
+
+		name := C.snd_device_name_get_hint(*it, cNameHintName)
+		defer func() {
+			if name != nil {
+				C.free(unsafe.Pointer(name))
+			}
+		}()
+		if name == nil {
+			continue
+		}
+		goName := C.GoString(name)
+		if goName == "null" {
+			continue
+		}
+		if goName == "default" {
+			continue
+		}
+
+		desc := C.snd_device_name_get_hint(*it, cDescHintName)
+		defer func() {
+			if desc != nil {
+				C.free(unsafe.Pointer(desc))
+			}
+		}()
+		// descriptions are usually two lines: the card, and what the PCM does
+		goDesc := strings.ReplaceAll(C.GoString(desc), "\n", ", ")
+		if goDesc == "" {
+			goDesc = goName
+		}
+		devices = append(devices, Device{
+			ID:   goName,
+			Name: goDesc,
+		})
+	}
+	return devices
+}
+
+// deviceCandidates returns the PCMs to try in order: the configured one only, as unixDriver falls back to the default
+// device itself, or else the default ones and then all others.
+func deviceCandidates(device string) []string {
+	if device != "" {
+		return []string{device}
+	}
+	candidates := []string{"default", "plug:default"}
+	for _, d := range alsaDevices() {
+		candidates = append(candidates, d.ID)
+	}
+	return candidates
+}
+
+func newALSAContext(options *DriverOptions) *alsaContext {
+	c := &alsaContext{
+		options: options,
+		cond:    sync.NewCond(&sync.Mutex{}),
+		ready:   make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(c.ready)
+
+		// Open a default ALSA audio device for blocking stream playback
+		type openError struct {
+			device string
+			err    C.int
+		}
+		var openErrs []openError
+		var found bool
+
+		for _, name := range deviceCandidates(options.Device) {
+			cname := C.CString(name)
+			defer C.free(unsafe.Pointer(cname))
+			if err := C.snd_pcm_open(&c.handle, cname, C.SND_PCM_STREAM_PLAYBACK, 0); err < 0 {
+				openErrs = append(openErrs, openError{
+					device: name,
+					err:    err,
+				})
+				continue
+			}
+			found = true
+			break
+		}
+		if !found {
+			var msgs []string
+			for _, e := range openErrs {
+				msgs = append(msgs, fmt.Sprintf("%q: %s", e.device, C.GoString(C.snd_strerror(e.err))))
+			}
+			c.err.TryStore(fmt.Errorf("oto: ALSA error at snd_pcm_open: %s", strings.Join(msgs, ", ")))
+			close(c.stopped)
+			return
+		}
+
+		// TODO: Should snd_pcm_hw_params_set_periods be called explicitly?
+		const periods = 2
+		var periodSize C.snd_pcm_uframes_t
+		if options.BufferSizeInBytes != 0 {
+			periodSize = C.snd_pcm_uframes_t(options.BufferSizeInBytes / (options.ChannelCount * 4 * periods))
+		} else {
+			periodSize = C.snd_pcm_uframes_t(1024)
+		}
+		bufferSize := periodSize * periods
+		// ALSA's own rate conversion is linear, so convert in higher quality ourselves if the device needs another rate
+		sampleRate := C.unsigned(options.SampleRate)
+		if err := alsaPcmHwParams(c.handle, &sampleRate, options.ChannelCount, false, &bufferSize, &periodSize); err != nil {
+			c.err.TryStore(err)
+			close(c.stopped)
+			return
+		}
+		c.info = OutputInfo{
+			SampleRate: int(sampleRate),
+			BufferSize: int(bufferSize),
+			PeriodSize: int(periodSize),
+		}
+		c.read = options.ReadFloat32sAt(int(sampleRate))
+
+		go func() {
+			defer close(c.stopped)
+			buf32 := make([]float32, int(periodSize)*options.ChannelCount)
+			for {
+				if !c.readAndWrite(buf32) {
+					return
+				}
+			}
+		}()
+	}()
+
+	return c
+}
+
+// alsaPcmHwParams configures the PCM. Like the ALSA functions, it updates sampleRate, bufferSize and periodSize to the values that the device accepted.
+// If resample is false, ALSA doesn't convert the rate, and sampleRate is set to what the hardware supports.
+func alsaPcmHwParams(handle *C.snd_pcm_t, sampleRate *C.unsigned, channelCount int, resample bool, bufferSize, periodSize *C.snd_pcm_uframes_t) error {
+	var params *C.snd_pcm_hw_params_t
+	C.snd_pcm_hw_params_malloc(&params)
+	defer C.free(unsafe.Pointer(params))
+
+	if err := C.snd_pcm_hw_params_any(handle, params); err < 0 {
+		return alsaError("snd_pcm_hw_params_any", err)
+	}
+	if err := C.snd_pcm_hw_params_set_access(handle, params, C.SND_PCM_ACCESS_RW_INTERLEAVED); err < 0 {
+		return alsaError("snd_pcm_hw_params_set_access", err)
+	}
+	if err := C.snd_pcm_hw_params_set_format(handle, params, C.SND_PCM_FORMAT_FLOAT_LE); err < 0 {
+		return alsaError("snd_pcm_hw_params_set_format", err)
+	}
+	if err := C.snd_pcm_hw_params_set_channels(handle, params, C.unsigned(channelCount)); err < 0 {
+		return alsaError("snd_pcm_hw_params_set_channels", err)
+	}
+	var allowResample C.uint
+	if resample {
+		allowResample = 1
+	}
+	if err := C.snd_pcm_hw_params_set_rate_resample(handle, params, allowResample); err < 0 {
+		return alsaError("snd_pcm_hw_params_set_rate_resample", err)
+	}
+	if err := C.snd_pcm_hw_params_set_rate_near(handle, params, sampleRate, nil); err < 0 {
+		return alsaError("snd_pcm_hw_params_set_rate_near", err)
+	}
+	if err := C.snd_pcm_hw_params_set_buffer_size_near(handle, params, bufferSize); err < 0 {
+		return alsaError("snd_pcm_hw_params_set_buffer_size_near", err)
+	}
+	if err := C.snd_pcm_hw_params_set_period_size_near(handle, params, periodSize, nil); err < 0 {
+		return alsaError("snd_pcm_hw_params_set_period_size_near", err)
+	}
+	if err := C.snd_pcm_hw_params(handle, params); err < 0 {
+		return alsaError("snd_pcm_hw_params", err)
+	}
+	// the final values can still differ from what the _near functions returned
+	C.snd_pcm_hw_params_get_rate(params, sampleRate, nil)
+	C.snd_pcm_hw_params_get_buffer_size(params, bufferSize)
+	C.snd_pcm_hw_params_get_period_size(params, periodSize, nil)
+	return nil
+}
+
+func (c *alsaContext) readAndWrite(buf32 []float32) bool {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	for c.suspended && c.err.Load() == nil {
+		c.cond.Wait()
+	}
+	if c.err.Load() != nil {
+		return false
+	}
+
+	c.read(buf32)
+
+	channelCount := c.options.ChannelCount
+	for len(buf32) > 0 {
+		n := C.snd_pcm_writei(c.handle, unsafe.Pointer(&buf32[0]), C.snd_pcm_uframes_t(len(buf32)/channelCount))
+		if n == -C.EPIPE {
+			c.options.ReportXrun()
+		}
+		if n < 0 {
+			n = C.long(C.snd_pcm_recover(c.handle, C.int(n), 1))
+		}
+		if n < 0 {
+			c.err.TryStore(alsaError("snd_pcm_writei or snd_pcm_recover", C.int(n)))
+			return false
+		}
+		buf32 = buf32[int(n)*channelCount:]
+	}
+
+	// query the delay here, as not all plugins allow calls from other threads while writing
+	var delay C.snd_pcm_sframes_t
+	if C.snd_pcm_delay(c.handle, &delay) == 0 {
+		c.delay.Store(int64(delay))
+	}
+	return true
+}
+
+func (c *alsaContext) Suspend() error {
+	<-c.ready
+
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if err := c.err.Load(); err != nil {
+		return err.(error)
+	}
+
+	c.suspended = true
+
+	// Do not use snd_pcm_pause as not all devices support this.
+	// Do not use snd_pcm_drop as this might hang (https://github.com/libsdl-org/SDL/blob/a5c610b0a3857d3138f3f3da1f6dc3172c5ea4a8/src/audio/alsa/SDL_alsa_audio.c#L478).
+	return nil
+}
+
+func (c *alsaContext) Resume() error {
+	<-c.ready
+
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if err := c.err.Load(); err != nil {
+		return err.(error)
+	}
+
+	c.suspended = false
+	c.cond.Signal()
+	return nil
+}
+
+// Close stops playing and closes the device.
+func (c *alsaContext) Close() error {
+	<-c.ready
+
+	c.cond.L.Lock()
+	c.err.TryStore(errALSAClosed)
+	c.cond.L.Unlock()
+	c.cond.Signal()
+
+	<-c.stopped
+	if c.handle != nil {
+		C.snd_pcm_close(c.handle)
+		c.handle = nil
+	}
+	return nil
+}
+
+func (c *alsaContext) OutputLatency() time.Duration {
+	return framesToDuration(int(c.delay.Load()), c.info.SampleRate)
+}
+
+func (c *alsaContext) OutputInfo() OutputInfo {
+	return c.info
+}
+
+func (c *alsaContext) done() <-chan struct{} {
+	return c.stopped
+}
+
+func (c *alsaContext) Err() error {
+	if err := c.err.Load(); err != nil {
+		return err.(error)
+	}
+	return nil
+}
@@ -19,30 +19,41 @@ import (
 	"github.com/Lundis/go-gameaudio/audio/internal/oboe"
 )
 
-type context struct {
+// oboeDriver plays through Oboe.
+type oboeDriver struct {
 }
 
-func newContext(sampleRate int, channelCount int, bufferSizeInBytes int) (*Context, chan struct{}, error) {
+func newDefaultDriver() Driver {
+	return &oboeDriver{}
+}
+
+func (d *oboeDriver) Open(options DriverOptions) (chan struct{}, error) {
 	ready := make(chan struct{})
 	close(ready)
 
-	c := &Context{
-		mux: NewMux(sampleRate, channelCount),
+	if err := oboe.Play(options.SampleRate, options.ChannelCount, options.ReadFloat32s, options.BufferSizeInBytes); err != nil {
+		return nil, err
 	}
-	if err := oboe.Play(sampleRate, channelCount, c.mux.ReadFloat32s, bufferSizeInBytes); err != nil {
-		return nil, nil, err
-	}
-	return c, ready, nil
+	return ready, nil
 }
 
-func (c *context) Suspend() error {
+func (d *oboeDriver) Suspend() error {
 	return oboe.Suspend()
 }
 
-func (c *context) Resume() error {
+func (d *oboeDriver) Resume() error {
 	return oboe.Resume()
 }
 
-func (c *context) Err() error {
+// Close only pauses the stream, as the binding can't close it.
+func (d *oboeDriver) Close() error {
+	return oboe.Suspend()
+}
+
+func (d *oboeDriver) Err() error {
 	return nil
 }
+
+func devices() ([]Device, error) {
+	return defaultDeviceOnly(), nil
+}
@@ -36,34 +36,51 @@ import (
 
 //export oto_OnReadCallback
 func oto_OnReadCallback(buf *C.float, length C.size_t) {
-	currentContext.mux.ReadFloat32s(unsafe.Slice((*float32)(unsafe.Pointer(buf)), length))
+	currentDriver.options.ReadFloat32s(unsafe.Slice((*float32)(unsafe.Pointer(buf)), length))
 }
 
-type context struct {
+// consoleDriver plays through the platform's audio proxy.
+type consoleDriver struct {
+	options DriverOptions
 }
 
-func newContext(sampleRate int, channelCount int, bufferSizeInBytes int) (*context, chan struct{}, error) {
+// currentDriver is the driver that oto_OnReadCallback goes to.
+var currentDriver *consoleDriver
+
+func newDefaultDriver() Driver {
+	return &consoleDriver{}
+}
+
+func (d *consoleDriver) Open(options DriverOptions) (chan struct{}, error) {
 	ready := make(chan struct{})
 	close(ready)
 
-	c := &context{
-		mux: NewMux(sampleRate, channelCount),
-	}
-	C.oto_OpenAudioProxy(C.int(sampleRate), C.int(channelCount), C.int(bufferSizeInBytes))
+	d.options = options
+	currentDriver = d
+	C.oto_OpenAudioProxy(C.int(options.SampleRate), C.int(options.ChannelCount), C.int(options.BufferSizeInBytes))
 
-	return c, ready, nil
+	return ready, nil
 }
 
-func (c *context) Suspend() error {
+func (d *consoleDriver) Suspend() error {
 	// Do nothing so far.
 	return nil
 }
 
-func (c *context) Resume() error {
+func (d *consoleDriver) Resume() error {
 	// Do nothing so far.
 	return nil
 }
 
-func (c *context) Err() error {
+func (d *consoleDriver) Close() error {
+	// Do nothing so far.
 	return nil
 }
+
+func (d *consoleDriver) Err() error {
+	return nil
+}
+
+func devices() ([]Device, error) {
+	return defaultDeviceOnly(), nil
+}
@@ -16,6 +16,7 @@
 package audio
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
 	"sync"
@@ -70,7 +71,10 @@ func newAudioQueue(sampleRate, channelCount int, oneBufferSizeInBytes int) (_Aud
 	return audioQueue, bufs, nil
 }
 
-type context struct {
+// coreAudioDriver plays through an AudioQueue.
+type coreAudioDriver struct {
+	options DriverOptions
+
 	audioQueue      _AudioQueueRef
 	unqueuedBuffers []_AudioQueueBufferRef
 
@@ -84,12 +88,24 @@ type context struct {
 	err atomicError
 }
 
-var darwinContext context
+// darwinContext is the driver that the AudioQueue callbacks go to.
+var darwinContext *coreAudioDriver
+
+var errCoreAudioClosed = errors.New("oto: CoreAudio context is closed")
+
+var (
+	notificationHandlerOnce sync.Once
+	notificationHandlerErr  error
+)
+
+func newDefaultDriver() Driver {
+	return &coreAudioDriver{}
+}
 
 // TODO: Convert the error code correctly.
 // See https://stackoverflow.com/questions/2196869/how-do-you-convert-an-iphone-osstatus-code-to-something-useful
 
-func newContext(bufferSizeInBytes int) (chan struct{}, error) {
+func (c *coreAudioDriver) Open(options DriverOptions) (chan struct{}, error) {
 	// defaultOneBufferSizeInBytes is the default buffer size in bytes.
 	//
 	// 12288 seems necessary at least on iPod touch (7th) and MacBook Pro 2020.
@@ -98,20 +114,20 @@ func newContext(bufferSizeInBytes int) (chan struct{}, error) {
 	const defaultOneBufferSizeInBytes = 12288
 
 	var oneBufferSizeInBytes int
-	if bufferSizeInBytes != 0 {
-		oneBufferSizeInBytes = bufferSizeInBytes / bufferCount
+	if options.BufferSizeInBytes != 0 {
+		oneBufferSizeInBytes = options.BufferSizeInBytes / bufferCount
 	} else {
 		oneBufferSizeInBytes = defaultOneBufferSizeInBytes
 	}
-	bytesPerSample := ChannelCount * 4
+	bytesPerSample := options.ChannelCount * 4
 	oneBufferSizeInBytes = oneBufferSizeInBytes / bytesPerSample * bytesPerSample
 
 	ready := make(chan struct{})
 
-	darwinContext = context{
-		cond:                 sync.NewCond(&sync.Mutex{}),
-		oneBufferSizeInBytes: oneBufferSizeInBytes,
-	}
+	c.options = options
+	c.cond = sync.NewCond(&sync.Mutex{})
+	c.oneBufferSizeInBytes = oneBufferSizeInBytes
+	darwinContext = c
 
 	if err := initializeAPI(); err != nil {
 		return nil, err
@@ -128,42 +144,51 @@ func newContext(bufferSizeInBytes int) (chan struct{}, error) {
 			}
 		}()
 
-		q, bs, err := newAudioQueue(mux.sampleRate, ChannelCount, oneBufferSizeInBytes)
+		q, bs, err := newAudioQueue(options.SampleRate, options.ChannelCount, oneBufferSizeInBytes)
 		if err != nil {
-			darwinContext.err.TryStore(err)
+			c.err.TryStore(err)
 			return
 		}
-		darwinContext.audioQueue = q
-		darwinContext.unqueuedBuffers = bs
+		c.audioQueue = q
+		c.unqueuedBuffers = bs
 
-		if err := setNotificationHandler(); err != nil {
-			darwinContext.err.TryStore(err)
+		if options.Device != "" {
+			// the default device is used if the configured one is gone
+			_ = setOutputDevice(q, options.Device)
+		}
+
+		// the handler outlives the driver, it only needs to be set once
+		notificationHandlerOnce.Do(func() {
+			notificationHandlerErr = setNotificationHandler()
+		})
+		if err := notificationHandlerErr; err != nil {
+			c.err.TryStore(err)
 			return
 		}
 
 		var retryCount int
 	try:
-		if osstatus := _AudioQueueStart(darwinContext.audioQueue, nil); osstatus != noErr {
+		if osstatus := _AudioQueueStart(c.audioQueue, nil); osstatus != noErr {
 			if osstatus == avAudioSessionErrorCodeCannotStartPlaying && retryCount < 100 {
 				// TODO: use sleepTime() after investigating when this error happens.
 				time.Sleep(10 * time.Millisecond)
 				retryCount++
 				goto try
 			}
-			darwinContext.err.TryStore(fmt.Errorf("oto: AudioQueueStart failed at newContext: %d", osstatus))
+			c.err.TryStore(fmt.Errorf("oto: AudioQueueStart failed at Open: %d", osstatus))
 			return
 		}
 
 		close(ready)
 		readyClosed = true
 
-		darwinContext.loop()
+		c.loop()
 	}()
 
 	return ready, nil
 }
 
-func (c *context) wait() bool {
+func (c *coreAudioDriver) wait() bool {
 	c.cond.L.Lock()
 	defer c.cond.L.Unlock()
 
@@ -173,7 +198,7 @@ func (c *context) wait() bool {
 	return c.err.Load() == nil
 }
 
-func (c *context) loop() {
+func (c *coreAudioDriver) loop() {
 	buf32 := make([]float32, c.oneBufferSizeInBytes/4)
 	for {
 		if !c.wait() {
@@ -183,7 +208,7 @@ func (c *context) loop() {
 	}
 }
 
-func (c *context) appendBuffer(buf32 []float32) {
+func (c *coreAudioDriver) appendBuffer(buf32 []float32) {
 	c.cond.L.Lock()
 	defer c.cond.L.Unlock()
 
@@ -211,7 +236,7 @@ func (c *context) appendBuffer(buf32 []float32) {
 	copy(c.unqueuedBuffers, c.unqueuedBuffers[1:])
 	c.unqueuedBuffers = c.unqueuedBuffers[:len(c.unqueuedBuffers)-1]
 
-	mux.ReadFloat32s(buf32)
+	c.options.ReadFloat32s(buf32)
 	copy(unsafe.Slice((*float32)(unsafe.Pointer(buf.mAudioData)), buf.mAudioDataByteSize/float32SizeInBytes), buf32)
 
 	if osstatus := _AudioQueueEnqueueBuffer(c.audioQueue, buf, 0, nil); osstatus != noErr {
@@ -219,7 +244,7 @@ func (c *context) appendBuffer(buf32 []float32) {
 	}
 }
 
-func (c *context) Suspend() error {
+func (c *coreAudioDriver) Suspend() error {
 	c.cond.L.Lock()
 	defer c.cond.L.Unlock()
 
@@ -232,7 +257,7 @@ func (c *context) Suspend() error {
 	return nil
 }
 
-func (c *context) Resume() error {
+func (c *coreAudioDriver) Resume() error {
 	c.cond.L.Lock()
 	defer c.cond.L.Unlock()
 
@@ -245,14 +270,14 @@ func (c *context) Resume() error {
 	return nil
 }
 
-func (c *context) pause() error {
+func (c *coreAudioDriver) pause() error {
 	if osstatus := _AudioQueuePause(c.audioQueue); osstatus != noErr {
 		return fmt.Errorf("oto: AudioQueuePause failed: %d", osstatus)
 	}
 	return nil
 }
 
-func (c *context) resume() error {
+func (c *coreAudioDriver) resume() error {
 	var retryCount int
 try:
 	if osstatus := _AudioQueueStart(c.audioQueue, nil); osstatus != noErr {
@@ -274,7 +299,46 @@ try:
 	return nil
 }
 
-func (c *context) Err() error {
+// Close stops the loop and disposes the AudioQueue.
+func (c *coreAudioDriver) Close() error {
+	c.cond.L.Lock()
+	c.err.TryStore(errCoreAudioClosed)
+	c.cond.L.Unlock()
+	c.cond.Signal()
+
+	c.cond.L.Lock()
+	q := c.audioQueue
+	c.audioQueue = 0
+	c.cond.L.Unlock()
+	if q == 0 {
+		return nil
+	}
+	if osstatus := _AudioQueueDispose(q, true); osstatus != noErr {
+		return fmt.Errorf("oto: AudioQueueDispose failed: %d", osstatus)
+	}
+	return nil
+}
+
+func (c *coreAudioDriver) OutputLatency() time.Duration {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+	queued := bufferCount - len(c.unqueuedBuffers)
+	return framesToDuration(queued*c.framesPerBuffer(), c.options.SampleRate)
+}
+
+func (c *coreAudioDriver) OutputInfo() OutputInfo {
+	return OutputInfo{
+		SampleRate: c.options.SampleRate,
+		BufferSize: bufferCount * c.framesPerBuffer(),
+		PeriodSize: c.framesPerBuffer(),
+	}
+}
+
+func (c *coreAudioDriver) framesPerBuffer() int {
+	return c.oneBufferSizeInBytes / (c.options.ChannelCount * float32SizeInBytes)
+}
+
+func (c *coreAudioDriver) Err() error {
 	if err := c.err.Load(); err != nil {
 		return err.(error)
 	}
@@ -284,7 +348,15 @@ func (c *context) Err() error {
 func render(inUserData unsafe.Pointer, inAQ _AudioQueueRef, inBuffer _AudioQueueBufferRef) {
 	darwinContext.cond.L.Lock()
 	defer darwinContext.cond.L.Unlock()
+	if darwinContext.audioQueue != inAQ {
+		// a buffer of a disposed queue
+		return
+	}
 	darwinContext.unqueuedBuffers = append(darwinContext.unqueuedBuffers, inBuffer)
+	if len(darwinContext.unqueuedBuffers) == bufferCount && !darwinContext.toPause {
+		// the queue played everything it had
+		darwinContext.options.ReportXrun()
+	}
 	darwinContext.cond.Signal()
 }
 
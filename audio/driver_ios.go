@@ -22,3 +22,11 @@ func setNotificationHandler() error {
 	// https://stackoverflow.com/questions/24404463/ios-siri-not-available-does-not-return-avaudiosessioninterruptionoptionshouldre
 	return nil
 }
+
+func devices() ([]Device, error) {
+	return defaultDeviceOnly(), nil
+}
+
+func setOutputDevice(queue _AudioQueueRef, uid string) error {
+	return nil
+}
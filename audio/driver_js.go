@@ -20,18 +20,28 @@ import (
 	"fmt"
 	"runtime"
 	"syscall/js"
+	"time"
 	"unsafe"
 )
 
-var jsContext struct {
+// webAudioDriver plays through an AudioWorklet, or a ScriptProcessor on older browsers.
+type webAudioDriver struct {
+	options DriverOptions
+
 	audioContext            js.Value
 	scriptProcessor         js.Value
 	scriptProcessorCallback js.Func
 	ready                   bool
 }
 
-func newContext(bufferSizeInBytes int) (chan struct{}, error) {
+func newDefaultDriver() Driver {
+	return &webAudioDriver{}
+}
+
+func (d *webAudioDriver) Open(options DriverOptions) (chan struct{}, error) {
 	ready := make(chan struct{})
+	bufferSizeInBytes := options.BufferSizeInBytes
+	d.options = options
 
 	class := js.Global().Get("AudioContext")
 	if !class.Truthy() {
@@ -40,19 +50,20 @@ func newContext(bufferSizeInBytes int) (chan struct{}, error) {
 	if !class.Truthy() {
 		return nil, errors.New("oto: AudioContext or webkitAudioContext was not found")
 	}
-	options := js.Global().Get("Object").New()
-	options.Set("sampleRate", mux.sampleRate)
+	contextOptions := js.Global().Get("Object").New()
+	contextOptions.Set("sampleRate", options.SampleRate)
 
-	jsContext.audioContext = class.New(options)
+	d.audioContext = class.New(contextOptions)
 
 	if bufferSizeInBytes == 0 {
 		// 4096 was not great at least on Safari 15.
 		bufferSizeInBytes = 8192 * ChannelCount
 	}
+	d.options.BufferSizeInBytes = bufferSizeInBytes
 
 	buf32 := make([]float32, bufferSizeInBytes/4)
 
-	if w := jsContext.audioContext.Get("audioWorklet"); w.Truthy() {
+	if w := d.audioContext.Get("audioWorklet"); w.Truthy() {
 		script := fmt.Sprintf(`
 class OtoWorkletProcessor extends AudioWorkletProcessor {
 	constructor() {
@@ -106,20 +117,20 @@ class OtoWorkletProcessor extends AudioWorkletProcessor {
 registerProcessor('oto-worklet-processor', OtoWorkletProcessor);
 `, bufferSizeInBytes/4/ChannelCount, ChannelCount)
 		w.Call("addModule", newScriptURL(script)).Call("then", js.FuncOf(func(this js.Value, arguments []js.Value) any {
-			node := js.Global().Get("AudioWorkletNode").New(jsContext.audioContext, "oto-worklet-processor", map[string]any{
+			node := js.Global().Get("AudioWorkletNode").New(d.audioContext, "oto-worklet-processor", map[string]any{
 				"outputChannelCount": []any{ChannelCount},
 			})
 			port := node.Get("port")
 			// When the worklet processor requests more data, send the request to the worklet.
 			port.Set("onmessage", js.FuncOf(func(this js.Value, arguments []js.Value) any {
-				mux.ReadFloat32s(buf32)
+				options.ReadFloat32s(buf32)
 				buf := float32SliceToTypedArray(buf32)
 				port.Call("postMessage", buf, map[string]any{
 					"transfer": []any{buf.Get("buffer")},
 				})
 				return nil
 			}))
-			node.Call("connect", jsContext.audioContext.Get("destination"))
+			node.Call("connect", d.audioContext.Get("destination"))
 			return nil
 		}))
 	} else {
@@ -130,9 +141,9 @@ registerProcessor('oto-worklet-processor', OtoWorkletProcessor);
 			chBuf32[i] = make([]float32, len(buf32)/ChannelCount)
 		}
 
-		sp := jsContext.audioContext.Call("createScriptProcessor", bufferSizeInBytes/4/ChannelCount, 0, ChannelCount)
+		sp := d.audioContext.Call("createScriptProcessor", bufferSizeInBytes/4/ChannelCount, 0, ChannelCount)
 		f := js.FuncOf(func(this js.Value, arguments []js.Value) any {
-			mux.ReadFloat32s(buf32)
+			options.ReadFloat32s(buf32)
 			for i := 0; i < ChannelCount; i++ {
 				for j := range chBuf32[i] {
 					chBuf32[i][j] = buf32[j*ChannelCount+i]
@@ -153,9 +164,9 @@ registerProcessor('oto-worklet-processor', OtoWorkletProcessor);
 			return nil
 		})
 		sp.Call("addEventListener", "audioprocess", f)
-		jsContext.scriptProcessor = sp
-		jsContext.scriptProcessorCallback = f
-		sp.Call("connect", jsContext.audioContext.Get("destination"))
+		d.scriptProcessor = sp
+		d.scriptProcessorCallback = f
+		sp.Call("connect", d.audioContext.Get("destination"))
 	}
 
 	// Browsers require user interaction to start the audio.
@@ -166,7 +177,7 @@ registerProcessor('oto-worklet-processor', OtoWorkletProcessor);
 	var onEventFired js.Func
 	var onResumeSuccess js.Func
 	onResumeSuccess = js.FuncOf(func(this js.Value, arguments []js.Value) any {
-		jsContext.ready = true
+		d.ready = true
 		close(ready)
 		for _, event := range events {
 			js.Global().Get("document").Call("removeEventListener", event, onEventFired)
@@ -176,8 +187,8 @@ registerProcessor('oto-worklet-processor', OtoWorkletProcessor);
 		return nil
 	})
 	onEventFired = js.FuncOf(func(this js.Value, arguments []js.Value) any {
-		if !jsContext.ready {
-			jsContext.audioContext.Call("resume").Call("then", onResumeSuccess)
+		if !d.ready {
+			d.audioContext.Call("resume").Call("then", onResumeSuccess)
 		}
 		return nil
 	})
@@ -188,6 +199,48 @@ registerProcessor('oto-worklet-processor', OtoWorkletProcessor);
 	return ready, nil
 }
 
+func (d *webAudioDriver) Suspend() error {
+	d.audioContext.Call("suspend")
+	return nil
+}
+
+func (d *webAudioDriver) Resume() error {
+	d.audioContext.Call("resume")
+	return nil
+}
+
+func (d *webAudioDriver) Close() error {
+	d.audioContext.Call("close")
+	return nil
+}
+
+func (d *webAudioDriver) OutputLatency() time.Duration {
+	// both are in seconds, and outputLatency is missing in some browsers
+	var latency float64
+	if v := d.audioContext.Get("baseLatency"); v.Truthy() {
+		latency += v.Float()
+	}
+	if v := d.audioContext.Get("outputLatency"); v.Truthy() {
+		latency += v.Float()
+	}
+	// plus what is waiting in our own buffer
+	frames := d.options.BufferSizeInBytes / 4 / d.options.ChannelCount
+	return time.Duration(latency*float64(time.Second)) + framesToDuration(frames, d.options.SampleRate)
+}
+
+func (d *webAudioDriver) OutputInfo() OutputInfo {
+	frames := d.options.BufferSizeInBytes / 4 / d.options.ChannelCount
+	return OutputInfo{
+		SampleRate: d.audioContext.Get("sampleRate").Int(),
+		BufferSize: frames,
+		PeriodSize: frames,
+	}
+}
+
+func (d *webAudioDriver) Err() error {
+	return nil
+}
+
 func float32SliceToTypedArray(s []float32) js.Value {
 	bs := unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*4)
 	a := js.Global().Get("Uint8Array").New(len(bs))
@@ -201,3 +254,7 @@ func newScriptURL(script string) js.Value {
 	blob := js.Global().Get("Blob").New([]any{script}, map[string]any{"type": "text/javascript"})
 	return js.Global().Get("URL").Call("createObjectURL", blob)
 }
+
+func devices() ([]Device, error) {
+	return defaultDeviceOnly(), nil
+}
@@ -18,6 +18,9 @@
 package audio
 
 import (
+	"bytes"
+	"fmt"
+	"sync"
 	"unsafe"
 
 	"github.com/ebitengine/purego"
@@ -75,3 +78,135 @@ func setNotificationHandler() error {
 	)
 	return nil
 }
+
+const (
+	kAudioObjectSystemObject = 1
+
+	kAudioObjectPropertyScopeGlobal = 0x676c6f62 // 'glob'
+	kAudioObjectPropertyScopeOutput = 0x6f757470 // 'outp'
+	kAudioObjectPropertyElementMain = 0
+
+	kAudioHardwarePropertyDevices             = 0x64657623 // 'dev#'
+	kAudioHardwarePropertyDefaultOutputDevice = 0x644f7574 // 'dOut'
+	kAudioDevicePropertyDeviceUID             = 0x75696420 // 'uid '
+	kAudioDevicePropertyStreams               = 0x73746d23 // 'stm#'
+	kAudioObjectPropertyName                  = 0x6c6e616d // 'lnam'
+	kCFStringEncodingUTF8                     = 0x08000100
+)
+
+type _AudioObjectPropertyAddress struct {
+	mSelector uint32
+	mScope    uint32
+	mElement  uint32
+}
+
+type _CFStringRef uintptr
+
+var (
+	_AudioObjectGetPropertyDataSize func(inObjectID uint32, inAddress *_AudioObjectPropertyAddress, inQualifierDataSize uint32, inQualifierData unsafe.Pointer, outDataSize *uint32) int32
+	_AudioObjectGetPropertyData     func(inObjectID uint32, inAddress *_AudioObjectPropertyAddress, inQualifierDataSize uint32, inQualifierData unsafe.Pointer, ioDataSize *uint32, outData unsafe.Pointer) int32
+	_CFStringCreateWithCString      func(alloc uintptr, cStr *byte, encoding uint32) _CFStringRef
+	_CFStringGetCString             func(theString _CFStringRef, buffer *byte, bufferSize int, encoding uint32) bool
+	_CFRelease                      func(cf uintptr)
+
+	coreAudioOnce sync.Once
+	coreAudioErr  error
+)
+
+func initializeCoreAudio() error {
+	coreAudioOnce.Do(func() {
+		coreAudio, err := purego.Dlopen("/System/Library/Frameworks/CoreAudio.framework/CoreAudio", purego.RTLD_LAZY|purego.RTLD_GLOBAL)
+		if err != nil {
+			coreAudioErr = err
+			return
+		}
+		coreFoundation, err := purego.Dlopen("/System/Library/Frameworks/CoreFoundation.framework/CoreFoundation", purego.RTLD_LAZY|purego.RTLD_GLOBAL)
+		if err != nil {
+			coreAudioErr = err
+			return
+		}
+		purego.RegisterLibFunc(&_AudioObjectGetPropertyDataSize, coreAudio, "AudioObjectGetPropertyDataSize")
+		purego.RegisterLibFunc(&_AudioObjectGetPropertyData, coreAudio, "AudioObjectGetPropertyData")
+		purego.RegisterLibFunc(&_CFStringCreateWithCString, coreFoundation, "CFStringCreateWithCString")
+		purego.RegisterLibFunc(&_CFStringGetCString, coreFoundation, "CFStringGetCString")
+		purego.RegisterLibFunc(&_CFRelease, coreFoundation, "CFRelease")
+	})
+	return coreAudioErr
+}
+
+func devices() ([]Device, error) {
+	if err := initializeCoreAudio(); err != nil {
+		return nil, err
+	}
+
+	var defaultID uint32
+	size := uint32(unsafe.Sizeof(defaultID))
+	address := _AudioObjectPropertyAddress{kAudioHardwarePropertyDefaultOutputDevice, kAudioObjectPropertyScopeGlobal, kAudioObjectPropertyElementMain}
+	if osstatus := _AudioObjectGetPropertyData(kAudioObjectSystemObject, &address, 0, nil, &size, unsafe.Pointer(&defaultID)); osstatus != noErr {
+		return nil, fmt.Errorf("oto: AudioObjectGetPropertyData failed: %d", osstatus)
+	}
+
+	address.mSelector = kAudioHardwarePropertyDevices
+	if osstatus := _AudioObjectGetPropertyDataSize(kAudioObjectSystemObject, &address, 0, nil, &size); osstatus != noErr {
+		return nil, fmt.Errorf("oto: AudioObjectGetPropertyDataSize failed: %d", osstatus)
+	}
+	ids := make([]uint32, size/4)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if osstatus := _AudioObjectGetPropertyData(kAudioObjectSystemObject, &address, 0, nil, &size, unsafe.Pointer(&ids[0])); osstatus != noErr {
+		return nil, fmt.Errorf("oto: AudioObjectGetPropertyData failed: %d", osstatus)
+	}
+	ids = ids[:size/4]
+
+	var devices []Device
+	for _, id := range ids {
+		// skip input-only devices
+		address := _AudioObjectPropertyAddress{kAudioDevicePropertyStreams, kAudioObjectPropertyScopeOutput, kAudioObjectPropertyElementMain}
+		if osstatus := _AudioObjectGetPropertyDataSize(id, &address, 0, nil, &size); osstatus != noErr || size == 0 {
+			continue
+		}
+		uid, err := deviceString(id, kAudioDevicePropertyDeviceUID)
+		if err != nil {
+			return nil, err
+		}
+		name, err := deviceString(id, kAudioObjectPropertyName)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, Device{ID: uid, Name: name, Default: id == defaultID})
+	}
+	return devices, nil
+}
+
+func deviceString(id uint32, selector uint32) (string, error) {
+	var str _CFStringRef
+	size := uint32(unsafe.Sizeof(str))
+	address := _AudioObjectPropertyAddress{selector, kAudioObjectPropertyScopeGlobal, kAudioObjectPropertyElementMain}
+	if osstatus := _AudioObjectGetPropertyData(id, &address, 0, nil, &size, unsafe.Pointer(&str)); osstatus != noErr {
+		return "", fmt.Errorf("oto: AudioObjectGetPropertyData failed: %d", osstatus)
+	}
+	defer _CFRelease(uintptr(str))
+
+	buf := make([]byte, 1024)
+	if !_CFStringGetCString(str, &buf[0], len(buf), kCFStringEncodingUTF8) {
+		return "", nil
+	}
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+	return string(buf), nil
+}
+
+func setOutputDevice(queue _AudioQueueRef, uid string) error {
+	if err := initializeCoreAudio(); err != nil {
+		return err
+	}
+	cstr := append([]byte(uid), 0)
+	str := _CFStringCreateWithCString(0, &cstr[0], kCFStringEncodingUTF8)
+	defer _CFRelease(uintptr(str))
+	if osstatus := _AudioQueueSetProperty(queue, kAudioQueueProperty_CurrentDevice, unsafe.Pointer(&str), uint32(unsafe.Sizeof(str))); osstatus != noErr {
+		return fmt.Errorf("oto: AudioQueueSetProperty failed: %d", osstatus)
+	}
+	return nil
+}
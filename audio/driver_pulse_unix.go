@@ -0,0 +1,180 @@
+//go:build !android && !darwin && !js && !windows && !nintendosdk && !playstation5
+
+package audio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio/internal/pulse"
+)
+
+// defaultPulseLatency is used when no buffer size is specified, as the server default of 2 seconds is far too much for games.
+const defaultPulseLatency = 50 * time.Millisecond
+
+// pulseContext plays through the PulseAudio native protocol, which PipeWire serves too.
+type pulseContext struct {
+	options *DriverOptions
+
+	client *pulse.Client
+	stream *pulse.PlaybackStream
+
+	suspended bool
+	closed    bool
+	cond      *sync.Cond
+
+	err     atomicError
+	stopped chan struct{}
+
+	latency          time.Duration
+	latencyUpdatedAt time.Time
+	latencyM         sync.Mutex
+}
+
+// latencyQueryInterval limits how often the server is asked for the latency, as every query is a round trip.
+const latencyQueryInterval = 100 * time.Millisecond
+
+func newPulseContext(options *DriverOptions) (*pulseContext, error) {
+	client, err := pulse.Dial(options.ApplicationName)
+	if err != nil {
+		return nil, err
+	}
+
+	bufferSizeInBytes := options.BufferSizeInBytes
+	if bufferSizeInBytes == 0 {
+		bytesPerSecond := options.SampleRate * options.ChannelCount * 4
+		bufferSizeInBytes = int(defaultPulseLatency.Seconds() * float64(bytesPerSecond))
+	}
+	streamOptions := pulse.StreamOptions{
+		Name:          "Game audio",
+		SampleRate:    options.SampleRate,
+		ChannelCount:  options.ChannelCount,
+		Sink:          options.Device,
+		TargetLatency: bufferSizeInBytes,
+	}
+	stream, err := client.CreatePlaybackStream(streamOptions)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	c := &pulseContext{
+		options: options,
+		client:  client,
+		stream:  stream,
+		cond:    sync.NewCond(&sync.Mutex{}),
+		stopped: make(chan struct{}),
+	}
+	go c.loop()
+	return c, nil
+}
+
+func (c *pulseContext) loop() {
+	defer close(c.stopped)
+
+	var buf32 []float32
+	underflows := 0
+	for {
+		c.cond.L.Lock()
+		for c.suspended && !c.closed {
+			c.cond.Wait()
+		}
+		closed := c.closed
+		c.cond.L.Unlock()
+		if closed {
+			return
+		}
+
+		n, err := c.stream.WaitRequest()
+		if err != nil {
+			c.err.TryStore(fmt.Errorf("oto: PulseAudio error: %w", err))
+			return
+		}
+		// the server tells us asynchronously when it ran dry
+		for u := c.stream.Underflows(); underflows < u; underflows++ {
+			c.options.ReportXrun()
+		}
+		samples := n / 4
+		samples -= samples % c.options.ChannelCount
+		if samples == 0 {
+			continue
+		}
+		if cap(buf32) < samples {
+			buf32 = make([]float32, samples)
+		}
+		buf32 = buf32[:samples]
+
+		c.options.ReadFloat32s(buf32)
+		if err := c.stream.Write(buf32); err != nil {
+			c.err.TryStore(fmt.Errorf("oto: PulseAudio error: %w", err))
+			return
+		}
+	}
+}
+
+func (c *pulseContext) Suspend() error {
+	if err := c.err.Load(); err != nil {
+		return err
+	}
+	c.cond.L.Lock()
+	c.suspended = true
+	c.cond.L.Unlock()
+	return c.stream.Cork(true)
+}
+
+func (c *pulseContext) Resume() error {
+	if err := c.err.Load(); err != nil {
+		return err
+	}
+	if err := c.stream.Cork(false); err != nil {
+		return err
+	}
+	c.cond.L.Lock()
+	c.suspended = false
+	c.cond.L.Unlock()
+	c.cond.Signal()
+	return nil
+}
+
+// Close stops playing and disconnects from the server.
+func (c *pulseContext) Close() error {
+	c.cond.L.Lock()
+	c.closed = true
+	c.cond.L.Unlock()
+	c.cond.Signal()
+
+	err := c.client.Close()
+	<-c.stopped
+	return err
+}
+
+func (c *pulseContext) OutputLatency() time.Duration {
+	c.latencyM.Lock()
+	defer c.latencyM.Unlock()
+	if time.Since(c.latencyUpdatedAt) >= latencyQueryInterval {
+		if latency, err := c.stream.Latency(); err == nil {
+			c.latency = latency
+		}
+		c.latencyUpdatedAt = time.Now()
+	}
+	return c.latency
+}
+
+func (c *pulseContext) OutputInfo() OutputInfo {
+	frameSize := c.options.ChannelCount * 4
+	return OutputInfo{
+		// the server resamples if the sink runs at another rate
+		SampleRate: c.options.SampleRate,
+		BufferSize: c.stream.BufferSize() / frameSize,
+		PeriodSize: c.stream.MinRequest() / frameSize,
+	}
+}
+
+func (c *pulseContext) done() <-chan struct{} {
+	return c.stopped
+}
+
+func (c *pulseContext) Err() error {
+	return c.err.Load()
+}
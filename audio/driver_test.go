@@ -0,0 +1,94 @@
+package audio_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio"
+	"github.com/Lundis/go-gameaudio/audio/audiotest"
+)
+
+// useTestContext replaces the context of TestMain by one that plays to an audiotest.Driver for the rest of the test.
+func useTestContext(t *testing.T, options *audio.NewContextOptions) *audiotest.Driver {
+	d, err := audiotest.InitContext(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := audio.CloseContext(); err != nil {
+			t.Error(err)
+		}
+		ready, err := audio.InitContext(&audio.NewContextOptions{SampleRate: 48000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-ready
+	})
+	return d
+}
+
+func TestCustomDriver(t *testing.T) {
+	d := useTestContext(t, &audio.NewContextOptions{SampleRate: 44100})
+	d.Latency = 20 * time.Millisecond
+	d.Info = audio.OutputInfo{SampleRate: 44100, BufferSize: 1024, PeriodSize: 512}
+	if d.Options.SampleRate != 44100 || d.Options.ChannelCount != audio.ChannelCount {
+		t.Errorf("unexpected driver options %+v", d.Options)
+	}
+
+	sound := audio.NewSound([]float32{0.25, -0.25, 0.5, -0.5}, 1, audio.ChannelIdDefault)
+	sound.Play()
+	buf := make([]float32, 8)
+	d.Read(buf)
+	want := []float32{0.25, -0.25, 0.5, -0.5, 0, 0, 0, 0}
+	for i := range want {
+		if buf[i] != want[i] {
+			t.Fatalf("the driver should have pulled %v but got %v", want, buf)
+		}
+	}
+
+	if latency := audio.OutputLatency(); latency != 20*time.Millisecond {
+		t.Errorf("the latency should come from the driver but was %v", latency)
+	}
+	if info := audio.CurrentOutput(); info.SampleRate != 44100 || info.BufferSize != 1024 || info.PeriodSize != 512 {
+		t.Errorf("unexpected output info %+v", info)
+	}
+
+	// a device that runs at 48000 Hz hears the 44100 Hz mix at the right pitch
+	tone := make([]float32, 44100*2)
+	for i := 0; i < len(tone); i += 2 {
+		tone[i] = float32(math.Sin(2 * math.Pi * 1000 * float64(i/2) / 44100))
+	}
+	audio.NewSound(tone, 1, audio.ChannelIdDefault).Play()
+	converted := make([]float32, 48000*2)
+	d.Options.ReadFloat32sAt(48000)(converted)
+	crossings := 0
+	for i := 2; i < len(converted); i += 2 {
+		if converted[i-2] < 0 && converted[i] >= 0 {
+			crossings++
+		}
+	}
+	if crossings < 995 || crossings > 1000 {
+		t.Errorf("a second of a 1000 Hz tone should have about 1000 rising zero crossings but had %d", crossings)
+	}
+
+	if err := audio.Suspend(); err != nil || !d.Suspended {
+		t.Errorf("Suspend should reach the driver (%v)", err)
+	}
+	if err := audio.Resume(); err != nil || d.Suspended {
+		t.Errorf("Resume should reach the driver (%v)", err)
+	}
+	if err := audio.CloseContext(); err != nil || !d.Closed {
+		t.Errorf("CloseContext should close the driver (%v)", err)
+	}
+}
+
+func TestControlWithoutContext(t *testing.T) {
+	useTestContext(t, &audio.NewContextOptions{SampleRate: 48000})
+	if err := audio.CloseContext(); err != nil {
+		t.Fatal(err)
+	}
+	if audio.Suspend() == nil || audio.Resume() == nil || audio.Err() == nil {
+		t.Error("controlling the output without a context should return an error")
+	}
+}
@@ -1,269 +1,214 @@
-// Copyright 2021 The Oto Authors
-// Copyright 2025 Lundis
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//     http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-
 //go:build !android && !darwin && !js && !windows && !nintendosdk && !playstation5
 
 package audio
 
-// #cgo pkg-config: alsa
-//
-// #include <alsa/asoundlib.h>
-import "C"
-
 import (
-	"fmt"
-	"strings"
 	"sync"
-	"unsafe"
-)
-
-type context struct {
-	suspended bool
+	"time"
 
-	handle *C.snd_pcm_t
-
-	cond *sync.Cond
+	"github.com/Lundis/go-gameaudio/audio/internal/pulse"
+)
 
-	err atomicError
+// unixBackend is implemented by pulseContext and alsaContext.
+type unixBackend interface {
+	OutputReporter
 
-	ready chan struct{}
-}
+	Suspend() error
+	Resume() error
+	Err() error
+	Close() error
 
-func alsaError(name string, err C.int) error {
-	return fmt.Errorf("oto: ALSA error at %s: %s", name, C.GoString(C.snd_strerror(err)))
+	// done is closed when the backend stops playing because of an error.
+	done() <-chan struct{}
 }
 
-func deviceCandidates() []string {
-	const getAllDevices = -1
-
-	cPCMInterfaceName := C.CString("pcm")
-	defer C.free(unsafe.Pointer(cPCMInterfaceName))
-
-	var hints *unsafe.Pointer
-	err := C.snd_device_name_hint(getAllDevices, cPCMInterfaceName, &hints)
-	if err != 0 {
-		return []string{"default", "plug:default"}
-	}
-	defer C.snd_device_name_free_hint(hints)
-
-	var devices []string
-
-	cIoHintName := C.CString("IOID")
-	defer C.free(unsafe.Pointer(cIoHintName))
-	cNameHintName := C.CString("NAME")
-	defer C.free(unsafe.Pointer(cNameHintName))
-
-	for it := hints; *it != nil; it = (*unsafe.Pointer)(unsafe.Pointer(uintptr(unsafe.Pointer(it)) + unsafe.Sizeof(uintptr(0)))) {
-		io := C.snd_device_name_get_hint(*it, cIoHintName)
-		defer func() {
-			if io != nil {
-				C.free(unsafe.Pointer(io))
-			}
-		}()
-		if C.GoString(io) == "Input" {
-			continue
-		}
-
-		name := C.snd_device_name_get_hint(*it, cNameHintName)
-		defer func() {
-			if name != nil {
-				C.free(unsafe.Pointer(name))
-			}
-		}()
-		if name == nil {
-			continue
-		}
-		goName := C.GoString(name)
-		if goName == "null" {
-			continue
-		}
-		if goName == "default" {
-			continue
-		}
-		devices = append(devices, goName)
-	}
+// unixDriver plays through PulseAudio or PipeWire if a server is running, and through ALSA otherwise.
+type unixDriver struct {
+	options DriverOptions
 
-	devices = append([]string{"default", "plug:default"}, devices...)
+	// newBackend opens the device, see newSystemBackend
+	newBackend func(options *DriverOptions) (unixBackend, error)
 
-	return devices
+	backend   unixBackend
+	suspended bool
+	closed    chan struct{}
+	stopped   chan struct{}
+	m         sync.Mutex
 }
 
-func newContext(bufferSizeInBytes int) (chan struct{}, error) {
-	c := &context{
-		cond:  sync.NewCond(&sync.Mutex{}),
-		ready: make(chan struct{}),
-	}
+func newDefaultDriver() Driver {
+	return &unixDriver{newBackend: newSystemBackend}
+}
 
-	go func() {
-		defer close(c.ready)
+func (d *unixDriver) Open(options DriverOptions) (chan struct{}, error) {
+	d.options = options
+	d.closed = make(chan struct{})
+	d.stopped = make(chan struct{})
 
-		// Open a default ALSA audio device for blocking stream playback
-		type openError struct {
-			device string
-			err    C.int
-		}
-		var openErrs []openError
-		var found bool
+	ready := make(chan struct{})
 
-		for _, name := range deviceCandidates() {
-			cname := C.CString(name)
-			defer C.free(unsafe.Pointer(cname))
-			if err := C.snd_pcm_open(&c.handle, cname, C.SND_PCM_STREAM_PLAYBACK, 0); err < 0 {
-				openErrs = append(openErrs, openError{
-					device: name,
-					err:    err,
-				})
-				continue
-			}
-			found = true
-			break
-		}
-		if !found {
-			var msgs []string
-			for _, e := range openErrs {
-				msgs = append(msgs, fmt.Sprintf("%q: %s", e.device, C.GoString(C.snd_strerror(e.err))))
+	go func() {
+		defer close(d.stopped)
+		if err := d.openBackend(); err != nil {
+			setDeviceState(DeviceStateLost, err)
+			// don't keep the game waiting, it can start playing when a device shows up
+			close(ready)
+			if !reopenWithBackoff(d.openBackend, d.closed) {
+				return
 			}
-			c.err.TryStore(fmt.Errorf("oto: ALSA error at snd_pcm_open: %s", strings.Join(msgs, ", ")))
-			return
-		}
-
-		// TODO: Should snd_pcm_hw_params_set_periods be called explicitly?
-		const periods = 2
-		var periodSize C.snd_pcm_uframes_t
-		if bufferSizeInBytes != 0 {
-			periodSize = C.snd_pcm_uframes_t(bufferSizeInBytes / (ChannelCount * 4 * periods))
 		} else {
-			periodSize = C.snd_pcm_uframes_t(1024)
-		}
-		bufferSize := periodSize * periods
-		if err := c.alsaPcmHwParams(mux.sampleRate, ChannelCount, &bufferSize, &periodSize); err != nil {
-			c.err.TryStore(err)
-			return
+			close(ready)
 		}
-
-		go func() {
-			buf32 := make([]float32, int(periodSize)*ChannelCount)
-			for {
-				if !c.readAndWrite(buf32) {
-					return
-				}
-			}
-		}()
+		d.supervise()
 	}()
 
-	return c.ready, nil
+	return ready, nil
 }
 
-func (c *context) alsaPcmHwParams(sampleRate, channelCount int, bufferSize, periodSize *C.snd_pcm_uframes_t) error {
-	var params *C.snd_pcm_hw_params_t
-	C.snd_pcm_hw_params_malloc(&params)
-	defer C.free(unsafe.Pointer(params))
-
-	if err := C.snd_pcm_hw_params_any(c.handle, params); err < 0 {
-		return alsaError("snd_pcm_hw_params_any", err)
-	}
-	if err := C.snd_pcm_hw_params_set_access(c.handle, params, C.SND_PCM_ACCESS_RW_INTERLEAVED); err < 0 {
-		return alsaError("snd_pcm_hw_params_set_access", err)
-	}
-	if err := C.snd_pcm_hw_params_set_format(c.handle, params, C.SND_PCM_FORMAT_FLOAT_LE); err < 0 {
-		return alsaError("snd_pcm_hw_params_set_format", err)
-	}
-	if err := C.snd_pcm_hw_params_set_channels(c.handle, params, C.unsigned(channelCount)); err < 0 {
-		return alsaError("snd_pcm_hw_params_set_channels", err)
-	}
-	if err := C.snd_pcm_hw_params_set_rate_resample(c.handle, params, 1); err < 0 {
-		return alsaError("snd_pcm_hw_params_set_rate_resample", err)
-	}
-	sr := C.unsigned(sampleRate)
-	if err := C.snd_pcm_hw_params_set_rate_near(c.handle, params, &sr, nil); err < 0 {
-		return alsaError("snd_pcm_hw_params_set_rate_near", err)
+func (d *unixDriver) openBackend() error {
+	backend, err := d.newBackend(&d.options)
+	if err != nil && d.options.Device != "" {
+		// the configured device might have been unplugged since it was chosen
+		options := d.options
+		options.Device = ""
+		backend, err = d.newBackend(&options)
 	}
-	if err := C.snd_pcm_hw_params_set_buffer_size_near(c.handle, params, bufferSize); err < 0 {
-		return alsaError("snd_pcm_hw_params_set_buffer_size_near", err)
+	if err != nil {
+		return err
 	}
-	if err := C.snd_pcm_hw_params_set_period_size_near(c.handle, params, periodSize, nil); err < 0 {
-		return alsaError("snd_pcm_hw_params_set_period_size_near", err)
-	}
-	if err := C.snd_pcm_hw_params(c.handle, params); err < 0 {
-		return alsaError("snd_pcm_hw_params", err)
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.suspended {
+		if err := backend.Suspend(); err != nil {
+			_ = backend.Close()
+			return err
+		}
 	}
+	d.backend = backend
 	return nil
 }
 
-func (c *context) readAndWrite(buf32 []float32) bool {
-	c.cond.L.Lock()
-	defer c.cond.L.Unlock()
-
-	for c.suspended && c.err.Load() == nil {
-		c.cond.Wait()
+// newSystemBackend opens the output device.
+func newSystemBackend(options *DriverOptions) (unixBackend, error) {
+	// On modern desktops ALSA is routed through the pulse or pipewire plugin anyway,
+	// so talking to the server directly saves latency and shows our name in the system mixer.
+	if pc, err := newPulseContext(options); err == nil {
+		return pc, nil
 	}
-	if c.err.Load() != nil {
-		return false
+	ac := newALSAContext(options)
+	<-ac.ready
+	if err := ac.Err(); err != nil {
+		_ = ac.Close()
+		return nil, err
 	}
+	return ac, nil
+}
 
-	mux.ReadFloat32s(buf32)
-
-	for len(buf32) > 0 {
-		n := C.snd_pcm_writei(c.handle, unsafe.Pointer(&buf32[0]), C.snd_pcm_uframes_t(len(buf32)/ChannelCount))
-		if n < 0 {
-			n = C.long(C.snd_pcm_recover(c.handle, C.int(n), 1))
+// supervise reopens the device whenever the backend fails, e.g. when a USB headset is unplugged.
+// The voices live in the mux, so they continue where they were once the new device pulls from it.
+func (d *unixDriver) supervise() {
+	for {
+		d.m.Lock()
+		backend := d.backend
+		d.m.Unlock()
+
+		select {
+		case <-backend.done():
+		case <-d.closed:
+			return
 		}
-		if n < 0 {
-			c.err.TryStore(alsaError("snd_pcm_writei or snd_pcm_recover", C.int(n)))
-			return false
+		setDeviceState(DeviceStateLost, backend.Err())
+		d.m.Lock()
+		d.backend = nil
+		d.m.Unlock()
+		_ = backend.Close()
+		if !reopenWithBackoff(d.openBackend, d.closed) {
+			return
 		}
-		buf32 = buf32[int(n)*ChannelCount:]
 	}
-	return true
 }
 
-func (c *context) Suspend() error {
-	<-c.ready
-
-	c.cond.L.Lock()
-	defer c.cond.L.Unlock()
+func (d *unixDriver) Suspend() error {
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.suspended = true
+	if d.backend == nil {
+		return nil
+	}
+	return d.backend.Suspend()
+}
 
-	if err := c.err.Load(); err != nil {
-		return err.(error)
+func (d *unixDriver) Resume() error {
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.suspended = false
+	if d.backend == nil {
+		return nil
 	}
+	return d.backend.Resume()
+}
 
-	c.suspended = true
+func (d *unixDriver) Close() error {
+	close(d.closed)
+	<-d.stopped
 
-	// Do not use snd_pcm_pause as not all devices support this.
-	// Do not use snd_pcm_drop as this might hang (https://github.com/libsdl-org/SDL/blob/a5c610b0a3857d3138f3f3da1f6dc3172c5ea4a8/src/audio/alsa/SDL_alsa_audio.c#L478).
-	return nil
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.backend == nil {
+		return nil
+	}
+	err := d.backend.Close()
+	d.backend = nil
+	return err
 }
 
-func (c *context) Resume() error {
-	<-c.ready
-
-	c.cond.L.Lock()
-	defer c.cond.L.Unlock()
+// Err returns nil even while the device is lost, as it is being reopened. See CurrentDeviceState.
+func (d *unixDriver) Err() error {
+	return nil
+}
 
-	if err := c.err.Load(); err != nil {
-		return err.(error)
+func (d *unixDriver) OutputLatency() time.Duration {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.backend == nil {
+		return 0
 	}
+	return d.backend.OutputLatency()
+}
 
-	c.suspended = false
-	c.cond.Signal()
-	return nil
+func (d *unixDriver) OutputInfo() OutputInfo {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.backend == nil {
+		return OutputInfo{}
+	}
+	return d.backend.OutputInfo()
 }
 
-func (c *context) Err() error {
-	if err := c.err.Load(); err != nil {
-		return err.(error)
+func devices() ([]Device, error) {
+	// list the devices of the same backend that unixDriver would pick
+	if client, err := pulse.Dial(""); err == nil {
+		defer client.Close()
+		sinks, err := client.Sinks()
+		if err != nil {
+			return nil, err
+		}
+		defaultSink, err := client.DefaultSink()
+		if err != nil {
+			return nil, err
+		}
+		devices := make([]Device, 0, len(sinks))
+		for _, s := range sinks {
+			devices = append(devices, Device{
+				ID:      s.Name,
+				Name:    s.Description,
+				Default: s.Name == defaultSink,
+			})
+		}
+		return devices, nil
 	}
-	return nil
+
+	devices := []Device{{ID: "default", Name: "Default", Default: true}}
+	return append(devices, alsaDevices()...), nil
 }
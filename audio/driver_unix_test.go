@@ -0,0 +1,139 @@
+//go:build !android && !darwin && !js && !windows && !nintendosdk && !playstation5
+
+package audio
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeBackend is a device that the test pulls, and unplugs by closing lost.
+type fakeBackend struct {
+	options *DriverOptions
+	lost    chan struct{}
+	err     error
+	closed  bool
+}
+
+func (b *fakeBackend) Suspend() error               { return nil }
+func (b *fakeBackend) Resume() error                { return nil }
+func (b *fakeBackend) Err() error                   { return b.err }
+func (b *fakeBackend) done() <-chan struct{}        { return b.lost }
+func (b *fakeBackend) OutputLatency() time.Duration { return 0 }
+func (b *fakeBackend) OutputInfo() OutputInfo       { return OutputInfo{} }
+
+func (b *fakeBackend) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestUnixDriverReopensLostDevice(t *testing.T) {
+	m := NewMixer(&MixerOptions{SampleRate: 8000})
+	data := make([]float32, 2*8000)
+	for i := range data {
+		data[i] = float32(i/2+1) / 8000
+	}
+	m.NewSound(data, 1, ChannelIdDefault).Play()
+
+	setDeviceState(DeviceStateOpen, nil)
+	states := make(chan DeviceState, 4)
+	deviceState.m.Lock()
+	callback := deviceState.callback
+	deviceState.callback = func(state DeviceState, err error) {
+		states <- state
+	}
+	deviceState.m.Unlock()
+	defer func() {
+		deviceState.m.Lock()
+		deviceState.callback = callback
+		deviceState.m.Unlock()
+	}()
+
+	// the device is plugged in, then missing for one attempt to reopen it, and then back
+	backends := make(chan *fakeBackend, 2)
+	attempts := 0
+	d := &unixDriver{newBackend: func(options *DriverOptions) (unixBackend, error) {
+		attempts++
+		if attempts == 2 {
+			return nil, errors.New("no device")
+		}
+		b := &fakeBackend{options: options, lost: make(chan struct{})}
+		backends <- b
+		return b, nil
+	}}
+	ready, err := d.Open(DriverOptions{SampleRate: 8000, ChannelCount: 2, ReadFloat32s: m.ReadFloat32s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-ready
+	defer d.Close()
+
+	first := <-backends
+	buf := make([]float32, 2*100)
+	first.options.ReadFloat32s(buf)
+	first.err = errors.New("unplugged")
+	close(first.lost)
+
+	for _, want := range []DeviceState{DeviceStateLost, DeviceStateOpen} {
+		select {
+		case state := <-states:
+			if state != want {
+				t.Fatalf("the device should be %v but was %v", want, state)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("the device should have become %v", want)
+		}
+	}
+	if !first.closed || attempts != 3 {
+		t.Errorf("the lost device should have been closed and reopened on the second retry, but closed=%v after %d attempts", first.closed, attempts)
+	}
+
+	// the sound continues on the new device where it was
+	second := <-backends
+	second.options.ReadFloat32s(buf)
+	if want := float32(101) / 8000; buf[0] != want {
+		t.Errorf("the sound should continue with %v but got %v", want, buf[0])
+	}
+}
+
+func TestUnixDriverSelectsDevice(t *testing.T) {
+	cases := []struct {
+		name   string
+		device string
+		// opened are the devices that are tried, in order
+		opened []string
+	}{
+		{"default", "", []string{""}},
+		{"configured", "usb-headset", []string{"usb-headset"}},
+		// an unplugged device falls back to the default one
+		{"unplugged", "unplugged", []string{"unplugged", ""}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var opened []string
+			d := &unixDriver{newBackend: func(options *DriverOptions) (unixBackend, error) {
+				opened = append(opened, options.Device)
+				if options.Device == "unplugged" {
+					return nil, errors.New("no such device")
+				}
+				return &fakeBackend{options: options, lost: make(chan struct{})}, nil
+			}}
+			ready, err := d.Open(DriverOptions{SampleRate: 8000, ChannelCount: 2, Device: c.device})
+			if err != nil {
+				t.Fatal(err)
+			}
+			<-ready
+			if err := d.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(opened, c.opened) {
+				t.Errorf("should have opened %q but opened %q", c.opened, opened)
+			}
+			if d.options.Device != c.device {
+				t.Errorf("should keep %q to reopen it but has %q", c.device, d.options.Device)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -71,40 +72,54 @@ func (c *comThread) Run(f func()) {
 }
 
 type wasapiContext struct {
-	bufferSizeInBytes int
+	options *DriverOptions
 
 	comThread     *comThread
 	err           atomicError
 	suspended     bool
+	closed        bool
 	suspendedCond *sync.Cond
+	stopped       chan struct{}
+	// closing is closed by Close, to stop reopening a lost device
+	closing chan struct{}
 
 	sampleReadyEvent windows.Handle
 	client           *_IAudioClient2
 	bufferFrames     uint32
 	renderClient     *_IAudioRenderClient
 	currentDeviceID  string
+	followsDefault   bool
 	enumerator       *_IMMDeviceEnumerator
 
 	buf []float32
 
+	// streamLatency and periodFrames are set when the device is opened,
+	// queuedFrames after every write
+	streamLatency time.Duration
+	periodFrames  uint32
+	queuedFrames  atomic.Uint32
+
 	m sync.Mutex
 }
 
 var (
 	errDeviceSwitched     = errors.New("oto: device switched")
+	errWASAPIClosed       = errors.New("oto: WASAPI context is closed")
 	errFormatNotSupported = errors.New("oto: the specified format is not supported (there is the closest format instead)")
 )
 
-func newWASAPIContext(bufferSizeInBytes int) (context *wasapiContext, ferr error) {
+func newWASAPIContext(options *DriverOptions) (context *wasapiContext, ferr error) {
 	t, err := newCOMThread()
 	if err != nil {
 		return nil, err
 	}
 
 	c := &wasapiContext{
-		bufferSizeInBytes: bufferSizeInBytes,
-		comThread:         t,
-		suspendedCond:     sync.NewCond(&sync.Mutex{}),
+		options:       options,
+		comThread:     t,
+		suspendedCond: sync.NewCond(&sync.Mutex{}),
+		stopped:       make(chan struct{}),
+		closing:       make(chan struct{}),
 	}
 
 	ev, err := windows.CreateEventEx(nil, nil, 0, windows.EVENT_ALL_ACCESS)
@@ -126,8 +141,8 @@ func newWASAPIContext(bufferSizeInBytes int) (context *wasapiContext, ferr error
 }
 
 func (c *wasapiContext) isDeviceSwitched() (bool, error) {
-	// If the audio is suspended, do nothing.
-	if c.isSuspended() {
+	// If the audio is suspended or plays to a configured device, do nothing.
+	if c.isSuspended() || !c.followsDefault {
 		return false, nil
 	}
 
@@ -172,13 +187,19 @@ func (c *wasapiContext) start() error {
 		if err := c.loop(); err != nil {
 			if !errors.Is(err, _AUDCLNT_E_DEVICE_INVALIDATED) && !errors.Is(err, _AUDCLNT_E_RESOURCES_INVALIDATED) && !errors.Is(err, errDeviceSwitched) {
 				c.err.TryStore(err)
+				close(c.stopped)
 				return
 			}
 
+			if !errors.Is(err, errDeviceSwitched) {
+				setDeviceState(DeviceStateLost, err)
+			}
 			if err := c.restart(); err != nil {
 				c.err.TryStore(err)
+				close(c.stopped)
 				return
 			}
+			setDeviceState(DeviceStateOpen, nil)
 		}
 	}()
 
@@ -200,11 +221,8 @@ func (c *wasapiContext) startOnCOMThread() (ferr error) {
 		}()
 	}
 
-	device, err := c.enumerator.GetDefaultAudioEndPoint(eRender, eConsole)
+	device, err := c.openDevice()
 	if err != nil {
-		if errors.Is(err, _E_NOTFOUND) {
-			return errDeviceNotFound
-		}
 		return err
 	}
 	defer device.Release()
@@ -244,8 +262,8 @@ func (c *wasapiContext) startOnCOMThread() (ferr error) {
 	f := &_WAVEFORMATEXTENSIBLE{
 		wFormatTag:      _WAVE_FORMAT_EXTENSIBLE,
 		nChannels:       ChannelCount,
-		nSamplesPerSec:  uint32(mux.sampleRate),
-		nAvgBytesPerSec: uint32(mux.sampleRate * nBlockAlign),
+		nSamplesPerSec:  uint32(c.options.SampleRate),
+		nAvgBytesPerSec: uint32(c.options.SampleRate * nBlockAlign),
 		nBlockAlign:     uint16(nBlockAlign),
 		wBitsPerSample:  bitsPerSample,
 		cbSize:          0x16,
@@ -255,9 +273,9 @@ func (c *wasapiContext) startOnCOMThread() (ferr error) {
 	}
 
 	var bufferSizeIn100ns _REFERENCE_TIME
-	if c.bufferSizeInBytes != 0 {
-		bufferSizeInFrames := int64(c.bufferSizeInBytes) / int64(nBlockAlign)
-		bufferSizeIn100ns = _REFERENCE_TIME(1e7 * bufferSizeInFrames / int64(mux.sampleRate))
+	if c.options.BufferSizeInBytes != 0 {
+		bufferSizeInFrames := int64(c.options.BufferSizeInBytes) / int64(nBlockAlign)
+		bufferSizeIn100ns = _REFERENCE_TIME(1e7 * bufferSizeInFrames / int64(c.options.SampleRate))
 	} else {
 		// The default buffer size can be too small and might cause glitch noises.
 		// Specify 50[ms] as the buffer size.
@@ -278,6 +296,13 @@ func (c *wasapiContext) startOnCOMThread() (ferr error) {
 	}
 	c.bufferFrames = frames
 
+	if latency, err := c.client.GetStreamLatency(); err == nil {
+		c.streamLatency = time.Duration(latency) * 100
+	}
+	if period, _, err := c.client.GetDevicePeriod(); err == nil {
+		c.periodFrames = uint32(int64(period) * int64(c.options.SampleRate) / _REFTIMES_PER_SEC)
+	}
+
 	if c.renderClient != nil {
 		c.renderClient.Release()
 		c.renderClient = nil
@@ -302,6 +327,31 @@ func (c *wasapiContext) startOnCOMThread() (ferr error) {
 	return nil
 }
 
+// openDevice opens the configured device, or the default device if there is none or it is gone.
+func (c *wasapiContext) openDevice() (*_IMMDevice, error) {
+	if c.options.Device != "" {
+		device, err := c.enumerator.GetDevice(c.options.Device)
+		if err == nil {
+			state, err := device.GetState()
+			if err == nil && state == _DEVICE_STATE_ACTIVE {
+				c.followsDefault = false
+				return device, nil
+			}
+			device.Release()
+		}
+	}
+
+	device, err := c.enumerator.GetDefaultAudioEndPoint(eRender, eConsole)
+	if err != nil {
+		if errors.Is(err, _E_NOTFOUND) {
+			return nil, errDeviceNotFound
+		}
+		return nil, err
+	}
+	c.followsDefault = true
+	return device, nil
+}
+
 func (c *wasapiContext) loop() error {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
@@ -326,10 +376,14 @@ func (c *wasapiContext) loopOnRenderThread() error {
 	last := time.Now()
 	for {
 		c.suspendedCond.L.Lock()
-		for c.suspended {
+		for c.suspended && !c.closed {
 			c.suspendedCond.Wait()
 		}
+		closed := c.closed
 		c.suspendedCond.L.Unlock()
+		if closed {
+			return errWASAPIClosed
+		}
 
 		evt, err := windows.WaitForSingleObject(c.sampleReadyEvent, windows.INFINITE)
 		if err != nil {
@@ -367,6 +421,11 @@ func (c *wasapiContext) writeOnRenderThread() error {
 		return err
 	}
 
+	if paddingFrames == 0 && c.queuedFrames.Load() > 0 {
+		// everything that was queued has been played before we could refill it
+		c.options.ReportXrun()
+	}
+
 	frames := c.bufferFrames - paddingFrames
 	if frames <= 0 {
 		return nil
@@ -386,7 +445,7 @@ func (c *wasapiContext) writeOnRenderThread() error {
 	}
 
 	// Read the buffer from the sounds.
-	mux.ReadFloat32s(c.buf)
+	c.options.ReadFloat32s(c.buf)
 
 	// Copy the read buf to the destination buffer.
 	copy(unsafe.Slice((*float32)(unsafe.Pointer(dstBuf)), len(c.buf)), c.buf)
@@ -397,6 +456,7 @@ func (c *wasapiContext) writeOnRenderThread() error {
 	}
 
 	c.buf = c.buf[:0]
+	c.queuedFrames.Store(paddingFrames + frames)
 	return nil
 }
 
@@ -428,31 +488,76 @@ func (c *wasapiContext) Err() error {
 	return c.err.Load()
 }
 
+// Close stops the render loop and releases the device.
+func (c *wasapiContext) Close() error {
+	c.suspendedCond.L.Lock()
+	if !c.closed {
+		close(c.closing)
+	}
+	c.closed = true
+	c.suspendedCond.L.Unlock()
+	c.suspendedCond.Signal()
+	// wake up the render loop if it waits for the device
+	_ = windows.SetEvent(c.sampleReadyEvent)
+	<-c.stopped
+
+	c.comThread.Run(func() {
+		if c.renderClient != nil {
+			c.renderClient.Release()
+			c.renderClient = nil
+		}
+		if c.client != nil {
+			c.client.Release()
+			c.client = nil
+		}
+		if c.enumerator != nil {
+			c.enumerator.Release()
+			c.enumerator = nil
+		}
+	})
+	return windows.CloseHandle(c.sampleReadyEvent)
+}
+
+func (c *wasapiContext) OutputLatency() time.Duration {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.streamLatency + framesToDuration(int(c.queuedFrames.Load()), c.options.SampleRate)
+}
+
+func (c *wasapiContext) OutputInfo() OutputInfo {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return OutputInfo{
+		// AUTOCONVERTPCM resamples to the device's mix format
+		SampleRate: c.options.SampleRate,
+		BufferSize: int(c.bufferFrames),
+		PeriodSize: int(c.periodFrames),
+	}
+}
+
 func (c *wasapiContext) restart() error {
 	// Probably the driver is missing temporarily e.g. plugging out the headset.
 	// Recreate the device.
-
-retry:
 	c.suspendedCond.L.Lock()
-	for c.suspended {
+	for c.suspended && !c.closed {
 		c.suspendedCond.Wait()
 	}
+	closed := c.closed
 	c.suspendedCond.L.Unlock()
+	if closed {
+		return errWASAPIClosed
+	}
 
-	if err := c.start(); err != nil {
-		// When a device is switched, the new device might not support the desired format,
-		// or all the audio devices might be disconnected.
-		// Instead of aborting this context, let's wait for the next device switch.
-		if !errors.Is(err, errFormatNotSupported) && !errors.Is(err, errDeviceNotFound) {
-			return err
-		}
-
-		// Just read the buffer and discard it. Then, retry to search the device.
-		var buf32 [4096]float32
-		sleep := time.Duration(float64(time.Second) * float64(len(buf32)) / float64(ChannelCount) / float64(mux.sampleRate))
-		mux.ReadFloat32s(buf32[:])
-		time.Sleep(sleep)
-		goto retry
+	err := c.start()
+	if err == nil {
+		return nil
+	}
+	// When a device is switched, the new device might not support the desired format,
+	// or all the audio devices might be disconnected.
+	// Instead of aborting this context, keep trying like the other platforms do. The voices wait in the mixer.
+	setDeviceState(DeviceStateLost, err)
+	if !reopenWithBackoff(c.start, c.closing) {
+		return errWASAPIClosed
 	}
 	return nil
 }
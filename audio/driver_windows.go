@@ -18,70 +18,251 @@ package audio
 import (
 	"errors"
 	"fmt"
+	"runtime"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"golang.org/x/sys/windows"
 )
 
 var errDeviceNotFound = errors.New("oto: device not found")
 
-var windowsContext struct {
-	wasapiContext *wasapiContext
-	winmmContext  *winmmContext
-	nullContext   *nullContext
+// windowsBackend is implemented by wasapiContext, winmmContext and nullContext.
+type windowsBackend interface {
+	OutputReporter
+
+	Suspend() error
+	Resume() error
+	Close() error
+	Err() error
+}
+
+// windowsDriver plays through WASAPI, falling back to WinMM, or to nothing if there is no device at all.
+type windowsDriver struct {
+	options DriverOptions
+	backend windowsBackend
 
 	ready chan struct{}
 	err   atomicError
 }
 
-func newContext(bufferSizeInBytes int) (chan struct{}, error) {
-	windowsContext.ready = make(chan struct{})
+func newDefaultDriver() Driver {
+	return &windowsDriver{}
+}
+
+func (d *windowsDriver) Open(options DriverOptions) (chan struct{}, error) {
+	d.options = options
+	d.ready = make(chan struct{})
 
 	// Initializing drivers might take some time. Do this asynchronously.
 	go func() {
-		defer close(windowsContext.ready)
+		defer close(d.ready)
 
-		xc, err0 := newWASAPIContext(bufferSizeInBytes)
+		xc, err0 := newWASAPIContext(&d.options)
 		if err0 == nil {
-			windowsContext.wasapiContext = xc
+			d.backend = xc
 			return
 		}
 
-		wc, err1 := newWinMMContext(bufferSizeInBytes)
+		wc, err1 := newWinMMContext(&d.options)
 		if err1 == nil {
-			windowsContext.winmmContext = wc
+			d.backend = wc
 			return
 		}
 
 		if errors.Is(err0, errDeviceNotFound) && errors.Is(err1, errDeviceNotFound) {
-			windowsContext.nullContext = newNullContext()
+			d.backend = newNullContext(&d.options)
 			return
 		}
 
-		windowsContext.err.TryStore(fmt.Errorf("oto: initialization failed: WASAPI: %v, WinMM: %v", err0, err1))
+		d.err.TryStore(fmt.Errorf("oto: initialization failed: WASAPI: %v, WinMM: %v", err0, err1))
 	}()
 
-	return windowsContext.ready, nil
+	return d.ready, nil
+}
+
+func (d *windowsDriver) Suspend() error {
+	<-d.ready
+	if d.backend == nil {
+		return d.Err()
+	}
+	return d.backend.Suspend()
+}
+
+func (d *windowsDriver) Resume() error {
+	<-d.ready
+	if d.backend == nil {
+		return d.Err()
+	}
+	return d.backend.Resume()
+}
+
+func (d *windowsDriver) Close() error {
+	<-d.ready
+	if d.backend == nil {
+		return nil
+	}
+	return d.backend.Close()
+}
+
+func (d *windowsDriver) Err() error {
+	if err := d.err.Load(); err != nil {
+		return err
+	}
+	if d.backend == nil {
+		return nil
+	}
+	return d.backend.Err()
+}
+
+func (d *windowsDriver) OutputLatency() time.Duration {
+	<-d.ready
+	if d.backend == nil {
+		return 0
+	}
+	return d.backend.OutputLatency()
+}
+
+func (d *windowsDriver) OutputInfo() OutputInfo {
+	<-d.ready
+	if d.backend == nil {
+		return OutputInfo{}
+	}
+	return d.backend.OutputInfo()
+}
+
+func devices() ([]Device, error) {
+	var devices []Device
+	var cerr error
+	done := make(chan struct{})
+	// COM calls need a thread of their own
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		if err := windows.CoInitializeEx(0, windows.COINIT_MULTITHREADED); err != nil && !errors.Is(err, syscall.Errno(windows.S_FALSE)) {
+			cerr = err
+			return
+		}
+		defer windows.CoUninitialize()
+
+		devices, cerr = wasapiDevices()
+	}()
+	<-done
+	return devices, cerr
+}
+
+func wasapiDevices() ([]Device, error) {
+	e, err := _CoCreateInstance(&uuidMMDeviceEnumerator, nil, uint32(_CLSCTX_ALL), &uuidIMMDeviceEnumerator)
+	if err != nil {
+		return nil, err
+	}
+	enumerator := (*_IMMDeviceEnumerator)(e)
+	defer enumerator.Release()
+
+	var defaultID string
+	if device, err := enumerator.GetDefaultAudioEndPoint(eRender, eConsole); err == nil {
+		defaultID, _ = device.GetId()
+		device.Release()
+	}
+
+	collection, err := enumerator.EnumAudioEndpoints(eRender, _DEVICE_STATE_ACTIVE)
+	if err != nil {
+		return nil, err
+	}
+	defer collection.Release()
+
+	count, err := collection.GetCount()
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]Device, 0, count)
+	for i := uint32(0); i < count; i++ {
+		device, err := collection.Item(i)
+		if err != nil {
+			return nil, err
+		}
+		d, err := wasapiDevice(device)
+		device.Release()
+		if err != nil {
+			return nil, err
+		}
+		d.Default = d.ID == defaultID
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+func wasapiDevice(device *_IMMDevice) (Device, error) {
+	id, err := device.GetId()
+	if err != nil {
+		return Device{}, err
+	}
+	store, err := device.OpenPropertyStore(_STGM_READ)
+	if err != nil {
+		return Device{}, err
+	}
+	defer store.Release()
+	name, err := store.GetValue(&_PKEY_Device_FriendlyName)
+	if err != nil {
+		return Device{}, err
+	}
+	defer _PropVariantClear(name)
+	return Device{ID: id, Name: name.String()}, nil
 }
 
 type nullContext struct {
-	suspended bool
+	options *DriverOptions
+
+	suspended atomic.Bool
+	closed    atomic.Bool
 }
 
-func newNullContext() *nullContext {
-	c := &nullContext{}
+func newNullContext(options *DriverOptions) *nullContext {
+	c := &nullContext{options: options}
 	go c.loop()
 	return c
 }
 
 func (c *nullContext) loop() {
 	var buf32 [4096]float32
-	sleep := time.Duration(float64(time.Second) * float64(len(buf32)) / float64(ChannelCount) / float64(mux.sampleRate))
-	for {
-		if c.suspended {
+	sleep := time.Duration(float64(time.Second) * float64(len(buf32)) / float64(c.options.ChannelCount) / float64(c.options.SampleRate))
+	for !c.closed.Load() {
+		if c.suspended.Load() {
 			time.Sleep(time.Second)
 			continue
 		}
 
-		mux.ReadFloat32s(buf32[:])
+		c.options.ReadFloat32s(buf32[:])
 		time.Sleep(sleep)
 	}
 }
+
+func (c *nullContext) Suspend() error {
+	c.suspended.Store(true)
+	return nil
+}
+
+func (c *nullContext) Resume() error {
+	c.suspended.Store(false)
+	return nil
+}
+
+func (c *nullContext) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func (c *nullContext) OutputLatency() time.Duration {
+	return 0
+}
+
+func (c *nullContext) OutputInfo() OutputInfo {
+	return OutputInfo{SampleRate: c.options.SampleRate}
+}
+
+func (c *nullContext) Err() error {
+	return nil
+}
@@ -68,14 +68,13 @@ func (h *header) Close() error {
 }
 
 type winmmContext struct {
-	bufferSizeInBytes int
+	options *DriverOptions
 
 	waveOut uintptr
 	headers []*header
 
 	buf32 []float32
 
-	mux       *Mux
 	err       atomicError
 	loopEndCh chan error
 
@@ -87,17 +86,16 @@ type winmmContext struct {
 
 var theWinMMContext *winmmContext
 
-func newWinMMContext(bufferSizeInBytes int) (*winmmContext, error) {
+func newWinMMContext(options *DriverOptions) (*winmmContext, error) {
 	// winmm.dll is not available on Xbox.
 	if err := winmm.Load(); err != nil {
 		return nil, fmt.Errorf("oto: loading winmm.dll failed: %w", err)
 	}
 
 	c := &winmmContext{
-		bufferSizeInBytes: bufferSizeInBytes,
-		mux:               mux,
-		cond:              sync.NewCond(&sync.Mutex{}),
-		suspendedCond:     sync.NewCond(&sync.Mutex{}),
+		options:       options,
+		cond:          sync.NewCond(&sync.Mutex{}),
+		suspendedCond: sync.NewCond(&sync.Mutex{}),
 	}
 	theWinMMContext = c
 
@@ -113,8 +111,8 @@ func (c *winmmContext) start() error {
 	f := &_WAVEFORMATEX{
 		wFormatTag:      _WAVE_FORMAT_IEEE_FLOAT,
 		nChannels:       uint16(ChannelCount),
-		nSamplesPerSec:  uint32(mux.sampleRate),
-		nAvgBytesPerSec: uint32(mux.sampleRate * nBlockAlign),
+		nSamplesPerSec:  uint32(c.options.SampleRate),
+		nAvgBytesPerSec: uint32(c.options.SampleRate * nBlockAlign),
 		nBlockAlign:     uint16(nBlockAlign),
 		wBitsPerSample:  bitsPerSample,
 	}
@@ -134,8 +132,8 @@ func (c *winmmContext) start() error {
 	}
 
 	headerBufferSize := defaultHeaderBufferSize
-	if c.bufferSizeInBytes != 0 {
-		headerBufferSize = c.bufferSizeInBytes
+	if c.options.BufferSizeInBytes != 0 {
+		headerBufferSize = c.options.BufferSizeInBytes
 	}
 
 	c.waveOut = w
@@ -172,6 +170,46 @@ func (c *winmmContext) Resume() (ferr error) {
 	return nil
 }
 
+// Close stops the loop and closes the device.
+func (c *winmmContext) Close() error {
+	ch := make(chan error)
+	c.cond.L.Lock()
+	if c.err.Load() != nil {
+		// the loop has already closed the device
+		c.cond.L.Unlock()
+		return nil
+	}
+	c.loopEndCh = ch
+	c.cond.L.Unlock()
+	c.cond.Signal()
+	// the loop has to run to notice loopEndCh
+	_ = c.Resume()
+	return <-ch
+}
+
+func (c *winmmContext) OutputLatency() time.Duration {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+	var queued int
+	for _, h := range c.headers {
+		if h.IsQueued() {
+			queued += len(h.buffer) / ChannelCount
+		}
+	}
+	return framesToDuration(queued, c.options.SampleRate)
+}
+
+func (c *winmmContext) OutputInfo() OutputInfo {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+	frames := len(c.buf32) / ChannelCount
+	return OutputInfo{
+		SampleRate: c.options.SampleRate,
+		BufferSize: frames * len(c.headers),
+		PeriodSize: frames,
+	}
+}
+
 func (c *winmmContext) Err() error {
 	if err := c.err.Load(); err != nil {
 		return err.(error)
@@ -266,7 +304,7 @@ func (c *winmmContext) appendBuffers() {
 		return
 	}
 
-	c.mux.ReadFloat32s(c.buf32)
+	c.options.ReadFloat32s(c.buf32)
 
 	for _, h := range c.headers {
 		if h.IsQueued() {
@@ -278,7 +316,7 @@ func (c *winmmContext) appendBuffers() {
 			case errors.Is(err, _MMSYSERR_NOMEM):
 				continue
 			case errors.Is(err, _MMSYSERR_NODRIVER):
-				sleep := time.Duration(float64(time.Second) * float64(len(c.buf32)) / float64(ChannelCount) / float64(mux.sampleRate))
+				sleep := time.Duration(float64(time.Second) * float64(len(c.buf32)) / float64(ChannelCount) / float64(c.options.SampleRate))
 				time.Sleep(sleep)
 				return
 			case errors.Is(err, windows.ERROR_NOT_FOUND):
@@ -33,7 +33,13 @@ func NewDynamicSound(fillFunc func(buf []float32), volume float32, channel Chann
 	if mux == nil {
 		return nil
 	}
+	return mux.NewDynamicSound(fillFunc, volume, channel)
+}
+
+// NewDynamicSound creates a new DynamicSound that plays on this mixer, see the package-level NewDynamicSound.
+func (m *Mixer) NewDynamicSound(fillFunc func(buf []float32), volume float32, channel ChannelId) *DynamicSound {
 	pl := &DynamicSound{
+		mixer:     m,
 		fillFunc:  fillFunc,
 		volume:    volume,
 		channelId: channel,
@@ -42,6 +48,7 @@ func NewDynamicSound(fillFunc func(buf []float32), volume float32, channel Chann
 }
 
 type DynamicSound struct {
+	mixer     *Mixer
 	fillFunc  func(buf []float32)
 	tmp       []float32
 	channelId ChannelId
@@ -50,31 +57,47 @@ type DynamicSound struct {
 }
 
 func (ds *DynamicSound) Play() {
-	for i, existing := range dynamicSounds {
+	m := ds.mixer
+	for i, existing := range m.dynamicSounds {
 		if existing == nil {
-			dynamicSounds[i] = ds
+			m.dynamicSounds[i] = ds
 			return
 		}
 	}
-	dynamicSounds = append(dynamicSounds, ds)
+	m.dynamicSounds = append(m.dynamicSounds, ds)
 }
 
 func (ds *DynamicSound) Stop() {
-	for i, existing := range dynamicSounds {
+	m := ds.mixer
+	for i, existing := range m.dynamicSounds {
 		if ds == existing {
-			dynamicSounds[i] = nil
+			m.dynamicSounds[i] = nil
 		}
 	}
 }
 
-func (ds *DynamicSound) readBufferAndAdd(buf []float32) {
+// readBufferAndAdd mixes the next part of the sound into buf.
+// If the fill function panics or produces invalid samples, nothing is added and the error is returned.
+func (ds *DynamicSound) readBufferAndAdd(buf []float32) error {
 	if len(ds.tmp) < len(buf) {
 		ds.tmp = make([]float32, len(buf))
 	}
 	clear(ds.tmp)
-	ds.fillFunc(ds.tmp)
-	volume := ds.volume * ds.channelId.Volume()
+	if err := ds.fill(); err != nil {
+		return err
+	}
+	if err := checkSamples(ds.tmp[:len(buf)]); err != nil {
+		return err
+	}
+	volume := ds.volume * ds.mixer.ChannelVolume(ds.channelId)
 	for i := 0; i < min(len(ds.tmp), len(buf)); i++ {
 		buf[i] += volume * ds.tmp[i]
 	}
+	return nil
+}
+
+func (ds *DynamicSound) fill() (err error) {
+	defer recoverSource(&err, "DynamicSound fill function")
+	ds.fillFunc(ds.tmp)
+	return nil
 }
@@ -0,0 +1,51 @@
+package audio
+
+import (
+	"math"
+	"math/bits"
+)
+
+// fft performs an in-place radix-2 FFT on re and im.
+// len(re) must be equal to len(im) and a power of two.
+func fft(re, im []float32) {
+	n := len(re)
+	if n < 2 {
+		return
+	}
+	// bit reversal permutation
+	shift := 64 - bits.Len(uint(n-1))
+	for i := 0; i < n; i++ {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if j > i {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := -2 * math.Pi / float64(size)
+		for k := 0; k < half; k++ {
+			wr := float32(math.Cos(step * float64(k)))
+			wi := float32(math.Sin(step * float64(k)))
+			for start := 0; start < n; start += size {
+				a := start + k
+				b := a + half
+				tr := wr*re[b] - wi*im[b]
+				ti := wr*im[b] + wi*re[b]
+				re[b] = re[a] - tr
+				im[b] = im[a] - ti
+				re[a] += tr
+				im[a] += ti
+			}
+		}
+	}
+}
+
+// hannWindow returns a Hann window of length n.
+func hannWindow(n int) []float32 {
+	w := make([]float32, n)
+	for i := range w {
+		w[i] = float32(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n)))
+	}
+	return w
+}
@@ -0,0 +1,395 @@
+// Package pulse is a minimal client for the PulseAudio native protocol,
+// which is also served by PipeWire through pipewire-pulse.
+//
+// It only supports what the audio package needs: playing back a single float32 stream through a unix socket.
+package pulse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	commandError                = 0
+	commandReply                = 2
+	commandCreatePlaybackStream = 3
+	commandDeletePlaybackStream = 4
+	commandGetPlaybackLatency   = 14
+	commandAuth                 = 8
+	commandSetClientName        = 9
+	commandCorkPlaybackStream   = 41
+	commandRequest              = 61
+	commandUnderflow            = 63
+	commandPlaybackStreamKilled = 64
+)
+
+// protocolVersion is the newest protocol version this client speaks.
+// Newer servers fall back to it.
+const protocolVersion = 32
+
+const (
+	descriptorSize  = 20
+	controlChannel  = 0xFFFFFFFF
+	maxFrameSize    = 16 * 1024 * 1024
+	cookieSize      = 256
+	defaultSockName = "native"
+)
+
+var (
+	// ErrNoServer is returned by Dial when no server socket could be found.
+	ErrNoServer = errors.New("pulse: no server found")
+	// ErrClosed is returned when using a closed client.
+	ErrClosed = errors.New("pulse: connection closed")
+)
+
+var errorNames = []string{
+	"ok", "access denied", "unknown command", "invalid argument", "entity exists", "no such entity",
+	"connection refused", "protocol error", "timeout", "no authentication key", "internal error",
+	"connection terminated", "entity killed", "invalid server", "module initialization failed",
+	"bad state", "no data", "incompatible protocol version", "too large", "not supported",
+	"unknown error code", "no such extension", "obsolete functionality", "missing implementation",
+	"client forked", "input/output error", "device or resource busy",
+}
+
+// Error is an error code returned by the server.
+type Error uint32
+
+func (e Error) Error() string {
+	if int(e) < len(errorNames) {
+		return "pulse: " + errorNames[e]
+	}
+	return fmt.Sprintf("pulse: error %d", uint32(e))
+}
+
+type reply struct {
+	t   *tagstruct
+	err error
+}
+
+// Client is a connection to a PulseAudio or PipeWire server.
+type Client struct {
+	conn    net.Conn
+	version uint32
+
+	writeLock sync.Mutex
+
+	m       sync.Mutex
+	nextTag uint32
+	pending map[uint32]chan reply
+	streams map[uint32]*PlaybackStream
+	// requests for streams whose creation reply hasn't been handled yet
+	earlyRequests map[uint32]int
+	err           error
+}
+
+// ServerAddress returns the unix socket of the server of the current user,
+// honoring $PULSE_SERVER and $XDG_RUNTIME_DIR.
+func ServerAddress() (string, error) {
+	if server := os.Getenv("PULSE_SERVER"); server != "" {
+		for _, s := range strings.Fields(server) {
+			s = strings.TrimPrefix(s, "unix:")
+			if filepath.IsAbs(s) {
+				return s, nil
+			}
+		}
+		return "", fmt.Errorf("pulse: only unix sockets are supported, PULSE_SERVER is %q", server)
+	}
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "pulse", defaultSockName))
+	}
+	candidates = append(candidates,
+		filepath.Join("/run/user", fmt.Sprint(os.Getuid()), "pulse", defaultSockName),
+		filepath.Join("/var/run/pulse", defaultSockName))
+	for _, c := range candidates {
+		if fi, err := os.Stat(c); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			return c, nil
+		}
+	}
+	return "", ErrNoServer
+}
+
+// Dial connects to the server of the current user.
+// applicationName is shown in the system mixer.
+func Dial(applicationName string) (*Client, error) {
+	addr, err := ServerAddress()
+	if err != nil {
+		return nil, err
+	}
+	return DialAddr(addr, applicationName)
+}
+
+// DialAddr connects to the server listening on the given unix socket.
+func DialAddr(addr string, applicationName string) (*Client, error) {
+	conn, err := net.Dial("unix", addr)
+	if err != nil {
+		return nil, fmt.Errorf("pulse: %w", err)
+	}
+	c := &Client{
+		conn:          conn,
+		version:       protocolVersion,
+		pending:       make(map[uint32]chan reply),
+		streams:       make(map[uint32]*PlaybackStream),
+		earlyRequests: make(map[uint32]int),
+	}
+	go c.readLoop()
+
+	if err := c.handshake(applicationName); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) handshake(applicationName string) error {
+	t := c.command(commandAuth)
+	t.putU32(protocolVersion)
+	t.putArbitrary(readCookie())
+	r, err := c.request(t)
+	if err != nil {
+		return fmt.Errorf("pulse: authentication failed: %w", err)
+	}
+	serverVersion, err := r.getU32()
+	if err != nil {
+		return err
+	}
+	// the upper bits are flags for shared memory, which isn't used
+	serverVersion &= 0xFFFF
+	if serverVersion < 13 {
+		return fmt.Errorf("pulse: server protocol version %d is too old", serverVersion)
+	}
+	c.version = min(c.version, serverVersion)
+
+	if applicationName == "" {
+		applicationName = filepath.Base(os.Args[0])
+	}
+	t = c.command(commandSetClientName)
+	t.putPropList(map[string]string{
+		"application.name":           applicationName,
+		"application.process.id":     fmt.Sprint(os.Getpid()),
+		"application.process.binary": filepath.Base(os.Args[0]),
+	})
+	_, err = c.request(t)
+	return err
+}
+
+// readCookie reads the authentication cookie. Servers that don't use one accept any value.
+func readCookie() []byte {
+	var paths []string
+	if p := os.Getenv("PULSE_COOKIE"); p != "" {
+		paths = append(paths, p)
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "pulse", "cookie"))
+	}
+	if dir, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, ".pulse-cookie"))
+	}
+	for _, p := range paths {
+		if cookie, err := os.ReadFile(p); err == nil && len(cookie) == cookieSize {
+			return cookie
+		}
+	}
+	return make([]byte, cookieSize)
+}
+
+// Version returns the negotiated protocol version.
+func (c *Client) Version() uint32 {
+	return c.version
+}
+
+// Err returns the error that closed the connection, if any.
+func (c *Client) Err() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.err
+}
+
+// Close closes the connection and all its streams.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	c.fail(ErrClosed)
+	return err
+}
+
+// command starts a new command packet. The tag is filled in by request.
+func (c *Client) command(cmd uint32) *tagstruct {
+	t := &tagstruct{}
+	t.putU32(cmd)
+	t.putU32(0)
+	return t
+}
+
+// request sends the command and waits for the reply.
+func (c *Client) request(t *tagstruct) (*tagstruct, error) {
+	ch := make(chan reply, 1)
+	c.m.Lock()
+	if c.err != nil {
+		c.m.Unlock()
+		return nil, c.err
+	}
+	tag := c.nextTag
+	c.nextTag++
+	c.pending[tag] = ch
+	c.m.Unlock()
+
+	// the tag is always the second value, after the command
+	binary.BigEndian.PutUint32(t.buf[6:], tag)
+	if err := c.writePacket(controlChannel, t.buf); err != nil {
+		c.m.Lock()
+		delete(c.pending, tag)
+		c.m.Unlock()
+		return nil, err
+	}
+	r := <-ch
+	return r.t, r.err
+}
+
+func (c *Client) writePacket(channel uint32, payload []byte) error {
+	var desc [descriptorSize]byte
+	binary.BigEndian.PutUint32(desc[0:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(desc[4:], channel)
+	// offset and flags are zero: no shared memory, relative seeking
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if _, err := c.conn.Write(desc[:]); err != nil {
+		c.fail(err)
+		return err
+	}
+	if _, err := c.conn.Write(payload); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+// fail closes down the client after a fatal error, waking everyone that waits for it.
+func (c *Client) fail(err error) {
+	c.m.Lock()
+	if c.err != nil {
+		c.m.Unlock()
+		return
+	}
+	c.err = err
+	pending := c.pending
+	c.pending = make(map[uint32]chan reply)
+	streams := c.streams
+	c.m.Unlock()
+
+	_ = c.conn.Close()
+	for _, ch := range pending {
+		ch <- reply{err: err}
+	}
+	for _, s := range streams {
+		s.kill(err)
+	}
+}
+
+func (c *Client) readLoop() {
+	var desc [descriptorSize]byte
+	for {
+		if _, err := io.ReadFull(c.conn, desc[:]); err != nil {
+			c.fail(err)
+			return
+		}
+		length := binary.BigEndian.Uint32(desc[0:])
+		channel := binary.BigEndian.Uint32(desc[4:])
+		if length > maxFrameSize {
+			c.fail(errMalformed)
+			return
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.conn, payload); err != nil {
+			c.fail(err)
+			return
+		}
+		if channel != controlChannel {
+			// memory blocks are only sent for record streams
+			continue
+		}
+		if err := c.dispatch(&tagstruct{buf: payload}); err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
+
+func (c *Client) dispatch(t *tagstruct) error {
+	cmd, err := t.getU32()
+	if err != nil {
+		return err
+	}
+	tag, err := t.getU32()
+	if err != nil {
+		return err
+	}
+	switch cmd {
+	case commandReply, commandError:
+		c.m.Lock()
+		ch, ok := c.pending[tag]
+		delete(c.pending, tag)
+		c.m.Unlock()
+		if !ok {
+			return nil
+		}
+		if cmd == commandError {
+			code, err := t.getU32()
+			if err != nil {
+				return err
+			}
+			ch <- reply{err: Error(code)}
+		} else {
+			ch <- reply{t: t}
+		}
+	case commandRequest:
+		index, err := t.getU32()
+		if err != nil {
+			return err
+		}
+		bytes, err := t.getU32()
+		if err != nil {
+			return err
+		}
+		c.m.Lock()
+		s, ok := c.streams[index]
+		if !ok {
+			c.earlyRequests[index] += int(bytes)
+		}
+		c.m.Unlock()
+		if ok {
+			s.request(int(bytes))
+		}
+	case commandUnderflow:
+		index, err := t.getU32()
+		if err != nil {
+			return err
+		}
+		if s := c.stream(index); s != nil {
+			s.underflow()
+		}
+	case commandPlaybackStreamKilled:
+		index, err := t.getU32()
+		if err != nil {
+			return err
+		}
+		if s := c.stream(index); s != nil {
+			s.kill(errors.New("pulse: the stream was killed by the server"))
+		}
+	}
+	// other notifications are not needed
+	return nil
+}
+
+func (c *Client) stream(index uint32) *PlaybackStream {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.streams[index]
+}
@@ -0,0 +1,321 @@
+package pulse
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeServer implements just enough of a server to create a playback stream and receive audio.
+type fakeServer struct {
+	t        *testing.T
+	listener net.Listener
+	received chan int
+	props    chan map[string]string
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, string) {
+	addr := filepath.Join(t.TempDir(), "native")
+	l, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{
+		t:        t,
+		listener: l,
+		received: make(chan int, 100),
+		props:    make(chan map[string]string, 2),
+	}
+	go s.serve()
+	t.Cleanup(func() { _ = l.Close() })
+	return s, addr
+}
+
+func (s *fakeServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	send := func(channel uint32, payload []byte) {
+		var desc [descriptorSize]byte
+		binary.BigEndian.PutUint32(desc[0:], uint32(len(payload)))
+		binary.BigEndian.PutUint32(desc[4:], channel)
+		_, _ = conn.Write(desc[:])
+		_, _ = conn.Write(payload)
+	}
+	for {
+		var desc [descriptorSize]byte
+		if _, err := io.ReadFull(conn, desc[:]); err != nil {
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(desc[0:]))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+		if channel := binary.BigEndian.Uint32(desc[4:]); channel != controlChannel {
+			s.received <- len(payload)
+			continue
+		}
+		t := &tagstruct{buf: payload}
+		cmd, _ := t.getU32()
+		tag, _ := t.getU32()
+		r := &tagstruct{}
+		r.putU32(commandReply)
+		r.putU32(tag)
+		switch cmd {
+		case commandAuth:
+			r.putU32(35)
+		case commandSetClientName:
+			props, err := t.getPropList()
+			if err != nil {
+				s.t.Error(err)
+			}
+			s.props <- props
+			r.putU32(0)
+		case commandCreatePlaybackStream:
+			if err := s.checkCreatePlaybackStream(t); err != nil {
+				s.t.Error(err)
+			}
+			for _, v := range []uint32{7, 3, 4096, 16384, 8192, 4096, 1024} {
+				r.putU32(v)
+			}
+			send(controlChannel, r.buf)
+			// the server asks for more immediately, to check that early requests aren't lost
+			req := &tagstruct{}
+			req.putU32(commandRequest)
+			req.putU32(invalidIndex)
+			req.putU32(7)
+			req.putU32(2048)
+			send(controlChannel, req.buf)
+			continue
+		case commandGetServerInfo:
+			for _, v := range []string{"pulseaudio", "16.1", "user", "host"} {
+				r.putString(v)
+			}
+			r.putSampleSpec(sampleFloat32LE, 2, 48000)
+			r.putString("usb")
+			r.putString("mic")
+			r.putU32(0)
+			r.putChannelMap([]uint8{positionFrontLeft, positionFrontRight})
+		case commandGetPlaybackLatency:
+			// 10 ms in the sink, and 4800 bytes (600 stereo frames, 13.6 ms at 44100 Hz) in the buffer
+			r.buf = append(r.buf, tagUsec, 0, 0, 0, 0, 0, 0, 0x27, 0x10)
+			r.buf = append(r.buf, tagUsec, 0, 0, 0, 0, 0, 0, 0, 0)
+			r.putBool(true)
+			r.putTimeval(time.Now())
+			r.putTimeval(time.Now())
+			r.buf = append(r.buf, tagS64, 0, 0, 0, 0, 0, 0, 0x2e, 0xe0)
+			r.buf = append(r.buf, tagS64, 0, 0, 0, 0, 0, 0, 0x1c, 0x20)
+			r.buf = append(r.buf, tagU64, 0, 0, 0, 0, 0, 0, 0, 0)
+			r.buf = append(r.buf, tagU64, 0, 0, 0, 0, 0, 0, 0, 0)
+		case commandGetSinkInfoList:
+			putSink(r, 0, "hdmi", "HDMI Output", 0)
+			putSink(r, 1, "usb", "USB Headset", 2)
+		}
+		send(controlChannel, r.buf)
+	}
+}
+
+// putSink writes a sink the way a protocol version 32 server does.
+func putSink(r *tagstruct, index uint32, name, description string, ports int) {
+	r.putU32(index)
+	r.putString(name)
+	r.putString(description)
+	r.putSampleSpec(sampleFloat32LE, 2, 48000)
+	r.putChannelMap([]uint8{positionFrontLeft, positionFrontRight})
+	r.putU32(invalidIndex)
+	r.putCVolume([]uint32{volumeNorm, volumeNorm})
+	r.putBool(false)
+	r.putU32(index + 10)
+	r.putString(name + ".monitor")
+	r.buf = append(r.buf, tagUsec, 0, 0, 0, 0, 0, 0, 0, 100)
+	r.putString("module-alsa-card.c")
+	r.putU32(0)
+	r.putPropList(map[string]string{"device.description": description})
+	r.buf = append(r.buf, tagUsec, 0, 0, 0, 0, 0, 0, 0, 0)
+	r.buf = append(r.buf, tagVolume, 0, 1, 0, 0)
+	r.putU32(0)
+	r.putU32(65537)
+	r.putU32(index)
+	r.putU32(uint32(ports))
+	for i := 0; i < ports; i++ {
+		r.putString("port")
+		r.putString("Port")
+		r.putU32(100)
+		r.putU32(2)
+	}
+	r.putString("")
+	r.putU8(1)
+	r.buf = append(r.buf, tagFormatInfo)
+	r.putU8(1)
+	r.putPropList(nil)
+}
+
+// checkCreatePlaybackStream checks that the command has exactly the fields of protocol version 32.
+func (s *fakeServer) checkCreatePlaybackStream(t *tagstruct) error {
+	format, channels, rate, err := t.getSampleSpec()
+	if err != nil {
+		return err
+	}
+	if format != sampleFloat32LE || channels != 2 || rate != 44100 {
+		s.t.Errorf("unexpected sample spec %d %d %d", format, channels, rate)
+	}
+	// channel map, sink index, sink name, maxlength, corked, tlength, prebuf, minreq, sync id, volume,
+	// 7 flags, muted, adjust latency
+	for i := 0; i < 19; i++ {
+		if err := t.skip(); err != nil {
+			return err
+		}
+	}
+	props, err := t.getPropList()
+	if err != nil {
+		return err
+	}
+	s.props <- props
+	// volume_set, early_requests, muted_set, dont_inhibit_auto_suspend, fail_on_suspend, relative_volume, passthrough
+	for i := 0; i < 7; i++ {
+		if _, err := t.getBool(); err != nil {
+			return err
+		}
+	}
+	if n, err := t.getU8(); err != nil || n != 0 {
+		s.t.Errorf("expected no formats")
+	}
+	if t.pos != len(t.buf) {
+		s.t.Errorf("%d unexpected bytes at the end of the command", len(t.buf)-t.pos)
+	}
+	return nil
+}
+
+func TestFakeServer(t *testing.T) {
+	server, addr := newFakeServer(t)
+	c, err := DialAddr(addr, "test app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if props := <-server.props; props["application.name"] != "test app" {
+		t.Errorf("unexpected client properties %v", props)
+	}
+	if c.Version() != protocolVersion {
+		t.Errorf("the protocol version should be %d but was %d", protocolVersion, c.Version())
+	}
+
+	s, err := c.CreatePlaybackStream(StreamOptions{Name: "music", SampleRate: 44100, ChannelCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props := <-server.props; props["media.name"] != "music" {
+		t.Errorf("unexpected stream properties %v", props)
+	}
+	if s.BufferSize() != 8192 || s.MinRequest() != 1024 {
+		t.Errorf("unexpected buffer attributes %d %d", s.BufferSize(), s.MinRequest())
+	}
+
+	latency, err := s.Latency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 10*time.Millisecond + 600*time.Second/44100; latency < want || latency > want+100*time.Millisecond {
+		t.Errorf("the latency should be about %v but was %v", want, latency)
+	}
+
+	sinks, err := c.Sinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sinks) != 2 || sinks[0].Name != "hdmi" || sinks[1].Description != "USB Headset" {
+		t.Errorf("unexpected sinks %v", sinks)
+	}
+	if name, err := c.DefaultSink(); err != nil || name != "usb" {
+		t.Errorf("the default sink should be usb but was %q (%v)", name, err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		n, err := s.WaitRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 4096+2048 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a request of 6144 bytes but got %d", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Write(make([]float32, 1536)); err != nil {
+		t.Fatal(err)
+	}
+	if n := <-server.received; n != 6144 {
+		t.Errorf("the server should have received 6144 bytes but got %d", n)
+	}
+}
+
+// TestServer plays against a real PulseAudio server with a null sink, if pulseaudio is installed.
+func TestServer(t *testing.T) {
+	bin, err := exec.LookPath("pulseaudio")
+	if err != nil {
+		t.Skip("pulseaudio is not installed")
+	}
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "native")
+	cmd := exec.Command(bin, "--daemonize=no", "--exit-idle-time=-1", "--disallow-exit", "-n",
+		"--load=module-null-sink sink_name=null",
+		"--load=module-native-protocol-unix auth-anonymous=1 socket="+socket)
+	cmd.Env = append(os.Environ(), "HOME="+dir, "XDG_RUNTIME_DIR="+dir, "XDG_CONFIG_HOME="+dir)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	var c *Client
+	for i := 0; ; i++ {
+		if c, err = DialAddr(socket, "go-gameaudio test"); err == nil {
+			break
+		}
+		if i == 50 {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	defer c.Close()
+
+	sinks, err := c.Sinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sinks) != 1 || sinks[0].Name != "null" {
+		t.Errorf("expected only the null sink but got %v", sinks)
+	}
+
+	s, err := c.CreatePlaybackStream(StreamOptions{Name: "test", SampleRate: 48000, ChannelCount: 2, Sink: "null"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// play half a second of silence
+	written := 0
+	for written < 48000*2*4/2 {
+		n, err := s.WaitRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Write(make([]float32, n/4)); err != nil {
+			t.Fatal(err)
+		}
+		written += n
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
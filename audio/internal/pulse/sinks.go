@@ -0,0 +1,99 @@
+package pulse
+
+const (
+	commandGetServerInfo   = 20
+	commandGetSinkInfoList = 22
+)
+
+// Sink is an output device.
+type Sink struct {
+	// Name identifies the sink in StreamOptions.
+	Name string
+	// Description is a human-readable name.
+	Description string
+}
+
+// DefaultSink returns the name of the sink that streams play to by default.
+func (c *Client) DefaultSink() (string, error) {
+	r, err := c.request(c.command(commandGetServerInfo))
+	if err != nil {
+		return "", err
+	}
+	// package name, package version, user name, host name, sample spec
+	for i := 0; i < 5; i++ {
+		if err := r.skip(); err != nil {
+			return "", err
+		}
+	}
+	return r.getString()
+}
+
+// Sinks lists the available output devices.
+func (c *Client) Sinks() ([]Sink, error) {
+	r, err := c.request(c.command(commandGetSinkInfoList))
+	if err != nil {
+		return nil, err
+	}
+	var sinks []Sink
+	for r.pos < len(r.buf) {
+		s, err := c.readSink(r)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+func (c *Client) readSink(r *tagstruct) (s Sink, err error) {
+	skip := func(n int) {
+		for i := 0; i < n && err == nil; i++ {
+			err = r.skip()
+		}
+	}
+	skip(1) // index
+	if err != nil {
+		return
+	}
+	if s.Name, err = r.getString(); err != nil {
+		return
+	}
+	if s.Description, err = r.getString(); err != nil {
+		return
+	}
+	// sample spec, channel map, owner module, volume, mute, monitor source index and name, latency, driver, flags
+	skip(10)
+	// properties, configured latency
+	skip(2)
+	if c.version >= 15 {
+		// base volume, state, volume steps, card
+		skip(4)
+	}
+	if c.version >= 16 && err == nil {
+		var ports uint32
+		if ports, err = r.getU32(); err != nil {
+			return
+		}
+		// name, description, priority
+		fields := 3
+		if c.version >= 24 {
+			// available
+			fields++
+		}
+		if c.version >= 34 {
+			// availability group, type
+			fields += 2
+		}
+		skip(int(ports) * fields)
+		// active port
+		skip(1)
+	}
+	if c.version >= 21 && err == nil {
+		var formats uint8
+		if formats, err = r.getU8(); err != nil {
+			return
+		}
+		skip(int(formats))
+	}
+	return
+}
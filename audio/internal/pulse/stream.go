@@ -0,0 +1,268 @@
+package pulse
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// channel positions
+const (
+	positionMono       = 0
+	positionFrontLeft  = 1
+	positionFrontRight = 2
+)
+
+// StreamOptions describes a playback stream.
+type StreamOptions struct {
+	// Name is shown in the system mixer next to the application name.
+	Name string
+	// SampleRate and ChannelCount describe the float32 frames that are written.
+	SampleRate   int
+	ChannelCount int
+	// Sink is the name of the output device. If empty, the default sink is used.
+	Sink string
+	// TargetLatency is the wanted amount of buffered audio in bytes. If 0, the server decides.
+	TargetLatency int
+}
+
+// PlaybackStream plays back interleaved float32 frames.
+type PlaybackStream struct {
+	client *Client
+	index  uint32
+
+	sampleRate   int
+	channelCount int
+	bufferSize   int
+	minRequest   int
+
+	cond       *sync.Cond
+	requested  int
+	underflows int
+	err        error
+
+	packet []byte
+}
+
+// CreatePlaybackStream creates a new stream, which starts playing as soon as enough data has been written.
+func (c *Client) CreatePlaybackStream(options StreamOptions) (*PlaybackStream, error) {
+	if options.ChannelCount != 1 && options.ChannelCount != 2 {
+		return nil, fmt.Errorf("pulse: unsupported channel count %d", options.ChannelCount)
+	}
+	positions := []uint8{positionMono}
+	volumes := []uint32{volumeNorm}
+	if options.ChannelCount == 2 {
+		positions = []uint8{positionFrontLeft, positionFrontRight}
+		volumes = []uint32{volumeNorm, volumeNorm}
+	}
+	tlength := uint32(invalidIndex)
+	if options.TargetLatency > 0 {
+		tlength = uint32(options.TargetLatency)
+	}
+
+	t := c.command(commandCreatePlaybackStream)
+	t.putSampleSpec(sampleFloat32LE, uint8(options.ChannelCount), uint32(options.SampleRate))
+	t.putChannelMap(positions)
+	t.putU32(invalidIndex) // sink index
+	t.putString(options.Sink)
+	t.putU32(invalidIndex) // maxlength
+	t.putBool(false)       // corked
+	t.putU32(tlength)
+	t.putU32(invalidIndex) // prebuf
+	t.putU32(invalidIndex) // minreq
+	t.putU32(0)            // sync id
+	t.putCVolume(volumes)
+	// no_remap, no_remix, fix_format, fix_rate, fix_channels, no_move, variable_rate
+	for i := 0; i < 7; i++ {
+		t.putBool(false)
+	}
+	t.putBool(false) // muted
+	t.putBool(true)  // adjust_latency: tlength is the total latency, including the device
+	t.putPropList(map[string]string{
+		"media.name": options.Name,
+		"media.role": "game",
+	})
+	if c.version >= 14 {
+		t.putBool(false) // volume_set
+		t.putBool(true)  // early_requests
+	}
+	if c.version >= 15 {
+		t.putBool(false) // muted_set
+		t.putBool(false) // dont_inhibit_auto_suspend
+		t.putBool(false) // fail_on_suspend
+	}
+	if c.version >= 17 {
+		t.putBool(false) // relative_volume
+	}
+	if c.version >= 18 {
+		t.putBool(false) // passthrough
+	}
+	if c.version >= 21 {
+		t.putU8(0) // no formats, the sample spec is used
+	}
+
+	r, err := c.request(t)
+	if err != nil {
+		return nil, fmt.Errorf("pulse: creating playback stream failed: %w", err)
+	}
+
+	s := &PlaybackStream{
+		client:       c,
+		sampleRate:   options.SampleRate,
+		channelCount: options.ChannelCount,
+		cond:         sync.NewCond(&sync.Mutex{}),
+	}
+	var values [7]uint32
+	for i := range values {
+		if values[i], err = r.getU32(); err != nil {
+			return nil, err
+		}
+	}
+	// index, sink input, missing, maxlength, tlength, prebuf, minreq
+	s.index = values[0]
+	s.requested = int(values[2])
+	s.bufferSize = int(values[4])
+	s.minRequest = int(values[6])
+
+	c.m.Lock()
+	c.streams[s.index] = s
+	s.requested += c.earlyRequests[s.index]
+	delete(c.earlyRequests, s.index)
+	c.m.Unlock()
+	return s, nil
+}
+
+// BufferSize returns the negotiated target length of the server side buffer in bytes.
+func (s *PlaybackStream) BufferSize() int {
+	return s.bufferSize
+}
+
+// MinRequest returns the negotiated minimum request size in bytes.
+func (s *PlaybackStream) MinRequest() int {
+	return s.minRequest
+}
+
+// Underflows returns how many times the server ran out of data to play.
+func (s *PlaybackStream) Underflows() int {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+	return s.underflows
+}
+
+// WaitRequest blocks until the server wants more data, and returns how many bytes it wants.
+func (s *PlaybackStream) WaitRequest() (int, error) {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+	for s.requested <= 0 && s.err == nil {
+		s.cond.Wait()
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.requested, nil
+}
+
+// Write sends interleaved frames to the server.
+func (s *PlaybackStream) Write(buf []float32) error {
+	n := len(buf) * 4
+	if cap(s.packet) < n {
+		s.packet = make([]byte, n)
+	}
+	s.packet = s.packet[:n]
+	for i, v := range buf {
+		binary.LittleEndian.PutUint32(s.packet[i*4:], math.Float32bits(v))
+	}
+	if err := s.client.writePacket(s.index, s.packet); err != nil {
+		return err
+	}
+	s.cond.L.Lock()
+	s.requested -= n
+	s.cond.L.Unlock()
+	return nil
+}
+
+// Cork pauses (true) or resumes (false) the playback.
+func (s *PlaybackStream) Cork(cork bool) error {
+	t := s.client.command(commandCorkPlaybackStream)
+	t.putU32(s.index)
+	t.putBool(cork)
+	_, err := s.client.request(t)
+	return err
+}
+
+// Close deletes the stream on the server.
+func (s *PlaybackStream) Close() error {
+	t := s.client.command(commandDeletePlaybackStream)
+	t.putU32(s.index)
+	_, err := s.client.request(t)
+
+	s.client.m.Lock()
+	delete(s.client.streams, s.index)
+	s.client.m.Unlock()
+	s.kill(ErrClosed)
+	return err
+}
+
+// Latency asks the server how long it takes until data that is written now is played.
+func (s *PlaybackStream) Latency() (time.Duration, error) {
+	t := s.client.command(commandGetPlaybackLatency)
+	t.putU32(s.index)
+	sent := time.Now()
+	t.putTimeval(sent)
+	r, err := s.client.request(t)
+	if err != nil {
+		return 0, err
+	}
+	// the request had to travel there and the reply back
+	transport := time.Since(sent) / 2
+
+	sinkLatency, err := r.getUsec()
+	if err != nil {
+		return 0, err
+	}
+	// source latency, playing, local and remote time
+	for i := 0; i < 4; i++ {
+		if err := r.skip(); err != nil {
+			return 0, err
+		}
+	}
+	writeIndex, err := r.getS64()
+	if err != nil {
+		return 0, err
+	}
+	readIndex, err := r.getS64()
+	if err != nil {
+		return 0, err
+	}
+
+	latency := sinkLatency + transport
+	if buffered := writeIndex - readIndex; buffered > 0 {
+		frames := buffered / int64(4*s.channelCount)
+		latency += time.Duration(frames * int64(time.Second) / int64(s.sampleRate))
+	}
+	return latency, nil
+}
+
+func (s *PlaybackStream) request(bytes int) {
+	s.cond.L.Lock()
+	s.requested += bytes
+	s.cond.L.Unlock()
+	s.cond.Signal()
+}
+
+func (s *PlaybackStream) underflow() {
+	s.cond.L.Lock()
+	s.underflows++
+	s.cond.L.Unlock()
+}
+
+func (s *PlaybackStream) kill(err error) {
+	s.cond.L.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.cond.L.Unlock()
+	s.cond.Broadcast()
+}
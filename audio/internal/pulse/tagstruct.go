@@ -0,0 +1,323 @@
+package pulse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Tags of the values in a tagstruct, the serialization format of the PulseAudio native protocol.
+const (
+	tagString       = 't'
+	tagStringNull   = 'N'
+	tagU32          = 'L'
+	tagU8           = 'B'
+	tagU64          = 'R'
+	tagS64          = 'r'
+	tagSampleSpec   = 'a'
+	tagArbitrary    = 'x'
+	tagBoolTrue     = '1'
+	tagBoolFalse    = '0'
+	tagTimeval      = 'T'
+	tagUsec         = 'U'
+	tagChannelMap   = 'm'
+	tagCVolume      = 'v'
+	tagPropList     = 'P'
+	tagVolume       = 'V'
+	tagFormatInfo   = 'f'
+	invalidIndex    = 0xFFFFFFFF
+	volumeNorm      = 0x10000
+	sampleFloat32LE = 5
+)
+
+var errMalformed = errors.New("pulse: malformed packet")
+
+type tagstruct struct {
+	buf []byte
+	pos int
+}
+
+func (t *tagstruct) putU32(v uint32) {
+	t.buf = append(t.buf, tagU32)
+	t.buf = binary.BigEndian.AppendUint32(t.buf, v)
+}
+
+func (t *tagstruct) putU8(v uint8) {
+	t.buf = append(t.buf, tagU8, v)
+}
+
+func (t *tagstruct) putBool(v bool) {
+	if v {
+		t.buf = append(t.buf, tagBoolTrue)
+	} else {
+		t.buf = append(t.buf, tagBoolFalse)
+	}
+}
+
+// putString writes s, or a null string if s is empty.
+func (t *tagstruct) putString(s string) {
+	if s == "" {
+		t.buf = append(t.buf, tagStringNull)
+		return
+	}
+	t.buf = append(t.buf, tagString)
+	t.buf = append(t.buf, s...)
+	t.buf = append(t.buf, 0)
+}
+
+func (t *tagstruct) putArbitrary(b []byte) {
+	t.buf = append(t.buf, tagArbitrary)
+	t.buf = binary.BigEndian.AppendUint32(t.buf, uint32(len(b)))
+	t.buf = append(t.buf, b...)
+}
+
+func (t *tagstruct) putSampleSpec(format uint8, channels uint8, rate uint32) {
+	t.buf = append(t.buf, tagSampleSpec, format, channels)
+	t.buf = binary.BigEndian.AppendUint32(t.buf, rate)
+}
+
+func (t *tagstruct) putChannelMap(positions []uint8) {
+	t.buf = append(t.buf, tagChannelMap, uint8(len(positions)))
+	t.buf = append(t.buf, positions...)
+}
+
+func (t *tagstruct) putCVolume(volumes []uint32) {
+	t.buf = append(t.buf, tagCVolume, uint8(len(volumes)))
+	for _, v := range volumes {
+		t.buf = binary.BigEndian.AppendUint32(t.buf, v)
+	}
+}
+
+func (t *tagstruct) putTimeval(tv time.Time) {
+	t.buf = append(t.buf, tagTimeval)
+	t.buf = binary.BigEndian.AppendUint32(t.buf, uint32(tv.Unix()))
+	t.buf = binary.BigEndian.AppendUint32(t.buf, uint32(tv.Nanosecond()/1000))
+}
+
+// putPropList writes the properties sorted by key, as strings.
+func (t *tagstruct) putPropList(props map[string]string) {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	t.buf = append(t.buf, tagPropList)
+	for _, k := range keys {
+		t.putString(k)
+		value := append([]byte(props[k]), 0)
+		t.putU32(uint32(len(value)))
+		t.putArbitrary(value)
+	}
+	t.buf = append(t.buf, tagStringNull)
+}
+
+func (t *tagstruct) eof() bool {
+	return t.pos >= len(t.buf)
+}
+
+func (t *tagstruct) need(n int) error {
+	if len(t.buf)-t.pos < n {
+		return errMalformed
+	}
+	return nil
+}
+
+func (t *tagstruct) expect(tag byte) error {
+	if err := t.need(1); err != nil {
+		return err
+	}
+	if t.buf[t.pos] != tag {
+		return fmt.Errorf("pulse: expected tag %q but got %q", tag, t.buf[t.pos])
+	}
+	t.pos++
+	return nil
+}
+
+func (t *tagstruct) getU32() (uint32, error) {
+	if err := t.expect(tagU32); err != nil {
+		return 0, err
+	}
+	if err := t.need(4); err != nil {
+		return 0, err
+	}
+	v := binary.BigEndian.Uint32(t.buf[t.pos:])
+	t.pos += 4
+	return v, nil
+}
+
+func (t *tagstruct) getU8() (uint8, error) {
+	if err := t.expect(tagU8); err != nil {
+		return 0, err
+	}
+	if err := t.need(1); err != nil {
+		return 0, err
+	}
+	v := t.buf[t.pos]
+	t.pos++
+	return v, nil
+}
+
+func (t *tagstruct) get64(tag byte) (uint64, error) {
+	if err := t.expect(tag); err != nil {
+		return 0, err
+	}
+	if err := t.need(8); err != nil {
+		return 0, err
+	}
+	v := binary.BigEndian.Uint64(t.buf[t.pos:])
+	t.pos += 8
+	return v, nil
+}
+
+func (t *tagstruct) getS64() (int64, error) {
+	v, err := t.get64(tagS64)
+	return int64(v), err
+}
+
+func (t *tagstruct) getUsec() (time.Duration, error) {
+	v, err := t.get64(tagUsec)
+	return time.Duration(v) * time.Microsecond, err
+}
+
+func (t *tagstruct) getBool() (bool, error) {
+	if err := t.need(1); err != nil {
+		return false, err
+	}
+	switch t.buf[t.pos] {
+	case tagBoolTrue:
+		t.pos++
+		return true, nil
+	case tagBoolFalse:
+		t.pos++
+		return false, nil
+	}
+	return false, fmt.Errorf("pulse: expected a boolean but got %q", t.buf[t.pos])
+}
+
+// getString returns an empty string for null strings.
+func (t *tagstruct) getString() (string, error) {
+	if err := t.need(1); err != nil {
+		return "", err
+	}
+	switch t.buf[t.pos] {
+	case tagStringNull:
+		t.pos++
+		return "", nil
+	case tagString:
+		t.pos++
+		for end := t.pos; end < len(t.buf); end++ {
+			if t.buf[end] == 0 {
+				s := string(t.buf[t.pos:end])
+				t.pos = end + 1
+				return s, nil
+			}
+		}
+		return "", errMalformed
+	}
+	return "", fmt.Errorf("pulse: expected a string but got %q", t.buf[t.pos])
+}
+
+func (t *tagstruct) getArbitrary() ([]byte, error) {
+	if err := t.expect(tagArbitrary); err != nil {
+		return nil, err
+	}
+	if err := t.need(4); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint32(t.buf[t.pos:]))
+	t.pos += 4
+	if err := t.need(n); err != nil {
+		return nil, err
+	}
+	b := t.buf[t.pos : t.pos+n]
+	t.pos += n
+	return b, nil
+}
+
+func (t *tagstruct) getSampleSpec() (format, channels uint8, rate uint32, err error) {
+	if err = t.expect(tagSampleSpec); err != nil {
+		return
+	}
+	if err = t.need(6); err != nil {
+		return
+	}
+	format = t.buf[t.pos]
+	channels = t.buf[t.pos+1]
+	rate = binary.BigEndian.Uint32(t.buf[t.pos+2:])
+	t.pos += 6
+	return
+}
+
+func (t *tagstruct) getPropList() (map[string]string, error) {
+	if err := t.expect(tagPropList); err != nil {
+		return nil, err
+	}
+	props := make(map[string]string)
+	for {
+		key, err := t.getString()
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return props, nil
+		}
+		if _, err := t.getU32(); err != nil {
+			return nil, err
+		}
+		value, err := t.getArbitrary()
+		if err != nil {
+			return nil, err
+		}
+		if len(value) > 0 && value[len(value)-1] == 0 {
+			value = value[:len(value)-1]
+		}
+		props[key] = string(value)
+	}
+}
+
+// skip skips over the next value, whatever its type.
+func (t *tagstruct) skip() error {
+	if err := t.need(1); err != nil {
+		return err
+	}
+	var err error
+	switch t.buf[t.pos] {
+	case tagString, tagStringNull:
+		_, err = t.getString()
+	case tagBoolTrue, tagBoolFalse:
+		t.pos++
+	case tagU8:
+		t.pos += 2
+	case tagU32, tagVolume:
+		t.pos += 5
+	case tagU64, tagS64, tagUsec, tagTimeval:
+		t.pos += 9
+	case tagSampleSpec:
+		t.pos += 7
+	case tagArbitrary:
+		_, err = t.getArbitrary()
+	case tagChannelMap:
+		if err = t.need(2); err == nil {
+			t.pos += 2 + int(t.buf[t.pos+1])
+		}
+	case tagCVolume:
+		if err = t.need(2); err == nil {
+			t.pos += 2 + 4*int(t.buf[t.pos+1])
+		}
+	case tagPropList:
+		_, err = t.getPropList()
+	case tagFormatInfo:
+		t.pos++
+		if _, err = t.getU8(); err == nil {
+			_, err = t.getPropList()
+		}
+	default:
+		err = fmt.Errorf("pulse: unknown tag %q", t.buf[t.pos])
+	}
+	if err == nil && t.pos > len(t.buf) {
+		err = errMalformed
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"time"
+)
+
+// OutputInfo is the configuration that the driver negotiated with the output device.
+// It can differ from what was asked for in NewContextOptions.
+type OutputInfo struct {
+	// SampleRate is the rate that the mix is sent to the device at.
+	SampleRate int
+
+	// BufferSize is the number of frames that the device buffers.
+	BufferSize int
+
+	// PeriodSize is the number of frames that the driver mixes at once.
+	PeriodSize int
+}
+
+// OutputReporter can be implemented by a Driver to report the latency and configuration of its output.
+type OutputReporter interface {
+	// OutputLatency returns how long it takes until a frame that is mixed now is heard.
+	OutputLatency() time.Duration
+
+	// OutputInfo returns the negotiated configuration.
+	OutputInfo() OutputInfo
+}
+
+// OutputLatency returns how far the speakers are behind the mixer:
+// a sound that is played now is heard after this duration.
+// Use it to line up audio with visuals, e.g. in rhythm games.
+//
+// It returns 0 if the driver doesn't know its latency.
+// With NewContextOptions.MixAhead, the audio that was mixed in advance is included.
+func OutputLatency() time.Duration {
+	contextCreationMutex.Lock()
+	d := driver
+	a := ahead
+	contextCreationMutex.Unlock()
+	var latency time.Duration
+	if r, ok := d.(OutputReporter); ok {
+		latency = r.OutputLatency()
+	}
+	if a != nil {
+		latency += framesToDuration(a.buffered(), mux.sampleRate)
+	}
+	return latency
+}
+
+// CurrentOutput returns the configuration that the driver negotiated with the output device.
+//
+// It returns the zero value if the driver doesn't report it.
+func CurrentOutput() OutputInfo {
+	contextCreationMutex.Lock()
+	d := driver
+	contextCreationMutex.Unlock()
+	if r, ok := d.(OutputReporter); ok {
+		return r.OutputInfo()
+	}
+	return OutputInfo{}
+}
+
+// framesToDuration converts a number of frames at the sample rate to a duration.
+func framesToDuration(frames int, sampleRate int) time.Duration {
+	if sampleRate == 0 {
+		return 0
+	}
+	return time.Duration(int64(frames) * int64(time.Second) / int64(sampleRate))
+}
@@ -0,0 +1,103 @@
+package audio
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// mixAheadBlockFrames is the number of frames that the mix-ahead goroutine mixes at once.
+const mixAheadBlockFrames = 256
+
+// mixAhead mixes on its own goroutine into a ring buffer that the driver drains,
+// so a GC pause or a slow DynamicSound doesn't stall the driver.
+type mixAhead struct {
+	channelCount int
+	read         func(buf []float32)
+
+	// mixing is held while the mixer runs, by the goroutine or by a driver that reads more than was mixed ahead
+	mixing sync.Mutex
+
+	ring    *ringBuffer
+	drained chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// newMixAhead starts mixing up to ahead worth of audio in advance with read.
+// The ring also has room for bufferFrames, the size of the device buffer if it is known,
+// so that the driver can read a whole buffer at once and still find ahead worth of audio.
+func newMixAhead(read func(buf []float32), sampleRate int, channelCount int, ahead time.Duration, bufferFrames int) *mixAhead {
+	frames := int(int64(ahead)*int64(sampleRate)/int64(time.Second)) + bufferFrames
+	blocks := max((frames+mixAheadBlockFrames-1)/mixAheadBlockFrames, 1)
+	m := &mixAhead{
+		channelCount: channelCount,
+		read:         read,
+		ring:         newRingBuffer(blocks * mixAheadBlockFrames * channelCount),
+		drained:      make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	go m.loop()
+	return m
+}
+
+func (m *mixAhead) loop() {
+	// keep the mixer off the threads that the scheduler hands to other goroutines
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(m.stopped)
+
+	block := make([]float32, mixAheadBlockFrames*m.channelCount)
+	for {
+		if m.fill(block) {
+			continue
+		}
+		select {
+		case <-m.drained:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// fill mixes block into the ring if it has room for it, and returns whether it did.
+func (m *mixAhead) fill(block []float32) bool {
+	m.mixing.Lock()
+	defer m.mixing.Unlock()
+	if m.ring.free() < len(block) {
+		return false
+	}
+	m.read(block)
+	m.ring.write(block)
+	return true
+}
+
+// ReadFloat32s is called by the driver. It plays what was mixed ahead, and only waits for the mixer if the driver
+// reads more than that, e.g. because its buffer is bigger than the ring. The rest is then mixed right away.
+func (m *mixAhead) ReadFloat32s(buf []float32) {
+	n := m.ring.read(buf)
+	if n < len(buf) {
+		m.mixing.Lock()
+		// the goroutine may have mixed more while we waited, which comes first
+		n += m.ring.read(buf[n:])
+		if n < len(buf) {
+			m.read(buf[n:])
+		}
+		m.mixing.Unlock()
+	}
+	select {
+	case m.drained <- struct{}{}:
+	default:
+	}
+}
+
+// buffered returns the number of frames that were mixed but not yet read by the driver.
+func (m *mixAhead) buffered() int {
+	return m.ring.available() / m.channelCount
+}
+
+func (m *mixAhead) close() {
+	close(m.done)
+	<-m.stopped
+}
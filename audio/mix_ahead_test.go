@@ -0,0 +1,74 @@
+package audio_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio"
+	"github.com/Lundis/go-gameaudio/audio/audiotest"
+)
+
+func TestMixAhead(t *testing.T) {
+	d := useTestContext(t, &audio.NewContextOptions{SampleRate: 48000, MixAhead: 20 * time.Millisecond})
+	d.Latency = 20 * time.Millisecond
+
+	// the mixer fills the buffer on its own
+	deadline := time.Now().Add(time.Second)
+	for audio.OutputLatency() < 40*time.Millisecond {
+		if time.Now().After(deadline) {
+			t.Fatalf("the mixer should have mixed ahead, but the latency is %v", audio.OutputLatency())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// what was mixed before the sound started is played first, then the sound follows without a gap
+	readMixedAhead(t, d, 256)
+}
+
+func TestMixAheadLongReads(t *testing.T) {
+	// the driver reads 2048 frames at once, which is more than the 20ms that are mixed ahead
+	d := useTestContext(t, &audio.NewContextOptions{SampleRate: 48000, MixAhead: 20 * time.Millisecond})
+	readMixedAhead(t, d, 2048)
+
+	// a known buffer size makes room for a whole buffer in the ring
+	d = useTestContext(t, &audio.NewContextOptions{SampleRate: 48000, MixAhead: 20 * time.Millisecond, BufferSize: 50 * time.Millisecond})
+	deadline := time.Now().Add(time.Second)
+	for audio.OutputLatency() < 70*time.Millisecond {
+		if time.Now().After(deadline) {
+			t.Fatalf("the mixer should have mixed ahead for the buffer too, but the latency is %v", audio.OutputLatency())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	readMixedAhead(t, d, 2048)
+}
+
+// readMixedAhead plays a sound, and reads it from d in reads of frames.
+// The sound must follow the silence that was mixed before it without a gap or an xrun.
+func readMixedAhead(t *testing.T, d *audiotest.Driver, frames int) {
+	data := make([]float32, 2*48000)
+	for i := range data {
+		data[i] = 0.5
+	}
+	audio.ResetStats()
+	playing := audio.NewSound(data, 1, audio.ChannelIdDefault).Play()
+	defer playing.Stop()
+
+	var out []float32
+	buf := make([]float32, 2*frames)
+	for len(out) < len(data)/2 {
+		d.Read(buf)
+		out = append(out, buf...)
+	}
+	start := 0
+	for start < len(out) && out[start] == 0 {
+		start++
+	}
+	for i, v := range out[start:] {
+		if v != 0.5 {
+			t.Fatalf("%d frame reads: the sound should play without a gap, but sample %d after its start was %v", frames, i, v)
+		}
+	}
+	if xruns := audio.Stats().Xruns; xruns != 0 {
+		t.Errorf("%d frame reads: there should be no xruns but there were %d", frames, xruns)
+	}
+}
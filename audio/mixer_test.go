@@ -0,0 +1,69 @@
+package audio_test
+
+import (
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/audio"
+)
+
+func TestMixer(t *testing.T) {
+	t.Parallel()
+
+	m := audio.NewMixer(&audio.MixerOptions{SampleRate: 44100})
+	if m.SampleRate() != 44100 || m.ChannelCount() != audio.ChannelCount {
+		t.Errorf("unexpected format %d Hz, %d channels", m.SampleRate(), m.ChannelCount())
+	}
+	m.NewSound([]float32{0.5, 0.5, 0.5, 0.5}, 1, audio.ChannelIdSfx).Play()
+	m.NewDynamicSound(func(buf []float32) {
+		for i := range buf {
+			buf[i] = 0.25
+		}
+	}, 1, audio.ChannelIdMusic).Play()
+
+	// the mixer has its own channels
+	m.SetChannelVolume(audio.ChannelIdMusic, 0.5)
+	if audio.ChannelIdMusic.Volume() != 1 {
+		t.Error("the channels of a mixer should not change the context's channels")
+	}
+
+	buf := make([]float32, 8)
+	m.ReadFloat32s(buf)
+	want := []float32{0.625, 0.625, 0.625, 0.625, 0.125, 0.125, 0.125, 0.125}
+	for i := range want {
+		if buf[i] != want[i] {
+			t.Fatalf("the mixer should have rendered %v but got %v", want, buf)
+		}
+	}
+
+	m.ReadFloat32s(buf)
+	if s := m.Stats(); s.Callbacks != 2 || s.ActiveVoices != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestLoopRegion(t *testing.T) {
+	t.Parallel()
+
+	m := audio.NewMixer(&audio.MixerOptions{SampleRate: 44100})
+	// an intro frame, then two frames that repeat, then a frame that is never reached
+	data := []float32{1, 1, 2, 2, 3, 3, 4, 4}
+	ps := m.NewSound(data, 1, audio.ChannelIdDefault).PlayLoopRegion(1, 3)
+	buf := make([]float32, 12)
+	m.ReadFloat32s(buf)
+	want := []float32{1, 1, 2, 2, 3, 3, 2, 2, 3, 3, 2, 2}
+	for i := range want {
+		if buf[i] != want[i] {
+			t.Fatalf("should have played %v but got %v", want, buf)
+		}
+	}
+	if !ps.IsPlaying() {
+		t.Error("a loop region should keep playing")
+	}
+	ps.Stop()
+	m.ReadFloat32s(buf)
+	for _, v := range buf {
+		if v != 0 {
+			t.Fatalf("a stopped loop should be silent but got %v", buf)
+		}
+	}
+}
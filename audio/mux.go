@@ -17,26 +17,102 @@ package audio
 
 import (
 	"log"
+	"sync/atomic"
+	"time"
 )
 
-// Mux is a low-level multiplexer of audio sounds.
-type Mux struct {
+// Mixer mixes sounds into interleaved float32 frames.
+//
+// The package-level API, like NewSound and ChannelId.SetVolume, uses the mixer of the context.
+// Create more with NewMixer, e.g. to render a preview into a buffer while the game plays.
+// Each Mixer owns its voices, channel settings and dynamic sounds.
+type Mixer struct {
 	sampleRate   int
 	channelCount int
 
-	sounds []*PlayingSound
+	sounds        []PlayingSound
+	dynamicSounds []*DynamicSound
+	// streamingSounds are registered while they play. Like analyzers, the list is replaced instead of changed,
+	// so that Play and Stop don't race with the mixer.
+	streamingSounds atomic.Pointer[[]*StreamingSound]
+	channels        *channelTable
+	analyzers       atomic.Pointer[[]*SpectrumAnalyzer]
+	onSourceError   func(err *SourceError)
+	stats           mixerStats
+
+	// buses collect the output of channels that are being analyzed
+	buses []channelBus
+}
+
+// MixerOptions represents options for NewMixer.
+type MixerOptions struct {
+	// SampleRate is the rate that the sounds of the mixer are loaded at.
+	SampleRate int
+
+	// ChannelCount is the number of interleaved channels per frame. If 0, ChannelCount is used.
+	ChannelCount int
+
+	// OnSourceError is called from a background goroutine when user code running in the mixer misbehaves,
+	// see NewContextOptions.OnSourceError.
+	OnSourceError func(err *SourceError)
+}
+
+type channelBus struct {
+	channelId ChannelId
+	buf       []float32
+	active    bool
 }
 
-var mux *Mux
+// mux is the mixer of the context. It is created by the first InitContext and kept by CloseContext,
+// so sounds and channels survive a new context.
+var mux *Mixer
 
 const soundPoolSize = 128
 
-var soundPool = make([]PlayingSound, soundPoolSize)
-var dynamicSounds []*DynamicSound
+// NewMixer creates a mixer that is independent of the context.
+// Pull it with ReadFloat32s, or pass ReadFloat32s to a Driver.
+func NewMixer(options *MixerOptions) *Mixer {
+	channelCount := options.ChannelCount
+	if channelCount == 0 {
+		channelCount = ChannelCount
+	}
+	return newMixer(options.SampleRate, channelCount, newChannelTable(), options.OnSourceError)
+}
+
+func newMixer(sampleRate int, channelCount int, channels *channelTable, onSourceError func(err *SourceError)) *Mixer {
+	return &Mixer{
+		sampleRate:    sampleRate,
+		channelCount:  channelCount,
+		sounds:        make([]PlayingSound, soundPoolSize),
+		channels:      channels,
+		onSourceError: onSourceError,
+	}
+}
 
-func getFreePlayingSound(s *Sound) (ps *PlayingSound) {
-	for i := 0; i < soundPoolSize; i++ {
-		ps2 := &soundPool[i]
+func initMux(sampleRate int, channelCount int, onSourceError func(err *SourceError)) {
+	if mux == nil {
+		mux = newMixer(sampleRate, channelCount, &defaultChannels, onSourceError)
+		return
+	}
+	// the driver is closed, so nothing is mixing right now
+	mux.sampleRate = sampleRate
+	mux.channelCount = channelCount
+	mux.onSourceError = onSourceError
+}
+
+// SampleRate returns the rate that the sounds of the mixer are loaded at.
+func (m *Mixer) SampleRate() int {
+	return m.sampleRate
+}
+
+// ChannelCount returns the number of interleaved channels per frame.
+func (m *Mixer) ChannelCount() int {
+	return m.channelCount
+}
+
+func (m *Mixer) getFreePlayingSound(s *Sound) (ps *PlayingSound) {
+	for i := range m.sounds {
+		ps2 := &m.sounds[i]
 		if ps2.pos >= ps2.endAt && !ps2.loop {
 			ps = ps2
 			break
@@ -45,61 +121,150 @@ func getFreePlayingSound(s *Sound) (ps *PlayingSound) {
 	if ps != nil {
 		ps.sound = s
 		ps.pos = 0
-		ps.endAt = len(s.data)
+		ps.endAt = s.length
 		ps.fadeInEndsAt = 0
-		ps.fadeOutStartsAt = len(s.data)
+		ps.fadeOutStartsAt = s.length
 		ps.seekTo = -1
 		ps.loop = false
 		ps.loopedOnce = false
+		ps.loopStart = 0
+		ps.loopEnd = 0
+		ps.cursor.reset()
+		ps.endCursor.reset()
 	} else {
+		m.stats.poolExhausted.Add(1)
 		log.Println("WARNING: sound pool is full. Throttle your SFX!")
 	}
 	return ps
 }
 
-func initMux(sampleRate int, channelCount int) {
-	mux = &Mux{
-		sampleRate:   sampleRate,
-		channelCount: channelCount,
-	}
-}
-
 // ReadFloat32s fills buf with the multiplexed data of the sounds as float32 values.
-func (m *Mux) ReadFloat32s(buf []float32) {
+func (m *Mixer) ReadFloat32s(buf []float32) {
+	start := time.Now()
+	voices := 0
 
 	clear(buf)
-	for i := 0; i < soundPoolSize; i++ {
-		ps := &soundPool[i]
+	var taps []*SpectrumAnalyzer
+	if a := m.analyzers.Load(); a != nil {
+		taps = *a
+	}
+	m.prepareBuses(taps, len(buf))
+	for i := range m.sounds {
+		ps := &m.sounds[i]
 		if ps.seekTo >= 0 {
 			ps.pos = int(ps.seekTo * float32(ps.endAt))
 			// align to frame
-			ps.pos = ps.pos - ps.pos%(mux.channelCount)
+			ps.pos = ps.pos - ps.pos%(m.channelCount)
 			ps.seekTo = -1
 		}
 		if ps.pos >= ps.endAt && !ps.loop {
 			continue
 		}
-		ps.readBufferAndAdd(buf)
+		voices++
+		ps.readBufferAndAdd(m.busFor(ps.sound.channelId, buf))
 		if ps.pos >= ps.endAt && ps.onEndCallback != nil {
-			ps.onEndCallback()
-			ps.onEndCallback = nil
+			if err := ps.callOnEnd(); err != nil {
+				m.reportSourceError(&SourceError{PlayingSound: ps, Err: err})
+			}
 		}
 	}
-	for _, ds := range dynamicSounds {
+	for i, ds := range m.dynamicSounds {
 		if ds != nil {
-			ds.readBufferAndAdd(buf)
+			voices++
+			if err := ds.readBufferAndAdd(m.busFor(ds.channelId, buf)); err != nil {
+				m.dynamicSounds[i] = nil
+				m.reportSourceError(&SourceError{DynamicSound: ds, Err: err})
+			}
+		}
+	}
+	var streams []*StreamingSound
+	if s := m.streamingSounds.Load(); s != nil {
+		streams = *s
+	}
+	for _, s := range streams {
+		if s.IsPlaying() {
+			voices++
+			s.readBufferAndAdd(m.busFor(s.channelId, buf))
+		}
+	}
+	m.flushBuses(taps, buf)
+	for _, a := range taps {
+		if a.master {
+			a.write(buf)
+		}
+	}
+	m.stats.recordMix(start, len(buf), voices)
+}
+
+// prepareBuses clears a bus for every analyzed channel.
+func (m *Mixer) prepareBuses(taps []*SpectrumAnalyzer, n int) {
+	for i := range m.buses {
+		m.buses[i].active = false
+	}
+	for _, a := range taps {
+		if a.master {
+			continue
+		}
+		bus := m.bus(a.channelId)
+		if bus == nil {
+			m.buses = append(m.buses, channelBus{channelId: a.channelId})
+			bus = &m.buses[len(m.buses)-1]
+		}
+		if cap(bus.buf) < n {
+			bus.buf = make([]float32, n)
+		}
+		bus.buf = bus.buf[:n]
+		clear(bus.buf)
+		bus.active = true
+	}
+}
+
+func (m *Mixer) bus(channelId ChannelId) *channelBus {
+	for i := range m.buses {
+		if m.buses[i].channelId == channelId {
+			return &m.buses[i]
 		}
 	}
+	return nil
+}
 
+// busFor returns the buffer that sounds on the given channel should be mixed into.
+func (m *Mixer) busFor(channelId ChannelId, buf []float32) []float32 {
+	if bus := m.bus(channelId); bus != nil && bus.active {
+		return bus.buf
+	}
+	return buf
+}
+
+// flushBuses feeds the channel analyzers and adds the buses to the final mix.
+func (m *Mixer) flushBuses(taps []*SpectrumAnalyzer, buf []float32) {
+	for _, a := range taps {
+		if !a.master {
+			a.write(m.bus(a.channelId).buf)
+		}
+	}
+	for i := range m.buses {
+		bus := &m.buses[i]
+		if !bus.active {
+			continue
+		}
+		for j, v := range bus.buf {
+			buf[j] += v
+		}
+	}
 }
 
 func (ps *PlayingSound) readBufferAndAdd(buf []float32) {
-	channelSettings := getChannelSettings(ps.sound.channelId)
+	channelSettings := ps.sound.mixer.channels.get(ps.sound.channelId)
 	if channelSettings.paused {
 		return
 	}
 
 	volumeMultiplier := ps.sound.volume * channelSettings.volume
+	if ps.loopEnd > 0 {
+		ps.readLoopRegion(buf, volumeMultiplier)
+		return
+	}
 	available := ps.endAt - ps.pos
 	if ps.loop {
 		available = len(buf)
@@ -108,14 +273,14 @@ func (ps *PlayingSound) readBufferAndAdd(buf []float32) {
 	loopAdjustment := 0
 	for i := 0; i < n; i++ {
 		di := (ps.pos + i + loopAdjustment) % ps.endAt
-		v := ps.sound.data[di] * volumeMultiplier
+		v := ps.sample(&ps.cursor, di) * volumeMultiplier
 		fadeInMultiplier := float32(1)
 		fadeOutMultiplier := float32(1)
 		if ps.loop && di == ps.fadeOutStartsAt {
 			// crossfade: seek to start
 			loopAdjustment += ps.endAt - ps.fadeOutStartsAt
 			di = (ps.pos + i + loopAdjustment) % ps.endAt
-			v = ps.sound.data[di] * volumeMultiplier
+			v = ps.sample(&ps.cursor, di) * volumeMultiplier
 			ps.loopedOnce = true
 		} else if di > ps.fadeOutStartsAt && ps.fadeOutStartsAt < ps.endAt {
 			fadeoutLength := ps.endAt - ps.fadeOutStartsAt
@@ -125,7 +290,7 @@ func (ps *PlayingSound) readBufferAndAdd(buf []float32) {
 			fadeInMultiplier = float32(di) / float32(ps.fadeInEndsAt)
 			if ps.loopedOnce {
 				// crossfade: mix in the "fadeout" value
-				endValue := ps.sound.data[di+ps.fadeOutStartsAt] * volumeMultiplier
+				endValue := ps.sample(&ps.endCursor, di+ps.fadeOutStartsAt) * volumeMultiplier
 				buf[i] += v*fadeInMultiplier + (1-fadeInMultiplier)*endValue
 
 				continue
@@ -138,3 +303,18 @@ func (ps *PlayingSound) readBufferAndAdd(buf []float32) {
 		ps.pos %= ps.endAt
 	}
 }
+
+// readLoopRegion plays the sound up to loopEnd, and then seeks back to loopStart without a crossfade.
+func (ps *PlayingSound) readLoopRegion(buf []float32, volumeMultiplier float32) {
+	for i := range buf {
+		if ps.pos >= ps.loopEnd {
+			ps.pos = ps.loopStart
+		}
+		fadeInMultiplier := float32(1)
+		if ps.pos < ps.fadeInEndsAt {
+			fadeInMultiplier = float32(ps.pos) / float32(ps.fadeInEndsAt)
+		}
+		buf[i] += ps.sample(&ps.cursor, ps.pos) * volumeMultiplier * fadeInMultiplier
+		ps.pos++
+	}
+}
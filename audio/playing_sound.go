@@ -14,6 +14,14 @@ type PlayingSound struct {
 	fadeOutStartsAt int
 	endAt           int
 	onEndCallback   func()
+
+	// loopEnd is where a loop region seeks back to loopStart, 0 if the sound doesn't loop a region
+	loopStart int
+	loopEnd   int
+
+	// the decoded blocks of an ADPCM sound, at the position and at the end of a crossfading loop
+	cursor    adpcmCursor
+	endCursor adpcmCursor
 }
 
 // OnEndCallback can be used to register a callback that will be called once when the sound has finished playing
@@ -21,6 +29,13 @@ func (ps *PlayingSound) OnEndCallback(onEndCallback func()) {
 	ps.onEndCallback = onEndCallback
 }
 
+// callOnEnd calls the OnEndCallback once, and returns an error if it panicked.
+func (ps *PlayingSound) callOnEnd() error {
+	callback := ps.onEndCallback
+	ps.onEndCallback = nil
+	return callOnEnd(callback)
+}
+
 // Seek a playing sound to a given percentage
 // if the sound already finished, this will do nothing
 func (ps *PlayingSound) Seek(percentage float32) {
@@ -29,28 +44,41 @@ func (ps *PlayingSound) Seek(percentage float32) {
 
 // Seconds returns the current position and total length of the first currently playing instance of this sound
 func (ps *PlayingSound) Seconds() (current, total float32) {
-	samplesPerSecond := float32(mux.channelCount * mux.sampleRate)
+	samplesPerSecond := float32(ps.sound.mixer.channelCount * ps.sound.mixer.sampleRate)
 	current = float32(ps.pos) / samplesPerSecond
-	total = float32(len(ps.sound.data)) / samplesPerSecond
+	total = float32(ps.sound.length) / samplesPerSecond
 	return
 }
 
 func (ps *PlayingSound) Stop() {
 	ps.endAt = ps.pos
 	ps.loop = false
+	ps.loopEnd = 0
 	ps.onEndCallback = nil
 	ps.seekTo = -1
 }
 
 func (ps *PlayingSound) StopFadeOut(fadeOut time.Duration) {
-	samplesPerSecond := float64(mux.channelCount * mux.sampleRate)
+	samplesPerSecond := float64(ps.sound.mixer.channelCount * ps.sound.mixer.sampleRate)
 	ps.endAt = min(ps.endAt, ps.pos+int(samplesPerSecond*fadeOut.Seconds()))
 	ps.loop = false
+	ps.loopEnd = 0
 	ps.onEndCallback = nil
 	ps.seekTo = -1
 	ps.fadeOutStartsAt = ps.pos
 }
 
+// sample returns sample i of the sound as float32, converting it from the format of the sound.
+func (ps *PlayingSound) sample(cursor *adpcmCursor, i int) float32 {
+	switch ps.sound.format {
+	case SampleFormatInt16:
+		return float32(ps.sound.pcm[i]) / 32767
+	case SampleFormatADPCM:
+		return cursor.sample(ps.sound.adpcm, i)
+	}
+	return ps.sound.data[i]
+}
+
 func (ps *PlayingSound) IsPlaying() bool {
 	return ps.pos < ps.endAt
 }
@@ -0,0 +1,45 @@
+package process
+
+// MonoToStereo appends src, a mono sound, to dst as stereo, with the same signal in both channels.
+func MonoToStereo(dst, src []float32) []float32 {
+	dst = grow(dst, 2*len(src))
+	for _, v := range src {
+		dst = append(dst, v, v)
+	}
+	return dst
+}
+
+// StereoToMono appends src, a stereo sound, to dst as mono, with the average of the channels.
+func StereoToMono(dst, src []float32) []float32 {
+	dst = grow(dst, len(src)/2)
+	for i := 0; i+1 < len(src); i += 2 {
+		dst = append(dst, (src[i]+src[i+1])/2)
+	}
+	return dst
+}
+
+// SwapChannels swaps two channels of each frame, e.g. 0 and 1 to swap left and right.
+func SwapChannels(data []float32, channelCount, a, b int) {
+	for i := 0; i+channelCount <= len(data); i += channelCount {
+		data[i+a], data[i+b] = data[i+b], data[i+a]
+	}
+}
+
+// MixDown appends the sum of the sounds, which all have the same channel count, to dst.
+// The result is as long as the longest of them. Loud sounds can add up to more than 1, see Normalize.
+func MixDown(dst []float32, sounds ...[]float32) []float32 {
+	length := 0
+	for _, s := range sounds {
+		length = max(length, len(s))
+	}
+	start := len(dst)
+	dst = grow(dst, length)[:start+length]
+	mix := dst[start:]
+	clear(mix)
+	for _, s := range sounds {
+		for i, v := range s {
+			mix[i] += v
+		}
+	}
+	return dst
+}
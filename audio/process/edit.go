@@ -0,0 +1,48 @@
+package process
+
+// TrimSilence returns the part of data between the first and after the last frame that has a sample louder than
+// threshold, e.g. DecibelsToGain(-60). It doesn't copy the samples. Silence is trimmed to nothing.
+func TrimSilence(data []float32, channelCount int, threshold float32) []float32 {
+	frames := len(data) / channelCount
+	loud := func(f int) bool {
+		for _, v := range data[f*channelCount : (f+1)*channelCount] {
+			if v > threshold || -v > threshold {
+				return true
+			}
+		}
+		return false
+	}
+	start := 0
+	for start < frames && !loud(start) {
+		start++
+	}
+	end := frames
+	for end > start && !loud(end-1) {
+		end--
+	}
+	return data[start*channelCount : end*channelCount]
+}
+
+// Reverse reverses the order of the frames, so that the sound plays backwards.
+func Reverse(data []float32, channelCount int) {
+	frames := len(data) / channelCount
+	for f := range frames / 2 {
+		a, b := f*channelCount, (frames-1-f)*channelCount
+		for c := range channelCount {
+			data[a+c], data[b+c] = data[b+c], data[a+c]
+		}
+	}
+}
+
+// Concat appends the sounds, which all have the same channel count, to dst one after another.
+func Concat(dst []float32, sounds ...[]float32) []float32 {
+	length := 0
+	for _, s := range sounds {
+		length += len(s)
+	}
+	dst = grow(dst, length)
+	for _, s := range sounds {
+		dst = append(dst, s...)
+	}
+	return dst
+}
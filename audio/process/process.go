@@ -0,0 +1,96 @@
+// Package process edits sounds before they are played, e.g. while a game loads them or in a build tool that
+// prepares its assets. It works on the interleaved []float32 of the loaders and audio.NewSound, and doesn't need
+// an audio context.
+//
+// Functions that keep the length of the data change it in place. Functions that change the length append their
+// result to a dst slice, like strconv.AppendInt, so that buffers can be reused; pass nil to allocate a new one.
+// Functions that take a channelCount ignore a trailing partial frame.
+package process
+
+import "math"
+
+// Gain multiplies all samples by gain.
+func Gain(data []float32, gain float32) {
+	for i := range data {
+		data[i] *= gain
+	}
+}
+
+// DecibelsToGain returns the factor of a gain in dB, e.g. 0.5 for -6 dB.
+func DecibelsToGain(db float64) float32 {
+	return float32(math.Pow(10, db/20))
+}
+
+// Peak returns the highest absolute value of the samples.
+func Peak(data []float32) float32 {
+	var peak float32
+	for _, v := range data {
+		peak = max(peak, v, -v)
+	}
+	return peak
+}
+
+// Normalize scales the samples so that their peak is at peak, e.g. 1 or DecibelsToGain(-1), and returns the gain
+// that it applied. Silence is left as it is.
+func Normalize(data []float32, peak float32) float32 {
+	current := Peak(data)
+	if current == 0 {
+		return 1
+	}
+	gain := peak / current
+	Gain(data, gain)
+	return gain
+}
+
+// FadeIn fades the first frames in linearly from silence.
+func FadeIn(data []float32, channelCount, frames int) {
+	frames = min(frames, len(data)/channelCount)
+	for f := range frames {
+		gain := float32(f) / float32(frames)
+		for c := range channelCount {
+			data[f*channelCount+c] *= gain
+		}
+	}
+}
+
+// FadeOut fades the last frames out linearly to silence.
+func FadeOut(data []float32, channelCount, frames int) {
+	total := len(data) / channelCount
+	frames = min(frames, total)
+	start := total - frames
+	for f := range frames {
+		gain := float32(frames-1-f) / float32(frames)
+		for c := range channelCount {
+			data[(start+f)*channelCount+c] *= gain
+		}
+	}
+}
+
+// RemoveDC subtracts the average of each channel from it, which recordings sometimes have from their hardware.
+// An offset wastes headroom, and clicks where the sound starts and stops.
+func RemoveDC(data []float32, channelCount int) {
+	frames := len(data) / channelCount
+	if frames == 0 {
+		return
+	}
+	for c := range channelCount {
+		var sum float64
+		for f := range frames {
+			sum += float64(data[f*channelCount+c])
+		}
+		offset := float32(sum / float64(frames))
+		for f := range frames {
+			data[f*channelCount+c] -= offset
+		}
+	}
+}
+
+// grow makes room for n more samples in dst, allocating at most once.
+func grow(dst []float32, n int) []float32 {
+	if cap(dst)-len(dst) < n {
+		grown := make([]float32, len(dst), len(dst)+n)
+		copy(grown, dst)
+		return grown
+	}
+	return dst
+}
@@ -0,0 +1,139 @@
+package process_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/audio/process"
+)
+
+func TestGain(t *testing.T) {
+	data := []float32{0.5, -0.25, 0}
+	process.Gain(data, process.DecibelsToGain(6.0206))
+	if want := []float32{1, -0.5, 0}; !almostEqual(data, want) {
+		t.Errorf("+6 dB should double the samples to %v but got %v", want, data)
+	}
+	if peak := process.Peak(data); peak != 1 {
+		t.Errorf("the peak should be 1 but is %v", peak)
+	}
+
+	data = []float32{0.1, -0.2, 0.05}
+	if gain := process.Normalize(data, 0.8); gain != 4 || !almostEqual(data, []float32{0.4, -0.8, 0.2}) {
+		t.Errorf("should have normalized with a gain of 4 but got %v and %v", gain, data)
+	}
+	silence := []float32{0, 0}
+	if gain := process.Normalize(silence, 1); gain != 1 || !slices.Equal(silence, []float32{0, 0}) {
+		t.Errorf("silence should be left as it is, but got %v and %v", gain, silence)
+	}
+}
+
+func TestFades(t *testing.T) {
+	data := []float32{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	process.FadeIn(data, 2, 2)
+	process.FadeOut(data, 2, 2)
+	if want := []float32{0, 0, 0.5, 0.5, 1, 1, 0.5, 0.5, 0, 0}; !slices.Equal(data, want) {
+		t.Errorf("should have faded to %v but got %v", want, data)
+	}
+
+	// fades that are longer than the sound fade all of it
+	data = []float32{1, 1}
+	process.FadeOut(data, 1, 100)
+	if data[1] != 0 {
+		t.Errorf("the sound should end in silence but got %v", data)
+	}
+}
+
+func TestRemoveDC(t *testing.T) {
+	data := []float32{0.6, -0.1, 0.4, -0.3, 0.5, -0.2}
+	process.RemoveDC(data, 2)
+	if want := []float32{0.1, 0.1, -0.1, -0.1, 0, 0}; !almostEqual(data, want) {
+		t.Errorf("each channel should have lost its offset to %v but got %v", want, data)
+	}
+}
+
+func TestTrimSilence(t *testing.T) {
+	data := []float32{0, 0.001, 0, 0, 0.5, 0, 0, -0.5, 0.001, 0}
+	trimmed := process.TrimSilence(data, 2, 0.01)
+	if want := []float32{0.5, 0, 0, -0.5}; !slices.Equal(trimmed, want) {
+		t.Errorf("should have trimmed to %v but got %v", want, trimmed)
+	}
+	if &trimmed[0] != &data[4] {
+		t.Error("trimming should not copy")
+	}
+	if trimmed := process.TrimSilence([]float32{0, 0, 0}, 1, 0.01); len(trimmed) != 0 {
+		t.Errorf("silence should be trimmed to nothing but got %v", trimmed)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	data := []float32{1, 2, 3, 4, 5, 6}
+	process.Reverse(data, 2)
+	if want := []float32{5, 6, 3, 4, 1, 2}; !slices.Equal(data, want) {
+		t.Errorf("should have reversed the frames to %v but got %v", want, data)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	stereo := process.MonoToStereo(nil, []float32{1, 2})
+	if want := []float32{1, 1, 2, 2}; !slices.Equal(stereo, want) {
+		t.Errorf("should have converted to %v but got %v", want, stereo)
+	}
+	mono := process.StereoToMono(nil, []float32{1, 0, 0.5, 0.5})
+	if want := []float32{0.5, 0.5}; !slices.Equal(mono, want) {
+		t.Errorf("should have converted to %v but got %v", want, mono)
+	}
+
+	data := []float32{1, 2, 3, 4}
+	process.SwapChannels(data, 2, 0, 1)
+	if want := []float32{2, 1, 4, 3}; !slices.Equal(data, want) {
+		t.Errorf("should have swapped the channels to %v but got %v", want, data)
+	}
+}
+
+func TestConcatAndMixDown(t *testing.T) {
+	joined := process.Concat([]float32{9}, []float32{1, 2}, nil, []float32{3})
+	if want := []float32{9, 1, 2, 3}; !slices.Equal(joined, want) {
+		t.Errorf("should have appended %v but got %v", want, joined)
+	}
+	mixed := process.MixDown([]float32{9}, []float32{1, 2}, []float32{0.5, 0.5, 0.5, 0.5})
+	if want := []float32{9, 1.5, 2.5, 0.5, 0.5}; !slices.Equal(mixed, want) {
+		t.Errorf("should have appended the mix %v but got %v", want, mixed)
+	}
+}
+
+func TestAllocations(t *testing.T) {
+	src := make([]float32, 2*4096)
+	for i := range src {
+		src[i] = float32(math.Sin(float64(i)))
+	}
+	data := slices.Clone(src)
+	dst := make([]float32, 0, 4*len(src))
+	allocs := testing.AllocsPerRun(10, func() {
+		process.Gain(data, 0.5)
+		process.Normalize(data, 1)
+		process.FadeIn(data, 2, 100)
+		process.FadeOut(data, 2, 100)
+		process.RemoveDC(data, 2)
+		process.Reverse(data, 2)
+		process.SwapChannels(data, 2, 0, 1)
+		process.TrimSilence(data, 2, 0.01)
+		dst = process.MonoToStereo(dst[:0], src[:len(src)/2])
+		dst = process.StereoToMono(dst[:0], src)
+		dst = process.Concat(dst[:0], src, data)
+		dst = process.MixDown(dst[:0], src, data)
+	})
+	if allocs != 0 {
+		t.Errorf("processing with room in dst should not allocate, but allocated %v times", allocs)
+	}
+
+	// appending to a full slice allocates once
+	full := make([]float32, 10)
+	if allocs := testing.AllocsPerRun(10, func() { _ = process.Concat(full, src, src) }); allocs != 1 {
+		t.Errorf("should have allocated once but allocated %v times", allocs)
+	}
+}
+
+func almostEqual(a, b []float32) bool {
+	return slices.EqualFunc(a, b, func(x, y float32) bool { return math.Abs(float64(x-y)) < 1e-4 })
+}
@@ -0,0 +1,52 @@
+package audio
+
+import "sync/atomic"
+
+// ringBuffer is a lock-free single producer, single consumer queue of samples.
+type ringBuffer struct {
+	buf      []float32
+	readPos  atomic.Uint64
+	writePos atomic.Uint64
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{
+		buf: make([]float32, size),
+	}
+}
+
+// available returns the number of samples that can be read.
+func (r *ringBuffer) available() int {
+	return int(r.writePos.Load() - r.readPos.Load())
+}
+
+// free returns the number of samples that can be written.
+func (r *ringBuffer) free() int {
+	return len(r.buf) - r.available()
+}
+
+// write appends as much of data as fits, and returns the number of samples written.
+// It must only be called by the producer.
+func (r *ringBuffer) write(data []float32) int {
+	w := r.writePos.Load()
+	n := min(len(data), len(r.buf)-int(w-r.readPos.Load()))
+	for i := 0; i < n; {
+		start := int((w + uint64(i)) % uint64(len(r.buf)))
+		i += copy(r.buf[start:], data[i:n])
+	}
+	r.writePos.Store(w + uint64(n))
+	return n
+}
+
+// read fills data with as many samples as are available, and returns the number of samples read.
+// It must only be called by the consumer.
+func (r *ringBuffer) read(data []float32) int {
+	rp := r.readPos.Load()
+	n := min(len(data), int(r.writePos.Load()-rp))
+	for i := 0; i < n; {
+		start := int((rp + uint64(i)) % uint64(len(r.buf)))
+		i += copy(data[i:n], r.buf[start:])
+	}
+	r.readPos.Store(rp + uint64(n))
+	return n
+}
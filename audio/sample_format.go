@@ -0,0 +1,194 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// SampleFormat is how a Sound keeps its samples in memory. The compact formats are converted to float32
+// by the mixer while the sound plays, so they trade a little CPU time for memory.
+type SampleFormat int
+
+const (
+	// SampleFormatFloat32 keeps the samples as they are given, which is the default.
+	SampleFormatFloat32 SampleFormat = iota
+
+	// SampleFormatInt16 quantizes the samples to 16 bits, which halves the memory of a sound.
+	// Sounds that were loaded from 16 bit files don't lose anything.
+	SampleFormatInt16
+
+	// SampleFormatADPCM compresses the samples with IMA ADPCM to 4 bits and a little, which needs about 1/7 of
+	// the memory of float32. It adds some noise, which is rarely audible in sound effects, but can be in quiet music.
+	SampleFormatADPCM
+)
+
+func (f SampleFormat) String() string {
+	switch f {
+	case SampleFormatFloat32:
+		return "float32"
+	case SampleFormatInt16:
+		return "int16"
+	case SampleFormatADPCM:
+		return "adpcm"
+	}
+	return "unknown"
+}
+
+// MarshalText returns the name of the format, so that it can be written in JSON files such as sfx.json.
+func (f SampleFormat) MarshalText() ([]byte, error) {
+	if f.String() == "unknown" {
+		return nil, fmt.Errorf("audio: unknown sample format %d", int(f))
+	}
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText parses the name that String returns.
+func (f *SampleFormat) UnmarshalText(text []byte) error {
+	for _, format := range []SampleFormat{SampleFormatFloat32, SampleFormatInt16, SampleFormatADPCM} {
+		if string(text) == format.String() {
+			*f = format
+			return nil
+		}
+	}
+	return fmt.Errorf("audio: unknown sample format %q", text)
+}
+
+func toInt16(data []float32) []int16 {
+	pcm := make([]int16, len(data))
+	for i, v := range data {
+		pcm[i] = int16(math.Round(float64(min(max(v, -1), 1)) * 32767))
+	}
+	return pcm
+}
+
+// adpcmBlockFrames is the number of frames of a block of ADPCM, which is decoded at once and without the blocks
+// before it, so that sounds can seek and loop.
+const adpcmBlockFrames = 64
+
+var adpcmIndexTable = [8]int{-1, -1, -1, -1, 2, 4, 6, 8}
+
+var adpcmStepTable = [89]int{
+	7, 8, 9, 10, 11, 12, 13, 14, 16, 17,
+	19, 21, 23, 25, 28, 31, 34, 37, 41, 45,
+	50, 55, 60, 66, 73, 80, 88, 97, 107, 118,
+	130, 143, 157, 173, 190, 209, 230, 253, 279, 307,
+	337, 371, 408, 449, 494, 544, 598, 658, 724, 796,
+	876, 963, 1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066,
+	2272, 2499, 2749, 3024, 3327, 3660, 4026, 4428, 4871, 5358,
+	5894, 6484, 7132, 7845, 8630, 9493, 10442, 11487, 12635, 13899,
+	15289, 16818, 18500, 20350, 22385, 24623, 27086, 29794, 32767,
+}
+
+// adpcmState is the predictor of one channel.
+type adpcmState struct {
+	predictor int16
+	index     uint8
+}
+
+// decode applies a nibble to the predictor and returns the new sample.
+func (s *adpcmState) decode(nibble byte) int16 {
+	step := adpcmStepTable[s.index]
+	delta := step >> 3
+	if nibble&4 != 0 {
+		delta += step
+	}
+	if nibble&2 != 0 {
+		delta += step >> 1
+	}
+	if nibble&1 != 0 {
+		delta += step >> 2
+	}
+	predictor := int(s.predictor)
+	if nibble&8 != 0 {
+		predictor -= delta
+	} else {
+		predictor += delta
+	}
+	s.predictor = int16(min(max(predictor, math.MinInt16), math.MaxInt16))
+	s.index = uint8(min(max(int(s.index)+adpcmIndexTable[nibble&7], 0), len(adpcmStepTable)-1))
+	return s.predictor
+}
+
+// encode returns the nibble that comes closest to sample, and applies it like the decoder does.
+func (s *adpcmState) encode(sample int16) byte {
+	diff := int(sample) - int(s.predictor)
+	var nibble byte
+	if diff < 0 {
+		nibble = 8
+		diff = -diff
+	}
+	step := adpcmStepTable[s.index]
+	for bit := byte(4); bit > 0; bit >>= 1 {
+		if diff >= step {
+			nibble |= bit
+			diff -= step
+		}
+		step >>= 1
+	}
+	s.decode(nibble)
+	return nibble
+}
+
+// adpcmData is a sound compressed with IMA ADPCM, in blocks that start with the state of the predictors.
+type adpcmData struct {
+	channelCount int
+	length       int
+	// states has the predictors of each channel at the start of each block
+	states []adpcmState
+	// nibbles has one nibble per sample, the first in the low bits
+	nibbles []byte
+}
+
+func encodeADPCM(data []float32, channelCount int) *adpcmData {
+	a := &adpcmData{
+		channelCount: channelCount,
+		length:       len(data),
+		nibbles:      make([]byte, (len(data)+1)/2),
+	}
+	pcm := toInt16(data)
+	encoders := make([]adpcmState, channelCount)
+	blockSamples := adpcmBlockFrames * channelCount
+	for i, v := range pcm {
+		if i%blockSamples == 0 {
+			a.states = append(a.states, encoders...)
+		}
+		a.nibbles[i/2] |= encoders[i%channelCount].encode(v) << (4 * (i % 2))
+	}
+	return a
+}
+
+// decodeBlock decodes a block into out, which it grows if needed, using states for the predictors.
+func (a *adpcmData) decodeBlock(block int, out []float32, states []adpcmState) ([]float32, []adpcmState) {
+	blockSamples := adpcmBlockFrames * a.channelCount
+	start := block * blockSamples
+	if cap(out) < blockSamples {
+		out = make([]float32, blockSamples)
+	}
+	out = out[:min(blockSamples, a.length-start)]
+	states = append(states[:0], a.states[block*a.channelCount:(block+1)*a.channelCount]...)
+	for i := range out {
+		nibble := a.nibbles[(start+i)/2] >> (4 * ((start + i) % 2)) & 0xF
+		out[i] = float32(states[i%a.channelCount].decode(nibble)) / 32767
+	}
+	return out, states
+}
+
+// adpcmCursor keeps the block of an ADPCM sound that a PlayingSound is at, so that every block is decoded once.
+type adpcmCursor struct {
+	block   int
+	samples []float32
+	states  []adpcmState
+}
+
+func (c *adpcmCursor) reset() {
+	c.block = -1
+}
+
+func (c *adpcmCursor) sample(a *adpcmData, i int) float32 {
+	block := i / (adpcmBlockFrames * a.channelCount)
+	if block != c.block {
+		c.samples, c.states = a.decodeBlock(block, c.samples, c.states)
+		c.block = block
+	}
+	return c.samples[i-block*adpcmBlockFrames*a.channelCount]
+}
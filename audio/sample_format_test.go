@@ -0,0 +1,104 @@
+package audio_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio"
+)
+
+// stereoTone returns a second of a sine at freq in both channels.
+func stereoTone(freq float64, amplitude float32) []float32 {
+	data := make([]float32, 2*44100)
+	for i := range data {
+		data[i] = amplitude * float32(math.Sin(2*math.Pi*freq*float64(i/2)/44100))
+	}
+	return data
+}
+
+// signalToNoise returns the ratio of want to the difference of got from it in dB.
+func signalToNoise(want, got []float32) float64 {
+	var signal, noise float64
+	for i := range want {
+		signal += float64(want[i]) * float64(want[i])
+		noise += float64(got[i]-want[i]) * float64(got[i]-want[i])
+	}
+	return 10 * math.Log10(signal/(noise+1e-20))
+}
+
+func TestSampleFormats(t *testing.T) {
+	t.Parallel()
+
+	data := stereoTone(440, 0.5)
+	for _, format := range []struct {
+		format audio.SampleFormat
+		snr    float64
+	}{
+		{audio.SampleFormatFloat32, 150},
+		{audio.SampleFormatInt16, 80},
+		{audio.SampleFormatADPCM, 35},
+	} {
+		t.Run(format.format.String(), func(t *testing.T) {
+			m := audio.NewMixer(&audio.MixerOptions{SampleRate: 44100})
+			s := m.NewSoundWithFormat(data, 1, audio.ChannelIdSfx, format.format)
+			if s.Format() != format.format {
+				t.Errorf("the sound should be %v but is %v", format.format, s.Format())
+			}
+			ps := s.Play()
+			// reads that don't line up with the blocks of ADPCM
+			buf := make([]float32, len(data))
+			for i := 0; i < len(buf); i += 1000 {
+				m.ReadFloat32s(buf[i:min(i+1000, len(buf))])
+			}
+			if snr := signalToNoise(data, buf); snr < format.snr {
+				t.Errorf("the signal to noise ratio should be at least %.0f dB but is %.1f dB", format.snr, snr)
+			}
+			if ps.IsPlaying() {
+				t.Error("the sound should have ended")
+			}
+
+			// seeking back decodes from the middle of the sound
+			ps = s.Play()
+			ps.Seek(0.5)
+			half := make([]float32, len(data)/2)
+			m.ReadFloat32s(half)
+			if snr := signalToNoise(data[len(data)/2:], half); snr < format.snr {
+				t.Errorf("the signal to noise ratio after a seek should be at least %.0f dB but is %.1f dB", format.snr, snr)
+			}
+		})
+	}
+}
+
+func TestSampleFormatLoop(t *testing.T) {
+	t.Parallel()
+
+	data := stereoTone(440, 0.5)
+	render := func(format audio.SampleFormat) []float32 {
+		m := audio.NewMixer(&audio.MixerOptions{SampleRate: 44100})
+		m.NewSoundWithFormat(data, 1, audio.ChannelIdSfx, format).PlayLoop(100 * time.Millisecond)
+		buf := make([]float32, 3*len(data))
+		m.ReadFloat32s(buf)
+		return buf
+	}
+	want := render(audio.SampleFormatFloat32)
+	// the crossfade reads the start and the end of the sound at the same time
+	if snr := signalToNoise(want, render(audio.SampleFormatADPCM)); snr < 35 {
+		t.Errorf("a crossfading loop of ADPCM should have a signal to noise ratio of at least 35 dB but has %.1f dB", snr)
+	}
+}
+
+func TestSampleFormatJSON(t *testing.T) {
+	var entry struct{ Format audio.SampleFormat }
+	if err := json.Unmarshal([]byte(`{"Format": "adpcm"}`), &entry); err != nil || entry.Format != audio.SampleFormatADPCM {
+		t.Errorf("should have parsed adpcm but got %v, %v", entry.Format, err)
+	}
+	if err := json.Unmarshal([]byte(`{"Format": "mp3"}`), &entry); err == nil {
+		t.Error("an unknown format should be an error")
+	}
+	out, err := json.Marshal(struct{ Format audio.SampleFormat }{audio.SampleFormatInt16})
+	if err != nil || string(out) != `{"Format":"int16"}` {
+		t.Errorf("should have written int16 but got %s, %v", out, err)
+	}
+}
@@ -34,43 +34,105 @@ func NewSound(data []float32, volume float32, channel ChannelId) *Sound {
 	if mux == nil {
 		return nil
 	}
+	return mux.NewSound(data, volume, channel)
+}
+
+// NewSoundWithFormat is like NewSound, but keeps the samples in memory in the given format,
+// e.g. SampleFormatADPCM for the many short sound effects of a game. data is not kept unless the format is float32.
+func NewSoundWithFormat(data []float32, volume float32, channel ChannelId, format SampleFormat) *Sound {
+	if mux == nil {
+		return nil
+	}
+	return mux.NewSoundWithFormat(data, volume, channel, format)
+}
+
+// NewSound creates a new Sound that plays on this mixer, see the package-level NewSound.
+func (m *Mixer) NewSound(data []float32, volume float32, channel ChannelId) *Sound {
+	return m.NewSoundWithFormat(data, volume, channel, SampleFormatFloat32)
+}
+
+// NewSoundWithFormat creates a new Sound that plays on this mixer, see the package-level NewSoundWithFormat.
+func (m *Mixer) NewSoundWithFormat(data []float32, volume float32, channel ChannelId, format SampleFormat) *Sound {
 	pl := &Sound{
-		data:      data,
+		mixer:     m,
+		format:    format,
+		length:    len(data),
 		volume:    volume,
 		channelId: channel,
 	}
+	switch format {
+	case SampleFormatInt16:
+		pl.pcm = toInt16(data)
+	case SampleFormatADPCM:
+		pl.adpcm = encodeADPCM(data, m.channelCount)
+	default:
+		pl.format = SampleFormatFloat32
+		pl.data = data
+	}
 	return pl
 }
 
 type Sound struct {
+	mixer *Mixer
+	// the samples are in one of data, pcm and adpcm, depending on the format
+	format    SampleFormat
 	data      []float32
+	pcm       []int16
+	adpcm     *adpcmData
+	length    int
 	channelId ChannelId
 	volume    float32
 	m         sync.Mutex
 }
 
+// Format returns how the samples of the sound are kept in memory.
+func (s *Sound) Format() SampleFormat {
+	return s.format
+}
+
 func (s *Sound) Play() *PlayingSound {
-	return getFreePlayingSound(s)
+	return s.mixer.getFreePlayingSound(s)
 }
 
 // PlayLoop starts playing this sound in an infinite loop.
 // If the sound is already playing, it will not reset it.
 // If it's playing multiple instances right now, this will cause all of them to loop.
 func (s *Sound) PlayLoop(crossFade time.Duration) *PlayingSound {
-	fadeDuration := int(float64(mux.channelCount*mux.sampleRate) * crossFade.Seconds())
-	ps := getFreePlayingSound(s)
+	channelCount := s.mixer.channelCount
+	fadeDuration := int(float64(channelCount*s.mixer.sampleRate) * crossFade.Seconds())
+	// the crossfade needs a part of the sound to loop, like StreamingSound.PlayLoop
+	fadeDuration = min(fadeDuration, s.length/channelCount/2*channelCount)
+	ps := s.mixer.getFreePlayingSound(s)
 	if ps != nil {
 		ps.loop = true
 		ps.fadeInEndsAt = fadeDuration
-		ps.fadeOutStartsAt = len(s.data) - fadeDuration
+		ps.fadeOutStartsAt = s.length - fadeDuration
+	}
+	return ps
+}
+
+// PlayLoopRegion starts playing this sound from the beginning, and then repeats the frames from start up to end
+// forever, e.g. for music with an intro. The loop is sample-accurate, without a crossfade.
+func (s *Sound) PlayLoopRegion(start, end int) *PlayingSound {
+	ps := s.mixer.getFreePlayingSound(s)
+	if ps != nil {
+		channelCount := s.mixer.channelCount
+		ps.loopEnd = min(end*channelCount, s.length)
+		ps.loopStart = min(max(start, 0)*channelCount, ps.loopEnd)
+		if ps.loopStart == ps.loopEnd {
+			// nothing to repeat
+			ps.loopEnd = 0
+			return ps
+		}
+		ps.loop = true
 	}
 	return ps
 }
 
 func (s *Sound) PlayFadeIn(fadeIn time.Duration) *PlayingSound {
 
-	fadeDuration := int(float64(mux.channelCount*mux.sampleRate) * fadeIn.Seconds())
-	ps := getFreePlayingSound(s)
+	fadeDuration := int(float64(s.mixer.channelCount*s.mixer.sampleRate) * fadeIn.Seconds())
+	ps := s.mixer.getFreePlayingSound(s)
 	if ps != nil {
 		ps.fadeInEndsAt = fadeDuration
 	}
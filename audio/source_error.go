@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxSourceValue is the largest sample that a source may produce. Anything above is treated as a runaway generator;
+// it is +24 dB, far louder than anything that would be intended.
+const maxSourceValue = 16
+
+// SourceError is reported to NewContextOptions.OnSourceError when user code running in the mixer misbehaves.
+// The offending source has been muted and removed from the mix; the other sounds keep playing.
+type SourceError struct {
+	// DynamicSound is the dynamic sound whose fill function panicked or produced invalid samples, if any.
+	DynamicSound *DynamicSound
+
+	// PlayingSound is the sound whose OnEndCallback panicked, if any.
+	PlayingSound *PlayingSound
+
+	// StreamingSound is the streaming sound whose source failed or whose OnEndCallback panicked, if any.
+	StreamingSound *StreamingSound
+
+	// Err describes what went wrong.
+	Err error
+}
+
+func (e *SourceError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// reportSourceError passes err to the OnSourceError callback of the mixer.
+// It runs on its own goroutine so that the callback can't stall or crash the mixer.
+func (m *Mixer) reportSourceError(err *SourceError) {
+	if callback := m.onSourceError; callback != nil {
+		go callback(err)
+	}
+}
+
+// recoverSource turns a panic in user code into an error.
+// It must be deferred directly.
+func recoverSource(err *error, what string) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("audio: %s panicked: %v", what, r)
+	}
+}
+
+// callOnEnd calls an OnEndCallback, and returns an error if it panicked.
+func callOnEnd(callback func()) (err error) {
+	defer recoverSource(&err, "OnEndCallback")
+	callback()
+	return nil
+}
+
+// checkSamples returns an error if buf contains NaN, infinity or runaway values.
+func checkSamples(buf []float32) error {
+	for i, v := range buf {
+		if v != v || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("audio: DynamicSound produced %v at sample %d", v, i)
+		}
+		if v > maxSourceValue || v < -maxSourceValue {
+			return fmt.Errorf("audio: DynamicSound produced the runaway value %v at sample %d", v, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package audio_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio"
+)
+
+func TestSourceErrors(t *testing.T) {
+	errs := make(chan *audio.SourceError, 3)
+	d := useTestContext(t, &audio.NewContextOptions{
+		SampleRate: 48000,
+		OnSourceError: func(err *audio.SourceError) {
+			errs <- err
+		},
+	})
+
+	good := audio.NewDynamicSound(func(buf []float32) {
+		for i := range buf {
+			buf[i] = 0.25
+		}
+	}, 1, audio.ChannelIdDefault)
+	panicking := audio.NewDynamicSound(func(buf []float32) {
+		panic("broken generator")
+	}, 1, audio.ChannelIdDefault)
+	nan := audio.NewDynamicSound(func(buf []float32) {
+		buf[0] = float32(math.NaN())
+	}, 1, audio.ChannelIdDefault)
+	good.Play()
+	defer good.Stop()
+	panicking.Play()
+	nan.Play()
+	audio.NewSound([]float32{0, 0}, 1, audio.ChannelIdDefault).Play().OnEndCallback(func() {
+		panic("broken callback")
+	})
+
+	buf := make([]float32, 8)
+	for i := 0; i < 2; i++ {
+		d.Read(buf)
+		for _, v := range buf {
+			if v != 0.25 {
+				t.Fatalf("only the good sound should be heard but got %v", buf)
+			}
+		}
+	}
+	failed := map[*audio.DynamicSound]bool{}
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			if err.DynamicSound != nil {
+				failed[err.DynamicSound] = true
+			} else if err.PlayingSound == nil {
+				t.Errorf("the error should name its source: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("OnSourceError should have been called three times")
+		}
+	}
+	if !failed[panicking] || !failed[nan] || failed[good] {
+		t.Errorf("the panicking and the NaN sounds should have been reported")
+	}
+}
@@ -0,0 +1,208 @@
+package audio
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// SpectrumAnalyzer computes the frequency spectrum of the most recently mixed audio,
+// either of the final mix or of a single channel.
+//
+// The mixer writes into a lock-free snapshot buffer, so reading the spectrum never blocks playback.
+// All the functions of a SpectrumAnalyzer are concurrent-safe.
+type SpectrumAnalyzer struct {
+	mixer     *Mixer
+	channelId ChannelId
+	master    bool
+	size      int
+
+	// written by the mixer
+	ring    []atomic.Uint32
+	written atomic.Uint64
+
+	// used by the reader
+	m      sync.Mutex
+	window []float32
+	re     []float32
+	im     []float32
+	// bins holds the magnitudes that Bands groups
+	bins []float32
+}
+
+// NewSpectrumAnalyzer creates an analyzer of the final mix, looking at the latest size frames.
+// size is rounded up to the nearest power of two.
+func NewSpectrumAnalyzer(size int) *SpectrumAnalyzer {
+	if mux == nil {
+		return nil
+	}
+	return mux.newSpectrumAnalyzer(ChannelIdDefault, true, size)
+}
+
+// NewSpectrumAnalyzer creates an analyzer of everything played on this channel,
+// after channel volume has been applied, looking at the latest size frames.
+// size is rounded up to the nearest power of two.
+func (cid ChannelId) NewSpectrumAnalyzer(size int) *SpectrumAnalyzer {
+	if mux == nil {
+		return nil
+	}
+	return mux.newSpectrumAnalyzer(cid, false, size)
+}
+
+// NewSpectrumAnalyzer creates an analyzer of the final mix of this mixer, see the package-level NewSpectrumAnalyzer.
+func (m *Mixer) NewSpectrumAnalyzer(size int) *SpectrumAnalyzer {
+	return m.newSpectrumAnalyzer(ChannelIdDefault, true, size)
+}
+
+// NewChannelSpectrumAnalyzer creates an analyzer of a channel of this mixer, see ChannelId.NewSpectrumAnalyzer.
+func (m *Mixer) NewChannelSpectrumAnalyzer(cid ChannelId, size int) *SpectrumAnalyzer {
+	return m.newSpectrumAnalyzer(cid, false, size)
+}
+
+func (m *Mixer) newSpectrumAnalyzer(channelId ChannelId, master bool, size int) *SpectrumAnalyzer {
+	size = max(size, 2)
+	size = 1 << bits.Len(uint(size-1))
+	a := &SpectrumAnalyzer{
+		mixer:     m,
+		channelId: channelId,
+		master:    master,
+		size:      size,
+		ring:      make([]atomic.Uint32, size),
+		window:    hannWindow(size),
+		re:        make([]float32, size),
+		im:        make([]float32, size),
+	}
+	for {
+		old := m.analyzers.Load()
+		var next []*SpectrumAnalyzer
+		if old != nil {
+			next = append(next, *old...)
+		}
+		next = append(next, a)
+		if m.analyzers.CompareAndSwap(old, &next) {
+			return a
+		}
+	}
+}
+
+// Close stops feeding the analyzer. The last snapshot can still be read.
+func (a *SpectrumAnalyzer) Close() {
+	m := a.mixer
+	for {
+		old := m.analyzers.Load()
+		if old == nil {
+			return
+		}
+		next := make([]*SpectrumAnalyzer, 0, len(*old))
+		for _, existing := range *old {
+			if existing != a {
+				next = append(next, existing)
+			}
+		}
+		if m.analyzers.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
+
+// Size returns the number of frames analyzed. The number of frequency bins is Size()/2.
+func (a *SpectrumAnalyzer) Size() int {
+	return a.size
+}
+
+// BinFrequency returns the center frequency in Hz of the given bin.
+func (a *SpectrumAnalyzer) BinFrequency(bin int) float32 {
+	return float32(bin) * float32(a.mixer.sampleRate) / float32(a.size)
+}
+
+// write is called by the mixer with interleaved samples, which are downmixed to mono.
+func (a *SpectrumAnalyzer) write(buf []float32) {
+	pos := a.written.Load()
+	mask := uint64(a.size - 1)
+	for i := 0; i+a.mixer.channelCount <= len(buf); i += a.mixer.channelCount {
+		var v float32
+		for c := 0; c < a.mixer.channelCount; c++ {
+			v += buf[i+c]
+		}
+		v /= float32(a.mixer.channelCount)
+		a.ring[pos&mask].Store(math.Float32bits(v))
+		pos++
+	}
+	a.written.Store(pos)
+}
+
+// Magnitudes computes the Hann-windowed FFT of the latest frames and stores the magnitude of each bin in dst.
+// A full scale sine wave results in a magnitude of about 1 in its bin.
+// dst is resized to Size()/2 if needed, and returned.
+func (a *SpectrumAnalyzer) Magnitudes(dst []float32) []float32 {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.magnitudes(dst)
+}
+
+// magnitudes is Magnitudes with a.m held.
+func (a *SpectrumAnalyzer) magnitudes(dst []float32) []float32 {
+	a.compute()
+
+	bins := a.size / 2
+	if cap(dst) < bins {
+		dst = make([]float32, bins)
+	}
+	dst = dst[:bins]
+	// compensate for the window's coherent gain of 0.5 and the one-sided spectrum
+	scale := 4 / float32(a.size)
+	for i := range dst {
+		dst[i] = float32(math.Hypot(float64(a.re[i]), float64(a.im[i]))) * scale
+	}
+	return dst
+}
+
+// Bands splits the frequency range between minFreq and maxFreq into len(dst) logarithmically spaced bands
+// and stores the highest bin magnitude of each band in dst.
+// This is usually what you want for visualizers, as it matches how we hear pitch.
+func (a *SpectrumAnalyzer) Bands(dst []float32, minFreq, maxFreq float32) []float32 {
+	if len(dst) == 0 {
+		return dst
+	}
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.bins = a.magnitudes(a.bins)
+	magnitudes := a.bins
+	binWidth := float64(a.mixer.sampleRate) / float64(a.size)
+	minFreq = max(minFreq, float32(binWidth))
+	maxFreq = min(maxFreq, float32(a.mixer.sampleRate)/2)
+	if maxFreq <= minFreq {
+		clear(dst)
+		return dst
+	}
+	ratio := math.Pow(float64(maxFreq/minFreq), 1/float64(len(dst)))
+	low := float64(minFreq)
+	for i := range dst {
+		high := low * ratio
+		first := int(math.Round(low / binWidth))
+		last := max(int(math.Round(high/binWidth))-1, first)
+		var peak float32
+		for bin := first; bin <= last && bin < len(magnitudes); bin++ {
+			peak = max(peak, magnitudes[bin])
+		}
+		dst[i] = peak
+		low = high
+	}
+	return dst
+}
+
+func (a *SpectrumAnalyzer) compute() {
+	end := int64(a.written.Load())
+	mask := int64(a.size - 1)
+	for i := 0; i < a.size; i++ {
+		var v float32
+		// before the buffer has been filled once, the missing frames are silent
+		if pos := end - int64(a.size) + int64(i); pos >= 0 {
+			v = math.Float32frombits(a.ring[pos&mask].Load())
+		}
+		a.re[i] = v * a.window[i]
+		a.im[i] = 0
+	}
+	fft(a.re, a.im)
+}
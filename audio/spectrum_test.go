@@ -0,0 +1,83 @@
+package audio_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/audio"
+)
+
+func TestSpectrumAnalyzer(t *testing.T) {
+	t.Parallel()
+
+	const freq = 1500
+	m := audio.NewMixer(&audio.MixerOptions{SampleRate: 48000})
+	master := m.NewSpectrumAnalyzer(2048)
+	defer master.Close()
+	ui := m.NewChannelSpectrumAnalyzer(audio.ChannelIdUi, 1000)
+	defer ui.Close()
+	if ui.Size() != 1024 {
+		t.Fatalf("size should be rounded up to 1024 but was %d", ui.Size())
+	}
+
+	frame := 0
+	m.NewDynamicSound(func(buf []float32) {
+		for i := 0; i < len(buf); i += audio.ChannelCount {
+			v := float32(0.5 * math.Sin(2*math.Pi*freq*float64(frame)/48000))
+			buf[i] = v
+			buf[i+1] = v
+			frame++
+		}
+	}, 1, audio.ChannelIdSfx).Play()
+	// more than the analyzed frames, in blocks like a driver would pull
+	buf := make([]float32, 2*512)
+	for i := 0; i < 5; i++ {
+		m.ReadFloat32s(buf)
+	}
+
+	magnitudes := master.Magnitudes(nil)
+	if len(magnitudes) != master.Size()/2 {
+		t.Fatalf("expected %d bins but got %d", master.Size()/2, len(magnitudes))
+	}
+	peak := 0
+	for i, m := range magnitudes {
+		if m > magnitudes[peak] {
+			peak = i
+		}
+	}
+	binWidth := master.BinFrequency(1)
+	if f := master.BinFrequency(peak); math.Abs(float64(f-freq)) > float64(binWidth) {
+		t.Errorf("peak should be at %dHz but was at %.1fHz", freq, f)
+	}
+	if magnitudes[peak] < 0.3 || magnitudes[peak] > 0.6 {
+		t.Errorf("peak magnitude should be about 0.5 but was %f", magnitudes[peak])
+	}
+
+	bands := master.Bands(make([]float32, 8), 100, 16000)
+	loudest := 0
+	for i, b := range bands {
+		if b > bands[loudest] {
+			loudest = i
+		}
+	}
+	// band i starts at 100Hz * 160^(i/8), so band 4 covers 1266Hz-2387Hz
+	if loudest != 4 {
+		t.Errorf("expected band 4 to be the loudest, got %d: %v", loudest, bands)
+	}
+
+	for _, m := range ui.Magnitudes(nil) {
+		if m > 0.01 {
+			t.Fatalf("the ui channel should be silent")
+		}
+	}
+}
+
+func TestSpectrumBandsAllocations(t *testing.T) {
+	a := audio.NewMixer(&audio.MixerOptions{SampleRate: 48000}).NewSpectrumAnalyzer(2048)
+	defer a.Close()
+	bands := make([]float32, 8)
+	// visualizers call Bands every frame
+	if allocs := testing.AllocsPerRun(10, func() { a.Bands(bands, 100, 16000) }); allocs != 0 {
+		t.Errorf("Bands should reuse its buffers but allocated %v times", allocs)
+	}
+}
@@ -0,0 +1,126 @@
+package audio
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// AudioStats is a snapshot of how well the audio keeps up, e.g. for playtest telemetry.
+type AudioStats struct {
+	// Xruns counts the underruns reported by the driver: the device ran out of audio and the output glitched.
+	Xruns int64
+
+	// Callbacks counts how often the driver asked for more audio.
+	Callbacks int64
+
+	// AverageMixTime and MaxMixTime are how long the mixer took per mix: per callback,
+	// or per block of NewContextOptions.MixAhead.
+	AverageMixTime time.Duration
+	MaxMixTime     time.Duration
+
+	// Period is the average duration of the audio that the driver read per callback.
+	// Without MixAhead, mixing must stay well below it, or the device will underrun.
+	Period time.Duration
+
+	// ActiveVoices is the number of sounds and dynamic sounds that were mixed in the last mix.
+	ActiveVoices int
+
+	// PoolExhausted counts the sounds that weren't played because all voices were in use.
+	PoolExhausted int64
+}
+
+// mixerStats is updated by the mixer.
+type mixerStats struct {
+	mixes         atomic.Int64
+	mixTime       atomic.Int64
+	maxMixTime    atomic.Int64
+	samples       atomic.Int64
+	activeVoices  atomic.Int64
+	poolExhausted atomic.Int64
+}
+
+// xruns is reported by the driver of the context.
+var xruns atomic.Int64
+
+// callbacks counts the reads of the driver of the context, and callbackAudio the nanoseconds of audio they read.
+var (
+	callbacks     atomic.Int64
+	callbackAudio atomic.Int64
+)
+
+// Stats returns the statistics of the context since it was created, or since the last ResetStats.
+func Stats() AudioStats {
+	var s AudioStats
+	if mux != nil {
+		s = mux.Stats()
+	}
+	s.Xruns = xruns.Load()
+	// the mixer may run in other blocks than the driver reads, see MixAhead
+	s.Callbacks = callbacks.Load()
+	s.Period = 0
+	if s.Callbacks > 0 {
+		s.Period = time.Duration(callbackAudio.Load() / s.Callbacks)
+	}
+	return s
+}
+
+// ResetStats starts counting from zero, e.g. to get the maximum mix time per telemetry interval.
+func ResetStats() {
+	xruns.Store(0)
+	callbacks.Store(0)
+	callbackAudio.Store(0)
+	if mux != nil {
+		mux.ResetStats()
+	}
+}
+
+// Stats returns the statistics of the mixer since it was created, or since the last ResetStats.
+// Every ReadFloat32s counts as a callback. Xruns are only known for the context, see the package-level Stats.
+func (m *Mixer) Stats() AudioStats {
+	s := AudioStats{
+		Callbacks:     m.stats.mixes.Load(),
+		MaxMixTime:    time.Duration(m.stats.maxMixTime.Load()),
+		ActiveVoices:  int(m.stats.activeVoices.Load()),
+		PoolExhausted: m.stats.poolExhausted.Load(),
+	}
+	if s.Callbacks > 0 {
+		s.AverageMixTime = time.Duration(m.stats.mixTime.Load() / s.Callbacks)
+		frames := m.stats.samples.Load() / int64(m.channelCount)
+		s.Period = framesToDuration(int(frames/s.Callbacks), m.sampleRate)
+	}
+	return s
+}
+
+// ResetStats starts counting the statistics of the mixer from zero.
+func (m *Mixer) ResetStats() {
+	m.stats.mixes.Store(0)
+	m.stats.mixTime.Store(0)
+	m.stats.maxMixTime.Store(0)
+	m.stats.samples.Store(0)
+	m.stats.poolExhausted.Store(0)
+}
+
+func recordXrun() {
+	xruns.Add(1)
+}
+
+// recordCallback is called whenever the driver of the context reads frames at sampleRate.
+func recordCallback(frames, sampleRate int) {
+	callbacks.Add(1)
+	callbackAudio.Add(int64(framesToDuration(frames, sampleRate)))
+}
+
+// recordMix is called after every ReadFloat32s.
+func (s *mixerStats) recordMix(start time.Time, samples int, voices int) {
+	elapsed := int64(time.Since(start))
+	s.mixes.Add(1)
+	s.mixTime.Add(elapsed)
+	s.samples.Add(int64(samples))
+	s.activeVoices.Store(int64(voices))
+	for {
+		m := s.maxMixTime.Load()
+		if elapsed <= m || s.maxMixTime.CompareAndSwap(m, elapsed) {
+			break
+		}
+	}
+}
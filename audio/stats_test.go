@@ -0,0 +1,58 @@
+package audio_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio"
+)
+
+func TestStats(t *testing.T) {
+	d := useTestContext(t, &audio.NewContextOptions{SampleRate: 48000})
+
+	audio.NewSound(make([]float32, 4800), 1, audio.ChannelIdDefault).Play()
+	buf := make([]float32, 960)
+	d.Read(buf)
+	d.Read(buf)
+	d.Options.ReportXrun()
+
+	s := audio.Stats()
+	if s.Callbacks != 2 || s.Xruns != 1 || s.ActiveVoices != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if s.Period != 10*time.Millisecond {
+		t.Errorf("480 frames at 48000 Hz should be a 10ms period but was %v", s.Period)
+	}
+	if s.MaxMixTime < s.AverageMixTime {
+		t.Errorf("the maximum mix time %v should be at least the average %v", s.MaxMixTime, s.AverageMixTime)
+	}
+
+	audio.ResetStats()
+	if s := audio.Stats(); s.Callbacks != 0 || s.Xruns != 0 || s.MaxMixTime != 0 {
+		t.Errorf("ResetStats should clear the counters but got %+v", s)
+	}
+}
+
+func TestStatsCountDriverReads(t *testing.T) {
+	// the mixer mixes ahead in small blocks, but the driver reads 2048 frames at once
+	d := useTestContext(t, &audio.NewContextOptions{SampleRate: 48000, MixAhead: 5 * time.Millisecond})
+	for audio.OutputLatency() < 5*time.Millisecond {
+		time.Sleep(time.Millisecond)
+	}
+	buf := make([]float32, 2*2048)
+	for i := 0; i < 3; i++ {
+		d.Read(buf)
+	}
+	want := 2048 * time.Second / 48000
+	if s := audio.Stats(); s.Callbacks != 3 || s.Period != want {
+		t.Errorf("should have counted 3 callbacks of %v but got %d of %v", want, s.Callbacks, s.Period)
+	}
+
+	// a driver that converts the mix to its rate reads the mix in other blocks
+	audio.ResetStats()
+	read := d.Options.ReadFloat32sAt(96000)
+	read(make([]float32, 2*960))
+	if s := audio.Stats(); s.Callbacks != 1 || s.Period != 10*time.Millisecond {
+		t.Errorf("should have counted 1 callback of 10ms but got %d of %v", s.Callbacks, s.Period)
+	}
+}
@@ -0,0 +1,536 @@
+package audio
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// streamDecodeAhead is how much audio a StreamingSound decodes before the mixer needs it.
+const streamDecodeAhead = time.Second / 2
+
+// streamBlockFrames is the number of frames that a StreamingSound decodes at once.
+const streamBlockFrames = 1024
+
+// StreamSource is decoded by a StreamingSound while it plays, e.g. an oggvorbis.Stream.
+// Read and Seek are only called by one goroutine at a time; Length can be called from any goroutine.
+type StreamSource interface {
+	// Read decodes the next interleaved frames at the sample rate of the mixer into buf,
+	// and returns the number of samples. At the end it returns io.EOF.
+	Read(buf []float32) (int, error)
+
+	// Seek moves to the given frame.
+	Seek(frame int) error
+
+	// Length returns the number of frames.
+	Length() int
+}
+
+// StreamingSound plays audio that is decoded on a background goroutine while it plays,
+// so that long music doesn't have to be kept in memory as float32.
+// It plays like a Sound, but only one instance at a time.
+//
+// All the functions of a StreamingSound are concurrent-safe.
+type StreamingSound struct {
+	mixer     *Mixer
+	source    StreamSource
+	channelId ChannelId
+	volume    float32
+
+	ring    *ringBuffer
+	tmp     []float32
+	playing atomic.Bool
+	seekTo  atomic.Int64
+	wake    chan struct{}
+
+	// decodedAll is set by the decoder once the last frame is in the ring
+	decodedAll atomic.Bool
+
+	// control serializes Play and Stop
+	control sync.Mutex
+
+	// m guards the state below, and resetting the ring. The mixer only ever tries to lock it,
+	// and plays silence for a moment if a seek holds it.
+	m             sync.Mutex
+	decoder       *streamDecoder
+	plan          streamPlan
+	pos           int
+	fadeOutLeft   int
+	fadeOutLength int
+	onEndCallback func()
+	// registered is whether the sound is in the list of the mixer
+	registered bool
+}
+
+// streamDecoder is the goroutine that decodes one playback of a StreamingSound.
+type streamDecoder struct {
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func (d *streamDecoder) cancel() {
+	d.once.Do(func() {
+		close(d.done)
+	})
+}
+
+// streamPlan is how a playback moves through the source: straight through, looping with a crossfade like
+// Sound.PlayLoop, or repeating a region like Sound.PlayLoopRegion. Positions are in frames.
+type streamPlan struct {
+	length int
+	fadeIn int
+
+	// crossFade > 0 loops the frames up to length-crossFade, and crossfades their start with the rest
+	crossFade int
+
+	// loopEnd > 0 repeats the frames from loopStart up to loopEnd
+	loopStart int
+	loopEnd   int
+}
+
+// advance returns the position after playing frames from pos. The decoder and the mixer both follow it.
+func (p streamPlan) advance(pos int, frames int) int {
+	pos += frames
+	switch {
+	case p.loopEnd > 0 && pos >= p.loopEnd:
+		pos = p.loopStart + (pos-p.loopEnd)%(p.loopEnd-p.loopStart)
+	case p.crossFade > 0 && pos >= p.length-p.crossFade:
+		pos = (pos - (p.length - p.crossFade)) % (p.length - p.crossFade)
+	}
+	return pos
+}
+
+// clampSeek returns where a seek to frame ends up.
+func (p streamPlan) clampSeek(frame int) int {
+	frame = max(frame, 0)
+	switch {
+	case p.loopEnd > 0 && frame >= p.loopEnd:
+		return p.loopStart
+	case p.crossFade > 0 && frame >= p.length-p.crossFade:
+		return 0
+	}
+	return min(frame, p.length)
+}
+
+// NewStreamingSound creates a new, ready-to-use StreamingSound that plays source.
+//
+// NewStreamingSound is concurrent-safe.
+func NewStreamingSound(source StreamSource, volume float32, channel ChannelId) *StreamingSound {
+	if mux == nil {
+		return nil
+	}
+	return mux.NewStreamingSound(source, volume, channel)
+}
+
+// NewStreamingSound creates a new StreamingSound that plays on this mixer, see the package-level NewStreamingSound.
+func (m *Mixer) NewStreamingSound(source StreamSource, volume float32, channel ChannelId) *StreamingSound {
+	frames := max(int(streamDecodeAhead.Seconds()*float64(m.sampleRate)), streamBlockFrames)
+	s := &StreamingSound{
+		mixer:     m,
+		source:    source,
+		channelId: channel,
+		volume:    volume,
+		ring:      newRingBuffer((frames + streamBlockFrames) * m.channelCount),
+		wake:      make(chan struct{}, 1),
+	}
+	s.seekTo.Store(-1)
+	return s
+}
+
+// Play starts playing the sound from the beginning. If it is already playing, it is restarted.
+func (s *StreamingSound) Play() *StreamingSound {
+	return s.play(streamPlan{})
+}
+
+// PlayFadeIn is like Play, but fades in the sound.
+func (s *StreamingSound) PlayFadeIn(fadeIn time.Duration) *StreamingSound {
+	return s.play(streamPlan{fadeIn: s.durationToFrames(fadeIn)})
+}
+
+// PlayLoop starts playing the sound in an infinite loop, crossfading its end into its start, like Sound.PlayLoop.
+func (s *StreamingSound) PlayLoop(crossFade time.Duration) *StreamingSound {
+	fade := s.durationToFrames(crossFade)
+	return s.play(streamPlan{fadeIn: fade, crossFade: max(fade, 1)})
+}
+
+// PlayLoopRegion plays the sound from the beginning, and then repeats the frames from start up to end,
+// like Sound.PlayLoopRegion.
+func (s *StreamingSound) PlayLoopRegion(start, end int) *StreamingSound {
+	length := s.source.Length()
+	end = min(end, length)
+	start = min(max(start, 0), end)
+	if start == end {
+		return s.play(streamPlan{})
+	}
+	return s.play(streamPlan{loopStart: start, loopEnd: end})
+}
+
+func (s *StreamingSound) play(plan streamPlan) *StreamingSound {
+	s.control.Lock()
+	defer s.control.Unlock()
+	s.stopDecoder()
+
+	plan.length = s.source.Length()
+	// the crossfade needs a part of the sound to loop
+	plan.crossFade = min(plan.crossFade, plan.length/2)
+	if plan.crossFade > 0 {
+		// the fade in of a loop is its crossfade, which only has as many frames of the end to mix in
+		plan.fadeIn = min(plan.fadeIn, plan.crossFade)
+	}
+
+	d := &streamDecoder{
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	s.m.Lock()
+	s.resetRing()
+	s.decoder = d
+	s.plan = plan
+	s.pos = 0
+	s.fadeOutLeft = 0
+	s.fadeOutLength = 0
+	s.seekTo.Store(-1)
+	s.decodedAll.Store(false)
+	s.register()
+	s.m.Unlock()
+
+	s.playing.Store(true)
+	go s.decode(d, plan)
+	return s
+}
+
+// Stop stops the sound without calling the OnEndCallback.
+func (s *StreamingSound) Stop() {
+	s.control.Lock()
+	defer s.control.Unlock()
+	s.playing.Store(false)
+	s.m.Lock()
+	s.onEndCallback = nil
+	s.unregister()
+	s.m.Unlock()
+	s.stopDecoder()
+}
+
+// StopFadeOut fades out the sound and then stops it, without calling the OnEndCallback.
+func (s *StreamingSound) StopFadeOut(fadeOut time.Duration) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.onEndCallback = nil
+	frames := max(s.durationToFrames(fadeOut), 1)
+	if s.fadeOutLeft == 0 || frames < s.fadeOutLeft {
+		s.fadeOutLeft = frames
+		s.fadeOutLength = frames
+	}
+}
+
+// OnEndCallback registers a callback that is called once when the sound has finished playing.
+func (s *StreamingSound) OnEndCallback(onEndCallback func()) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.onEndCallback = onEndCallback
+}
+
+// Seek moves the playing sound to a given percentage. If the sound isn't playing, this does nothing.
+func (s *StreamingSound) Seek(percentage float32) {
+	if !s.playing.Load() {
+		return
+	}
+	s.seekTo.Store(int64(percentage * float32(s.source.Length())))
+	s.signal()
+}
+
+// Seconds returns the current position and the total length of the sound.
+func (s *StreamingSound) Seconds() (current, total float32) {
+	rate := float32(s.mixer.sampleRate)
+	s.m.Lock()
+	pos := s.pos
+	s.m.Unlock()
+	return float32(pos) / rate, float32(s.source.Length()) / rate
+}
+
+// IsPlaying returns whether the sound is playing.
+func (s *StreamingSound) IsPlaying() bool {
+	return s.playing.Load()
+}
+
+func (s *StreamingSound) durationToFrames(d time.Duration) int {
+	return int(float64(s.mixer.sampleRate) * d.Seconds())
+}
+
+// stopDecoder stops the current decoder goroutine, if any. s.control must be held.
+func (s *StreamingSound) stopDecoder() {
+	s.m.Lock()
+	d := s.decoder
+	s.decoder = nil
+	s.m.Unlock()
+	if d == nil {
+		return
+	}
+	// the decoder locks s.m to seek, so it isn't held here
+	d.cancel()
+	<-d.stopped
+}
+
+// resetRing drops everything that was decoded but not played. s.m must be held, and the decoder must not be writing.
+func (s *StreamingSound) resetRing() {
+	s.ring.readPos.Store(s.ring.writePos.Load())
+}
+
+func (s *StreamingSound) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// decode runs on its own goroutine, and keeps the ring filled according to plan.
+// A source that fails or panics ends the playback once the decoded audio has been played.
+func (s *StreamingSound) decode(d *streamDecoder, plan streamPlan) {
+	defer close(d.stopped)
+	if err := s.decodeUntilDone(d, plan); err != nil {
+		s.fail(err)
+	}
+}
+
+// decodeUntilDone is the loop of decode. It returns when d is cancelled, or with the error of the source.
+func (s *StreamingSound) decodeUntilDone(d *streamDecoder, plan streamPlan) (err error) {
+	defer recoverSource(&err, "StreamSource")
+
+	channelCount := s.mixer.channelCount
+	buf := make([]float32, streamBlockFrames*channelCount)
+	var tail []float32
+	if plan.crossFade > 0 {
+		// the end of the sound is mixed into its start on every loop
+		tail = make([]float32, plan.crossFade*channelCount)
+		if err := s.readAt(plan.length-plan.crossFade, tail); err != nil {
+			return err
+		}
+	}
+	pos := 0
+	loopedOnce := false
+	if err := s.source.Seek(0); err != nil {
+		return err
+	}
+	for {
+		if frame := s.seekTo.Swap(-1); frame >= 0 {
+			pos = plan.clampSeek(int(frame))
+			if err := s.seek(pos); err != nil {
+				return err
+			}
+		}
+		for !s.decodedAll.Load() && s.ring.free() >= len(buf) {
+			n, err := s.decodeBlock(plan, &pos, &loopedOnce, buf, tail)
+			if err != nil {
+				return err
+			}
+			s.ring.write(buf[:n])
+			if n == 0 {
+				s.decodedAll.Store(true)
+			}
+		}
+		select {
+		case <-s.wake:
+		case <-d.done:
+			return nil
+		}
+	}
+}
+
+// seek drops the decoded audio and moves the source to pos, while the mixer waits.
+func (s *StreamingSound) seek(pos int) error {
+	s.m.Lock()
+	// unlocked by a defer, in case the source panics
+	defer s.m.Unlock()
+	s.resetRing()
+	s.pos = pos
+	s.decodedAll.Store(false)
+	return s.source.Seek(pos)
+}
+
+// decodeBlock decodes the next frames of the plan into buf, and returns the number of samples.
+// It returns 0 at the end of a playback that doesn't loop.
+func (s *StreamingSound) decodeBlock(plan streamPlan, pos *int, loopedOnce *bool, buf []float32, tail []float32) (int, error) {
+	channelCount := s.mixer.channelCount
+	end := plan.length
+	switch {
+	case plan.loopEnd > 0:
+		end = plan.loopEnd
+	case plan.crossFade > 0:
+		end = plan.length - plan.crossFade
+	}
+	if *pos >= end {
+		if plan.loopEnd == 0 && plan.crossFade == 0 {
+			return 0, nil
+		}
+		*pos = plan.advance(*pos, 0)
+		*loopedOnce = true
+		if err := s.source.Seek(*pos); err != nil {
+			return 0, err
+		}
+	}
+
+	frames := min(len(buf)/channelCount, end-*pos)
+	n, err := s.source.Read(buf[:frames*channelCount])
+	if err == io.EOF {
+		// the source was shorter than it claimed, so continue as if its end was reached
+		clear(buf[n:])
+		n = frames * channelCount
+	} else if err != nil {
+		return 0, err
+	}
+	frames = n / channelCount
+
+	for f := 0; f < frames; f++ {
+		p := *pos + f
+		if p >= plan.fadeIn {
+			continue
+		}
+		fadeIn := float32(p) / float32(plan.fadeIn)
+		for c := 0; c < channelCount; c++ {
+			i := f*channelCount + c
+			if *loopedOnce && plan.crossFade > 0 {
+				// crossfade: mix in the end of the sound
+				buf[i] = buf[i]*fadeIn + (1-fadeIn)*tail[p*channelCount+c]
+			} else {
+				buf[i] *= fadeIn
+			}
+		}
+	}
+	*pos += frames
+	return frames * channelCount, nil
+}
+
+// readAt reads len(buf) samples from frame on.
+func (s *StreamingSound) readAt(frame int, buf []float32) error {
+	if err := s.source.Seek(frame); err != nil {
+		return err
+	}
+	for read := 0; read < len(buf); {
+		n, err := s.source.Read(buf[read:])
+		read += n
+		if errors.Is(err, io.EOF) {
+			clear(buf[read:])
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail ends the playback after the decoded audio has been played, and reports err.
+func (s *StreamingSound) fail(err error) {
+	s.decodedAll.Store(true)
+	s.mixer.reportSourceError(&SourceError{StreamingSound: s, Err: err})
+}
+
+// readBufferAndAdd is called by the mixer.
+func (s *StreamingSound) readBufferAndAdd(buf []float32) {
+	if !s.playing.Load() || !s.m.TryLock() {
+		return
+	}
+	onEnd := s.readLocked(buf)
+	s.m.Unlock()
+
+	// outside of the lock, so that the callback can control this sound
+	if onEnd != nil {
+		if err := callOnEnd(onEnd); err != nil {
+			s.mixer.reportSourceError(&SourceError{StreamingSound: s, Err: err})
+		}
+	}
+}
+
+// readLocked mixes the decoded audio into buf, and returns the OnEndCallback if the sound ended. s.m must be held.
+func (s *StreamingSound) readLocked(buf []float32) (onEnd func()) {
+	channelSettings := s.mixer.channels.get(s.channelId)
+	if channelSettings.paused {
+		return nil
+	}
+	if len(s.tmp) < len(buf) {
+		s.tmp = make([]float32, len(buf))
+	}
+	tmp := s.tmp[:len(buf)]
+	n := s.ring.read(tmp)
+	s.signal()
+
+	channelCount := s.mixer.channelCount
+	volume := s.volume * channelSettings.volume
+	for i := 0; i < n; i += channelCount {
+		v := volume
+		if s.fadeOutLength > 0 {
+			if s.fadeOutLeft <= 0 {
+				s.finish()
+				return nil
+			}
+			v *= float32(s.fadeOutLeft) / float32(s.fadeOutLength)
+			s.fadeOutLeft--
+		}
+		for c := 0; c < channelCount; c++ {
+			buf[i+c] += tmp[i+c] * v
+		}
+	}
+	s.pos = s.plan.advance(s.pos, n/channelCount)
+	if n < len(buf) && s.decodedAll.Load() && s.ring.available() == 0 {
+		s.finish()
+		onEnd = s.onEndCallback
+		s.onEndCallback = nil
+	}
+	return onEnd
+}
+
+// finish ends the playback from the mixer. s.m must be held.
+func (s *StreamingSound) finish() {
+	s.playing.Store(false)
+	s.unregister()
+	if s.decoder != nil {
+		s.decoder.cancel()
+	}
+}
+
+// register adds the sound to the mixer, unless it is already there. s.m must be held.
+func (s *StreamingSound) register() {
+	if s.registered {
+		return
+	}
+	s.registered = true
+	m := s.mixer
+	for {
+		old := m.streamingSounds.Load()
+		var next []*StreamingSound
+		if old != nil {
+			next = append(next, *old...)
+		}
+		next = append(next, s)
+		if m.streamingSounds.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
+
+// unregister removes the sound from the mixer once it stopped, so that the mixer doesn't keep it. s.m must be held.
+func (s *StreamingSound) unregister() {
+	if !s.registered {
+		return
+	}
+	s.registered = false
+	m := s.mixer
+	for {
+		old := m.streamingSounds.Load()
+		if old == nil {
+			return
+		}
+		next := make([]*StreamingSound, 0, len(*old))
+		for _, existing := range *old {
+			if existing != s {
+				next = append(next, existing)
+			}
+		}
+		if m.streamingSounds.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
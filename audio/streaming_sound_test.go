@@ -0,0 +1,216 @@
+package audio_test
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio"
+)
+
+// sliceSource streams stereo samples from memory.
+type sliceSource struct {
+	data []float32
+	pos  int
+}
+
+func (s *sliceSource) Read(buf []float32) (int, error) {
+	n := copy(buf, s.data[s.pos*2:])
+	s.pos += n / 2
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func (s *sliceSource) Seek(frame int) error {
+	s.pos = frame
+	return nil
+}
+
+func (s *sliceSource) Length() int {
+	return len(s.data) / 2
+}
+
+func ramp(frames int) []float32 {
+	data := make([]float32, frames*2)
+	for i := range data {
+		data[i] = float32(i/2+1) / float32(frames)
+	}
+	return data
+}
+
+// readDecoded waits for the decoder to get ahead, and then pulls the mixer.
+func readDecoded(m *audio.Mixer, buf []float32) {
+	time.Sleep(20 * time.Millisecond)
+	m.ReadFloat32s(buf)
+}
+
+func TestStreamingSoundMatchesSound(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		// frames is the length of the sound
+		frames int
+		// Sound fades per sample and StreamingSound per frame, so they may differ by one step of a fade
+		tolerance float64
+		sound     func(s *audio.Sound)
+		stream    func(s *audio.StreamingSound)
+	}{
+		{"play", 400, 1e-6, func(s *audio.Sound) { s.Play() }, func(s *audio.StreamingSound) { s.Play() }},
+		{"fade in", 400, 1.0 / 16, func(s *audio.Sound) { s.PlayFadeIn(time.Millisecond) }, func(s *audio.StreamingSound) { s.PlayFadeIn(time.Millisecond) }},
+		{"loop", 400, 1.0 / 16, func(s *audio.Sound) { s.PlayLoop(time.Millisecond) }, func(s *audio.StreamingSound) { s.PlayLoop(time.Millisecond) }},
+		{"loop region", 400, 1e-6, func(s *audio.Sound) { s.PlayLoopRegion(100, 300) }, func(s *audio.StreamingSound) { s.PlayLoopRegion(100, 300) }},
+		// a crossfade longer than half of an odd length
+		{"long loop", 401, 1.0 / 16, func(s *audio.Sound) { s.PlayLoop(40 * time.Millisecond) }, func(s *audio.StreamingSound) { s.PlayLoop(40 * time.Millisecond) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := ramp(c.frames)
+			memory := audio.NewMixer(&audio.MixerOptions{SampleRate: 8000})
+			c.sound(memory.NewSound(data, 1, audio.ChannelIdDefault))
+			streaming := audio.NewMixer(&audio.MixerOptions{SampleRate: 8000})
+			c.stream(streaming.NewStreamingSound(&sliceSource{data: data}, 1, audio.ChannelIdDefault))
+
+			// long enough to loop a few times. Sound is read at once, because it only crossfades
+			// consistently within one read.
+			const block = 2 * 1000
+			want := make([]float32, 3*block)
+			memory.ReadFloat32s(want)
+			got := make([]float32, len(want))
+			for i := 0; i < len(got); i += block {
+				readDecoded(streaming, got[i:i+block])
+			}
+			for i := range want {
+				if math.Abs(float64(got[i]-want[i])) > c.tolerance {
+					t.Fatalf("sample %d should be %v but was %v", i, want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStreamingSoundControl(t *testing.T) {
+	t.Parallel()
+
+	m := audio.NewMixer(&audio.MixerOptions{SampleRate: 8000})
+	s := m.NewStreamingSound(&sliceSource{data: ramp(8000)}, 1, audio.ChannelIdDefault)
+	ended := make(chan struct{})
+	s.Play().OnEndCallback(func() {
+		close(ended)
+	})
+
+	buf := make([]float32, 2*800)
+	readDecoded(m, buf)
+	if current, total := s.Seconds(); current != 0.1 || total != 1 {
+		t.Errorf("the sound should be at 0.1s of 1s but was at %vs of %vs", current, total)
+	}
+
+	s.Seek(0.5)
+	readDecoded(m, buf)
+	if buf[0] != 0.5+1.0/8000 {
+		t.Errorf("after seeking to the middle the first sample should be %v but was %v", 0.5+1.0/8000, buf[0])
+	}
+	if current, _ := s.Seconds(); current != 0.6 {
+		t.Errorf("the sound should be at 0.6s but was at %vs", current)
+	}
+
+	for i := 0; i < 10 && s.IsPlaying(); i++ {
+		readDecoded(m, buf)
+	}
+	if s.IsPlaying() {
+		t.Fatal("the sound should have ended")
+	}
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Error("the OnEndCallback should have been called")
+	}
+
+	// a fade out stops a looping sound
+	s.PlayLoop(0)
+	readDecoded(m, buf)
+	s.StopFadeOut(10 * time.Millisecond)
+	readDecoded(m, buf)
+	if s.IsPlaying() {
+		t.Error("the sound should have faded out")
+	}
+	if buf[len(buf)-1] != 0 {
+		t.Error("the sound should be silent after fading out")
+	}
+}
+
+// panickingSource is a StreamSource with a bug.
+type panickingSource struct{}
+
+func (panickingSource) Read(buf []float32) (int, error) { panic("broken decoder") }
+func (panickingSource) Seek(frame int) error            { return nil }
+func (panickingSource) Length() int                     { return 8000 }
+
+func TestStreamingSoundSourcePanics(t *testing.T) {
+	t.Parallel()
+
+	errs := make(chan *audio.SourceError, 1)
+	m := audio.NewMixer(&audio.MixerOptions{SampleRate: 8000, OnSourceError: func(err *audio.SourceError) {
+		errs <- err
+	}})
+	s := m.NewStreamingSound(panickingSource{}, 1, audio.ChannelIdDefault).Play()
+	select {
+	case err := <-errs:
+		if err.StreamingSound != s {
+			t.Errorf("the error should name the streaming sound: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the panic should have been reported to OnSourceError")
+	}
+	readDecoded(m, make([]float32, 2*64))
+	if s.IsPlaying() {
+		t.Error("the sound should have ended")
+	}
+}
+
+// TestStreamingSoundConcurrentPlay plays and stops sounds while the mixer runs; run it with -race.
+func TestStreamingSoundConcurrentPlay(t *testing.T) {
+	t.Parallel()
+
+	m := audio.NewMixer(&audio.MixerOptions{SampleRate: 8000})
+	done := make(chan struct{})
+	mixed := make(chan struct{})
+	go func() {
+		defer close(mixed)
+		buf := make([]float32, 2*64)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				m.ReadFloat32s(buf)
+			}
+		}
+	}()
+
+	sounds := make([]*audio.StreamingSound, 8)
+	for i := range sounds {
+		sounds[i] = m.NewStreamingSound(&sliceSource{data: ramp(100)}, 1, audio.ChannelIdDefault)
+	}
+	for i := 0; i < 200; i++ {
+		s := sounds[i%len(sounds)]
+		s.Play()
+		if i%3 == 0 {
+			s.Stop()
+		}
+	}
+	for _, s := range sounds {
+		s.Stop()
+	}
+	close(done)
+	<-mixed
+
+	buf := make([]float32, 2*64)
+	m.ReadFloat32s(buf)
+	if voices := m.Stats().ActiveVoices; voices != 0 {
+		t.Errorf("stopped sounds should not be mixed, but %d voices were", voices)
+	}
+}
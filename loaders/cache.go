@@ -0,0 +1,235 @@
+package loaders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/Lundis/go-gameaudio/loaders/loudness"
+)
+
+// Cache keeps decoded audio on disk, so that the next launch reads it instead of decoding and resampling again.
+// Entries are found by a hash of the file and the sample rate, and are decoded again when the version of the
+// format changed or the entry is damaged.
+//
+// Failing to write the cache never fails a decode; the file is then decoded again next time.
+type Cache struct {
+	dir     string
+	options CacheOptions
+}
+
+// CacheOptions control how a cache stores samples.
+type CacheOptions struct {
+	// Int16 stores the samples with 16 bits instead of 32, which halves the size of the cache,
+	// at the cost of quantizing them to 16 bits.
+	Int16 bool
+}
+
+// OpenCache uses dir as a cache, creating it if needed.
+func OpenCache(dir string, options CacheOptions) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the cache: %w", err)
+	}
+	return &Cache{dir: dir, options: options}, nil
+}
+
+var (
+	cacheLock    sync.RWMutex
+	currentCache *Cache
+)
+
+// SetCache makes Decode, NewStream, and so sfx.Load and playlist.Load, use cache for the formats they decode up front.
+// Formats that are streamed aren't cached, as they are already cheap to load, but their loudness from Loudness is.
+// nil turns the cache off, which is the default.
+func SetCache(cache *Cache) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	currentCache = cache
+}
+
+func getCache() *Cache {
+	cacheLock.RLock()
+	defer cacheLock.RUnlock()
+	return currentCache
+}
+
+// cacheMagic starts all entries. Its last byte is the version of the layout of the entries.
+var cacheMagic = []byte("GACH\x01")
+
+// sample formats of the entries
+const (
+	cacheFloat32 = 0
+	cacheInt16   = 1
+)
+
+var errCacheEntry = errors.New("damaged cache entry")
+
+// path is the file of the entry of a file with the given hash at sampleRate, ext tells the samples from the loudness.
+func (c *Cache) path(hash [sha256.Size]byte, sampleRate int, ext string) string {
+	return filepath.Join(c.dir, hex.EncodeToString(hash[:])+"-"+strconv.Itoa(sampleRate)+ext)
+}
+
+// cacheHeader is the identity of an entry: everything but the sample format, which only changes how it is read.
+func cacheHeader(format Format, hash [sha256.Size]byte, sampleRate int) []byte {
+	b := append([]byte(nil), cacheMagic...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(format.Version))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate))
+	b = append(b, byte(len(format.Name)))
+	b = append(b, format.Name...)
+	return append(b, hash[:]...)
+}
+
+// load returns the cached samples of raw, or false if there are none or they are stale.
+func (c *Cache) load(format Format, hash [sha256.Size]byte, sampleRate int) ([]float32, bool) {
+	data, err := os.ReadFile(c.path(hash, sampleRate, ".pcm"))
+	if err != nil {
+		return nil, false
+	}
+	samples, err := parseCacheEntry(data, cacheHeader(format, hash, sampleRate))
+	if err != nil {
+		return nil, false
+	}
+	return samples, true
+}
+
+func parseCacheEntry(data, header []byte) ([]float32, error) {
+	if !bytes.HasPrefix(data, header) {
+		// another version of the format, or another layout
+		return nil, errCacheEntry
+	}
+	data = data[len(header):]
+	if len(data) < 1+8+4 {
+		return nil, errCacheEntry
+	}
+	sampleFormat := data[0]
+	count := binary.LittleEndian.Uint64(data[1:])
+	checksum := binary.LittleEndian.Uint32(data[9:])
+	payload := data[13:]
+
+	size := uint64(4)
+	if sampleFormat == cacheInt16 {
+		size = 2
+	} else if sampleFormat != cacheFloat32 {
+		return nil, errCacheEntry
+	}
+	if count > uint64(len(payload))/size || uint64(len(payload)) != count*size || crc32.ChecksumIEEE(payload) != checksum {
+		return nil, errCacheEntry
+	}
+
+	samples := make([]float32, count)
+	for i := range samples {
+		if sampleFormat == cacheInt16 {
+			samples[i] = float32(int16(binary.LittleEndian.Uint16(payload[2*i:]))) / 32767
+		} else {
+			samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(payload[4*i:]))
+		}
+	}
+	return samples, nil
+}
+
+// store writes the samples of raw to the cache.
+func (c *Cache) store(format Format, hash [sha256.Size]byte, sampleRate int, samples []float32) error {
+	header := cacheHeader(format, hash, sampleRate)
+	size := 4
+	sampleFormat := byte(cacheFloat32)
+	if c.options.Int16 {
+		size = 2
+		sampleFormat = cacheInt16
+	}
+	payload := make([]byte, len(samples)*size)
+	for i, v := range samples {
+		if c.options.Int16 {
+			binary.LittleEndian.PutUint16(payload[2*i:], uint16(int16(math.Round(float64(min(max(v, -1), 1))*32767))))
+		} else {
+			binary.LittleEndian.PutUint32(payload[4*i:], math.Float32bits(v))
+		}
+	}
+
+	b := make([]byte, 0, len(header)+13+len(payload))
+	b = append(b, header...)
+	b = append(b, sampleFormat)
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(samples)))
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(payload))
+	b = append(b, payload...)
+
+	return c.write(c.path(hash, sampleRate, ".pcm"), b)
+}
+
+// write writes an entry to path through a temporary file, so that other goroutines and processes never read half of it.
+func (c *Cache) write(path string, b []byte) error {
+	tmp, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// loudnessMagic starts the entries of measured loudness. Its last byte is the version of the measurement,
+// which is increased when package loudness measures differently.
+var loudnessMagic = []byte("GACL\x01")
+
+// loadLoudness returns the cached loudness of raw, or false if there is none or it is stale.
+func (c *Cache) loadLoudness(format Format, hash [sha256.Size]byte, sampleRate int) (loudness.Loudness, bool) {
+	data, err := os.ReadFile(c.path(hash, sampleRate, ".lufs"))
+	if err != nil {
+		return loudness.Loudness{}, false
+	}
+	header := append(append([]byte(nil), loudnessMagic...), cacheHeader(format, hash, sampleRate)...)
+	if !bytes.HasPrefix(data, header) || len(data) != len(header)+16+4 {
+		return loudness.Loudness{}, false
+	}
+	payload := data[len(header) : len(header)+16]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(data[len(header)+16:]) {
+		return loudness.Loudness{}, false
+	}
+	return loudness.Loudness{
+		Integrated: math.Float64frombits(binary.LittleEndian.Uint64(payload)),
+		TruePeak:   math.Float64frombits(binary.LittleEndian.Uint64(payload[8:])),
+	}, true
+}
+
+// storeLoudness writes the loudness of raw to the cache.
+func (c *Cache) storeLoudness(format Format, hash [sha256.Size]byte, sampleRate int, l loudness.Loudness) error {
+	b := append(append([]byte(nil), loudnessMagic...), cacheHeader(format, hash, sampleRate)...)
+	var payload []byte
+	payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(l.Integrated))
+	payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(l.TruePeak))
+	b = append(b, payload...)
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(payload))
+	return c.write(c.path(hash, sampleRate, ".lufs"), b)
+}
+
+// Clear removes all the entries, e.g. to reclaim the space of files that are no longer used.
+func (c *Cache) Clear() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if ext := filepath.Ext(e.Name()); ext == ".pcm" || ext == ".lufs" || ext == ".tmp" {
+			if err := os.Remove(filepath.Join(c.dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
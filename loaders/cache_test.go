@@ -0,0 +1,200 @@
+package loaders_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/loaders"
+)
+
+// registerCounted registers a format that counts how often it decodes, and decodes "CNT<n>" to a ramp of n frames.
+func registerCounted(magic string, version int, decodes *int) {
+	loaders.Register(loaders.Format{
+		Name:    "counted",
+		Version: version,
+		Match:   func(header []byte) bool { return len(header) >= 4 && string(header[:3]) == magic },
+		Decode: func(raw []byte, sampleRate int) ([]float32, error) {
+			*decodes++
+			data := make([]float32, 2*int(raw[3]))
+			for i := range data {
+				data[i] = float32(i) / float32(len(data))
+			}
+			return data, nil
+		},
+	})
+}
+
+func useCache(t *testing.T, options loaders.CacheOptions) (*loaders.Cache, string) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache, err := loaders.OpenCache(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaders.SetCache(cache)
+	t.Cleanup(func() { loaders.SetCache(nil) })
+	return cache, dir
+}
+
+func TestCache(t *testing.T) {
+	_, dir := useCache(t, loaders.CacheOptions{})
+	var decodes int
+	registerCounted("CNA", 1, &decodes)
+
+	first, err := loaders.Decode([]byte("CNA\x10"), 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := loaders.Decode([]byte("CNA\x10"), 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decodes != 1 {
+		t.Errorf("the second decode should have read the cache, but decoded %d times", decodes)
+	}
+	if len(first) != len(second) {
+		t.Fatalf("the cache should return %d samples but returned %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("sample %d should be %v but the cache returned %v", i, first[i], second[i])
+		}
+	}
+
+	// another sample rate or another file is another entry
+	if _, err := loaders.Decode([]byte("CNA\x10"), 48000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loaders.Decode([]byte("CNA\x11"), 44100); err != nil {
+		t.Fatal(err)
+	}
+	if decodes != 3 {
+		t.Errorf("another sample rate and file should have been decoded, but decoded %d times", decodes)
+	}
+
+	// a damaged entry is decoded again
+	entries, err := filepath.Glob(filepath.Join(dir, "*.pcm"))
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("should have 3 entries but has %v, %v", entries, err)
+	}
+	for _, e := range entries {
+		data, err := os.ReadFile(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(e, data[:len(data)-1], 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := loaders.Decode([]byte("CNA\x10"), 44100); err != nil {
+		t.Fatal(err)
+	}
+	if decodes != 4 {
+		t.Errorf("a truncated entry should have been decoded again, but decoded %d times", decodes)
+	}
+}
+
+func TestCacheVersion(t *testing.T) {
+	useCache(t, loaders.CacheOptions{})
+	var old, updated int
+	registerCounted("CNB", 1, &old)
+	if _, err := loaders.Decode([]byte("CNB\x10"), 44100); err != nil {
+		t.Fatal(err)
+	}
+	// a new version of the decoder
+	registerCounted("CNB", 2, &updated)
+	for range 2 {
+		if _, err := loaders.Decode([]byte("CNB\x10"), 44100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if old != 1 || updated != 1 {
+		t.Errorf("the entry of the old version should have been decoded again once, but was decoded %d and %d times", old, updated)
+	}
+}
+
+func TestCacheInt16(t *testing.T) {
+	cache, dir := useCache(t, loaders.CacheOptions{Int16: true})
+	raw, err := os.ReadFile("wav/test_stereo.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := loaders.Decode(raw, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stream of a format that isn't streamed comes from the cache as well
+	stream, err := loaders.NewStream(raw, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readAll(t, stream)
+	if len(got) != len(want) {
+		t.Fatalf("the cache should return %d samples but returned %d", len(want), len(got))
+	}
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1.0/32767 {
+			t.Fatalf("sample %d should be about %v but the cache returned %v", i, want[i], got[i])
+		}
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Clear should remove all entries but left %d", len(entries))
+	}
+}
+
+func TestCacheLoudness(t *testing.T) {
+	cache, dir := useCache(t, loaders.CacheOptions{})
+	raw, err := os.ReadFile("oggvorbis/test_stereo.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := loaders.Loudness(raw, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.IsInf(want.Integrated, -1) {
+		t.Fatal("the test file should not be silent")
+	}
+	// a streamed format keeps only its loudness in the cache
+	entries, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil || len(entries) != 1 || filepath.Ext(entries[0]) != ".lufs" {
+		t.Fatalf("should have one loudness entry but has %v, %v", entries, err)
+	}
+
+	// the cached measurement is read instead of decoding the file
+	var decodes int
+	registerCounted("CNL", 1, &decodes)
+	if _, err := loaders.Loudness([]byte("CNL\x10"), 44100); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, cacheEntryName(t, dir, ".pcm"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loaders.Loudness([]byte("CNL\x10"), 44100); err != nil || decodes != 1 {
+		t.Errorf("the loudness should have come from the cache, but decoded %d times, %v", decodes, err)
+	}
+	if got, err := loaders.Loudness(raw, 44100); err != nil || got != want {
+		t.Errorf("the cache should return %+v but returned %+v, %v", want, got, err)
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Clear should remove the loudness entries too, but left %d", len(entries))
+	}
+}
+
+// cacheEntryName returns the name of the only entry with the extension ext.
+func cacheEntryName(t *testing.T, dir, ext string) string {
+	entries, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("should have one %s entry but has %v, %v", ext, entries, err)
+	}
+	return filepath.Base(entries[0])
+}
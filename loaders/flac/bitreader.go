@@ -0,0 +1,79 @@
+package flac
+
+import "math/bits"
+
+// bitReader reads big-endian bit fields. Reading past the end yields zeros and sets truncated,
+// so that the decoder only has to check once per frame.
+type bitReader struct {
+	buf []byte
+	pos int
+
+	// cache holds the next n bits, starting at its most significant bit
+	cache     uint64
+	n         uint
+	truncated bool
+}
+
+func (r *bitReader) fill() {
+	for r.n <= 56 && r.pos < len(r.buf) {
+		r.cache |= uint64(r.buf[r.pos]) << (56 - r.n)
+		r.n += 8
+		r.pos++
+	}
+}
+
+// read returns the next n bits, n <= 56.
+func (r *bitReader) read(n uint) uint64 {
+	if r.n < n {
+		r.fill()
+		if r.n < n {
+			r.truncated = true
+			r.cache, r.n = 0, 0
+			return 0
+		}
+	}
+	v := r.cache >> (64 - n)
+	r.cache <<= n
+	r.n -= n
+	return v
+}
+
+// readSigned returns the next n bits as a two's complement number.
+func (r *bitReader) readSigned(n uint) int64 {
+	if n == 0 {
+		return 0
+	}
+	return int64(r.read(n)<<(64-n)) >> (64 - n)
+}
+
+// readUnary counts the zero bits up to the next one bit, and skips them all.
+func (r *bitReader) readUnary() uint64 {
+	var zeros uint64
+	for {
+		if r.cache == 0 {
+			// all the cached bits are zeros
+			zeros += uint64(r.n)
+			r.n = 0
+			r.fill()
+			if r.n == 0 {
+				r.truncated = true
+				return 0
+			}
+			continue
+		}
+		z := uint(bits.LeadingZeros64(r.cache))
+		r.cache <<= z + 1
+		r.n -= z + 1
+		return zeros + uint64(z)
+	}
+}
+
+// align skips the bits up to the next byte boundary.
+func (r *bitReader) align() {
+	r.read(r.n % 8)
+}
+
+// offset returns the position of the next unread byte. The reader must be aligned.
+func (r *bitReader) offset() int {
+	return r.pos - int(r.n/8)
+}
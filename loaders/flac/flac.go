@@ -0,0 +1,180 @@
+// Package flac decodes FLAC files in pure Go.
+package flac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Lundis/go-gameaudio/loaders/internal/id3"
+	"github.com/Lundis/go-gameaudio/loaders/resample"
+)
+
+var (
+	// ErrTruncated is returned when the file ends in the middle of the metadata or the audio.
+	ErrTruncated = errors.New("flac: file is truncated")
+	// ErrInvalidHeader is returned when the file isn't a FLAC file, or its STREAMINFO block is broken.
+	ErrInvalidHeader = errors.New("flac: invalid header")
+	// ErrInvalidFrame is returned for audio frames that don't follow the FLAC format.
+	ErrInvalidFrame = errors.New("flac: invalid frame")
+	// ErrChecksum is returned when the CRC of a frame, or the MD5 signature of the audio, doesn't match.
+	ErrChecksum = errors.New("flac: checksum mismatch")
+)
+
+// Options control how a file is decoded.
+type Options struct {
+	// CheckMD5 verifies the decoded audio against the MD5 signature in the STREAMINFO block,
+	// at the cost of hashing all of it. Files without a signature pass.
+	CheckMD5 bool
+}
+
+func LoadFile(path string, expectedSampleRate int) ([]float32, error) {
+	return LoadFileWithOptions(path, expectedSampleRate, Options{})
+}
+
+// LoadFileWithOptions is like LoadWithOptions but reads the file at path.
+func LoadFileWithOptions(path string, expectedSampleRate int, options Options) ([]float32, error) {
+	rawData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to open: %w", path, err)
+	}
+
+	data, err := LoadWithOptions(rawData, expectedSampleRate, options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// Load decodes a FLAC file to interleaved stereo at expectedSampleRate.
+// Any bit depth up to 32 bits is supported. Mono is played on both sides,
+// and surround layouts are mixed down to stereo.
+//
+// Corrupt files never cause a panic; the returned error wraps ErrTruncated, ErrInvalidHeader,
+// ErrInvalidFrame or ErrChecksum.
+func Load(flacData []byte, expectedSampleRate int) ([]float32, error) {
+	return LoadWithOptions(flacData, expectedSampleRate, Options{})
+}
+
+// LoadWithOptions is like Load, with options.
+func LoadWithOptions(flacData []byte, expectedSampleRate int, options Options) ([]float32, error) {
+	info, frames, err := readHeader(flacData)
+	if err != nil {
+		return nil, err
+	}
+	d := newDecoder(info, len(frames))
+	if options.CheckMD5 && info.md5 != [16]byte{} {
+		d.hash = md5.New()
+	}
+	if err := d.decodeFrames(frames); err != nil {
+		return nil, err
+	}
+	if d.hash != nil && !bytes.Equal(d.hash.Sum(nil), info.md5[:]) {
+		return nil, fmt.Errorf("%w: MD5 signature of the audio", ErrChecksum)
+	}
+	return resample.Stereo(d.out, info.sampleRate, expectedSampleRate), nil
+}
+
+// streamInfo is the content of the STREAMINFO block.
+type streamInfo struct {
+	maxBlockSize  int
+	sampleRate    int
+	channelCount  int
+	bitsPerSample int
+	// totalFrames is 0 if unknown
+	totalFrames int64
+	md5         [16]byte
+}
+
+// Resampling from absurd rates would blow up corrupt files to gigabytes.
+const (
+	minSampleRate = 1000
+	maxSampleRate = 768000
+)
+
+const (
+	blockTypeStreamInfo = 0
+	streamInfoSize      = 34
+)
+
+// readHeader reads the metadata blocks, and returns the STREAMINFO and the audio frames after the metadata.
+func readHeader(data []byte) (streamInfo, []byte, error) {
+	data = id3.SkipV2(data)
+	if len(data) < 4 {
+		return streamInfo{}, nil, fmt.Errorf("%w: %d bytes are too short for a FLAC header", ErrTruncated, len(data))
+	}
+	if !bytes.Equal(data[:4], []byte("fLaC")) {
+		return streamInfo{}, nil, fmt.Errorf("%w: 'fLaC' not found", ErrInvalidHeader)
+	}
+	data = data[4:]
+
+	var info streamInfo
+	for first := true; ; first = false {
+		if len(data) < 4 {
+			return streamInfo{}, nil, fmt.Errorf("%w: metadata block header", ErrTruncated)
+		}
+		last := data[0]&0x80 != 0
+		blockType := data[0] & 0x7F
+		size := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+		data = data[4:]
+		if size > len(data) {
+			return streamInfo{}, nil, fmt.Errorf("%w: metadata block of %d bytes", ErrTruncated, size)
+		}
+		if first {
+			// STREAMINFO is always the first block
+			if blockType != blockTypeStreamInfo || size < streamInfoSize {
+				return streamInfo{}, nil, fmt.Errorf("%w: STREAMINFO block not found", ErrInvalidHeader)
+			}
+			var err error
+			if info, err = parseStreamInfo(data[:size]); err != nil {
+				return streamInfo{}, nil, err
+			}
+		}
+		data = data[size:]
+		if last {
+			return info, data, nil
+		}
+	}
+}
+
+func parseStreamInfo(b []byte) (streamInfo, error) {
+	r := bitReader{buf: b}
+	r.read(16) // min block size
+	info := streamInfo{maxBlockSize: int(r.read(16))}
+	r.read(24) // min frame size
+	r.read(24) // max frame size
+	info.sampleRate = int(r.read(20))
+	info.channelCount = int(r.read(3)) + 1
+	info.bitsPerSample = int(r.read(5)) + 1
+	info.totalFrames = int64(r.read(36))
+	copy(info.md5[:], b[18:34])
+
+	if info.sampleRate < minSampleRate || info.sampleRate > maxSampleRate {
+		return streamInfo{}, fmt.Errorf("%w: sample rate must be between %d and %d but was %d", ErrInvalidHeader, minSampleRate, maxSampleRate, info.sampleRate)
+	}
+	if info.bitsPerSample < 4 {
+		return streamInfo{}, fmt.Errorf("%w: bits per sample must be at least 4 but was %d", ErrInvalidHeader, info.bitsPerSample)
+	}
+	if info.maxBlockSize < 16 {
+		return streamInfo{}, fmt.Errorf("%w: maximum block size must be at least 16 but was %d", ErrInvalidHeader, info.maxBlockSize)
+	}
+	return info, nil
+}
+
+// writeSamples adds the samples of a frame to the MD5 signature: interleaved, little-endian, in whole bytes.
+func (d *decoder) writeSamples(channels [][]int64, blockSize int) {
+	sampleSize := (d.info.bitsPerSample + 7) / 8
+	buf := d.md5Buf[:0]
+	var sample [8]byte
+	for i := 0; i < blockSize; i++ {
+		for _, ch := range channels {
+			binary.LittleEndian.PutUint64(sample[:], uint64(ch[i]))
+			buf = append(buf, sample[:sampleSize]...)
+		}
+	}
+	d.hash.Write(buf)
+	d.md5Buf = buf
+}
@@ -0,0 +1,161 @@
+package flac_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/loaders/flac"
+)
+
+// signal is the test signal that the test files were encoded from: triangle waves with noise,
+// a silent channel in the third block and wasted bits in the fourth.
+func signal(frame, channel, bits int) int64 {
+	amp := 1 << (bits - 2)
+	p := 300 + 70*channel
+	ph := frame % p
+	tri := (4*amp*abs(2*ph-p))/(2*p) - amp
+	h := uint32(frame)*2654435761 + uint32(channel)*40503
+	h ^= h >> 13
+	noise := int(h%256) - 128
+	v := tri + noise<<(bits-12)
+	switch frame / 4096 {
+	case 2:
+		if channel == 1 {
+			return 0
+		}
+	case 3:
+		v &^= 7
+	}
+	return int64(v)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		file     string
+		rate     int
+		channels int
+		bits     int
+		frames   int
+		// gains mixes the channels down to stereo
+		gains [][2]float32
+	}{
+		{"test_stereo.flac", 44100, 2, 16, 4096*7 + 1000, [][2]float32{{1, 0}, {0, 1}}},
+		{"test_24bit.flac", 48000, 1, 24, 4096*7 + 200, [][2]float32{{1, 1}}},
+		{"test_surround.flac", 8000, 6, 16, 2000, [][2]float32{{1, 0}, {0, 1}, {0.7071, 0.7071}, {0, 0}, {0.7071, 0}, {0, 0.7071}}},
+	}
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			data, err := flac.LoadFileWithOptions(c.file, c.rate, flac.Options{CheckMD5: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != 2*c.frames {
+				t.Fatalf("should have decoded %d frames but got %d", c.frames, len(data)/2)
+			}
+			scale := float32(int(1) << (c.bits - 1))
+			for i := 0; i < c.frames; i++ {
+				var l, r float32
+				for ch, g := range c.gains {
+					v := float32(signal(i, ch, c.bits)) / scale
+					l += v * g[0]
+					r += v * g[1]
+				}
+				if data[2*i] != l || data[2*i+1] != r {
+					t.Fatalf("frame %d should be %v, %v but was %v, %v", i, l, r, data[2*i], data[2*i+1])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadResampled(t *testing.T) {
+	data, err := flac.LoadFile("test_surround.flac", 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames := len(data) / 2; frames < 2000*44100/8000-1 || frames > 2000*44100/8000+1 {
+		t.Errorf("should have resampled 2000 frames at 8 kHz to about %d frames but got %d", 2000*44100/8000, frames)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	valid, err := os.ReadFile("test_stereo.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(offset int) []byte {
+		b := append([]byte(nil), valid...)
+		b[offset] ^= 0x10
+		return b
+	}
+	// the STREAMINFO block is 8 bytes in, and its MD5 signature ends it
+	const md5Offset = 8 + 18
+	cases := []struct {
+		name    string
+		data    []byte
+		options flac.Options
+		want    error
+	}{
+		{"empty", nil, flac.Options{}, flac.ErrTruncated},
+		{"not flac", append([]byte("OggS"), valid[4:]...), flac.Options{}, flac.ErrInvalidHeader},
+		{"no streaminfo", []byte("fLaC\x81\x00\x00\x00"), flac.Options{}, flac.ErrInvalidHeader},
+		{"half downloaded", valid[:len(valid)/2], flac.Options{}, flac.ErrTruncated},
+		{"corrupt audio", corrupt(len(valid) / 2), flac.Options{}, flac.ErrChecksum},
+		{"wrong md5", corrupt(md5Offset), flac.Options{CheckMD5: true}, flac.ErrChecksum},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := flac.LoadWithOptions(c.data, 44100, c.options)
+			if !errors.Is(err, c.want) {
+				t.Errorf("should have returned %v but got %v", c.want, err)
+			}
+		})
+	}
+
+	if _, err := flac.Load(corrupt(md5Offset), 44100); err != nil {
+		t.Errorf("the MD5 signature should only be checked when requested, but got %v", err)
+	}
+}
+
+func TestID3v2(t *testing.T) {
+	valid, err := os.ReadFile("test_surround.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x05abcde"), valid...)
+	if _, err := flac.Load(tagged, 8000); err != nil {
+		t.Errorf("should skip an ID3v2 tag, but got %v", err)
+	}
+}
+
+func FuzzLoad(f *testing.F) {
+	for _, name := range []string{"test_stereo.flac", "test_24bit.flac", "test_surround.flac"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		// the metadata and the first frames
+		f.Add(data[:min(len(data), 2048)])
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		samples, err := flac.LoadWithOptions(data, 44100, flac.Options{CheckMD5: true})
+		if err != nil {
+			if !errors.Is(err, flac.ErrTruncated) && !errors.Is(err, flac.ErrInvalidHeader) &&
+				!errors.Is(err, flac.ErrInvalidFrame) && !errors.Is(err, flac.ErrChecksum) {
+				t.Errorf("unexpected error type: %v", err)
+			}
+			return
+		}
+		if len(samples)%2 != 0 {
+			t.Errorf("the samples should be stereo but %d were decoded", len(samples))
+		}
+	})
+}
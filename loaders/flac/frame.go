@@ -0,0 +1,259 @@
+package flac
+
+import (
+	"fmt"
+	"hash"
+)
+
+// decoder decodes the audio frames of a file into interleaved stereo.
+type decoder struct {
+	info     streamInfo
+	channels [][]int64
+	gains    [][2]float32
+	out      []float32
+
+	// hash is the MD5 signature of the decoded audio, nil if it isn't checked
+	hash   hash.Hash
+	md5Buf []byte
+}
+
+// newDecoder prepares decoding the frames of a file of size bytes.
+func newDecoder(info streamInfo, size int) *decoder {
+	d := &decoder{
+		info:     info,
+		channels: make([][]int64, info.channelCount),
+		gains:    stereoGains(info.channelCount),
+	}
+	if info.totalFrames > 0 {
+		// a corrupt header can claim any length, and music doesn't compress to less than a byte per frame
+		d.out = make([]float32, 0, 2*min(info.totalFrames, int64(size)))
+	}
+	return d
+}
+
+// channel assignments of the frame header
+const (
+	channelsLeftSide  = 8
+	channelsSideRight = 9
+	channelsMidSide   = 10
+)
+
+// frameHeader is the header of an audio frame.
+type frameHeader struct {
+	blockSize         int
+	bitsPerSample     int
+	channelAssignment int
+}
+
+func (d *decoder) decodeFrames(data []byte) error {
+	var decoded int64
+	for len(data) > 0 {
+		if d.info.totalFrames > 0 && decoded >= d.info.totalFrames {
+			// e.g. an ID3v1 tag at the end
+			break
+		}
+		n, blockSize, err := d.decodeFrame(data)
+		if err != nil {
+			return fmt.Errorf("frame at sample %d: %w", decoded, err)
+		}
+		data = data[n:]
+		decoded += int64(blockSize)
+	}
+	if decoded < d.info.totalFrames {
+		return fmt.Errorf("%w: %d of %d samples", ErrTruncated, decoded, d.info.totalFrames)
+	}
+	if d.info.totalFrames > 0 && decoded > d.info.totalFrames {
+		// the last frame is padded
+		d.out = d.out[:2*d.info.totalFrames]
+	}
+	return nil
+}
+
+// decodeFrame decodes the frame at the start of data, and returns its size in bytes and its number of samples.
+func (d *decoder) decodeFrame(data []byte) (int, int, error) {
+	r := bitReader{buf: data}
+	h, err := d.readFrameHeader(&r)
+	if err != nil {
+		return 0, 0, err
+	}
+	for c, ch := range d.channels {
+		if len(ch) < h.blockSize {
+			d.channels[c] = make([]int64, h.blockSize)
+		}
+	}
+
+	for c := 0; c < d.info.channelCount; c++ {
+		bitsPerSample := h.bitsPerSample
+		// the side channel needs an extra bit
+		switch {
+		case h.channelAssignment == channelsLeftSide && c == 1,
+			h.channelAssignment == channelsSideRight && c == 0,
+			h.channelAssignment == channelsMidSide && c == 1:
+			bitsPerSample++
+		}
+		if err := readSubframe(&r, d.channels[c][:h.blockSize], uint(bitsPerSample)); err != nil {
+			return 0, 0, err
+		}
+		if r.truncated {
+			return 0, 0, ErrTruncated
+		}
+	}
+	r.align()
+	end := r.offset()
+	crc := uint16(r.read(16))
+	if r.truncated {
+		return 0, 0, ErrTruncated
+	}
+	if crc16(data[:end]) != crc {
+		return 0, 0, fmt.Errorf("%w: CRC-16 of the frame", ErrChecksum)
+	}
+
+	d.decorrelate(h)
+	if d.hash != nil {
+		d.writeSamples(d.channels, h.blockSize)
+	}
+	d.mixToStereo(h)
+	return end + 2, h.blockSize, nil
+}
+
+// blockSizes and sampleRates are the values that the frame header codes stand for, 0 where the value follows the header.
+var (
+	blockSizes    = [16]int{0, 192, 576, 1152, 2304, 4608, 0, 0, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768}
+	sampleSizes   = [8]int{0, 8, 12, 0, 16, 20, 24, 32}
+	sampleSizeSet = [8]bool{true, true, true, false, true, true, true, true}
+)
+
+func (d *decoder) readFrameHeader(r *bitReader) (frameHeader, error) {
+	if sync := r.read(14); sync != 0x3FFE {
+		return frameHeader{}, fmt.Errorf("%w: frame sync code not found", ErrInvalidFrame)
+	}
+	r.read(2) // reserved bit and blocking strategy
+	blockSizeCode := r.read(4)
+	sampleRateCode := r.read(4)
+	h := frameHeader{channelAssignment: int(r.read(4))}
+	sampleSizeCode := r.read(3)
+	r.read(1) // reserved
+
+	// the frame or sample number, UTF-8 coded
+	first := r.read(8)
+	for mask := uint64(0x40); first&0x80 != 0 && first&mask != 0 && mask > 1; mask >>= 1 {
+		r.read(8)
+	}
+
+	switch blockSizeCode {
+	case 0:
+		return frameHeader{}, fmt.Errorf("%w: reserved block size", ErrInvalidFrame)
+	case 6:
+		h.blockSize = int(r.read(8)) + 1
+	case 7:
+		h.blockSize = int(r.read(16)) + 1
+	default:
+		h.blockSize = blockSizes[blockSizeCode]
+	}
+	switch sampleRateCode {
+	case 12:
+		r.read(8)
+	case 13, 14:
+		r.read(16)
+	case 15:
+		return frameHeader{}, fmt.Errorf("%w: invalid sample rate", ErrInvalidFrame)
+	}
+	// only the sample rate of the STREAMINFO block is used, a file can't change it
+
+	if !sampleSizeSet[sampleSizeCode] {
+		return frameHeader{}, fmt.Errorf("%w: reserved sample size", ErrInvalidFrame)
+	}
+	h.bitsPerSample = sampleSizes[sampleSizeCode]
+	if h.bitsPerSample == 0 {
+		h.bitsPerSample = d.info.bitsPerSample
+	}
+
+	end := r.offset()
+	crc := uint8(r.read(8))
+	if r.truncated {
+		return frameHeader{}, ErrTruncated
+	}
+	if crc8(r.buf[:end]) != crc {
+		return frameHeader{}, fmt.Errorf("%w: CRC-8 of the frame header", ErrChecksum)
+	}
+
+	if h.blockSize > d.info.maxBlockSize {
+		return frameHeader{}, fmt.Errorf("%w: block size %d is larger than the maximum %d", ErrInvalidFrame, h.blockSize, d.info.maxBlockSize)
+	}
+	if h.bitsPerSample != d.info.bitsPerSample {
+		return frameHeader{}, fmt.Errorf("%w: %d bits per sample but the stream has %d", ErrInvalidFrame, h.bitsPerSample, d.info.bitsPerSample)
+	}
+	channelCount := h.channelAssignment + 1
+	if h.channelAssignment >= channelsLeftSide {
+		channelCount = 2
+	}
+	if h.channelAssignment > channelsMidSide || channelCount != d.info.channelCount {
+		return frameHeader{}, fmt.Errorf("%w: channel assignment %d for %d channels", ErrInvalidFrame, h.channelAssignment, d.info.channelCount)
+	}
+	return h, nil
+}
+
+// decorrelate restores left and right from the side channel.
+func (d *decoder) decorrelate(h frameHeader) {
+	if h.channelAssignment < channelsLeftSide {
+		return
+	}
+	left, right := d.channels[0][:h.blockSize], d.channels[1][:h.blockSize]
+	for i := range left {
+		switch h.channelAssignment {
+		case channelsLeftSide:
+			right[i] = left[i] - right[i]
+		case channelsSideRight:
+			left[i] += right[i]
+		case channelsMidSide:
+			mid, side := left[i]<<1|right[i]&1, right[i]
+			left[i] = (mid + side) >> 1
+			right[i] = (mid - side) >> 1
+		}
+	}
+}
+
+func (d *decoder) mixToStereo(h frameHeader) {
+	scale := 1 / float32(int64(1)<<(d.info.bitsPerSample-1))
+	for i := 0; i < h.blockSize; i++ {
+		var l, r float32
+		for c, ch := range d.channels {
+			v := float32(ch[i]) * scale
+			l += v * d.gains[c][0]
+			r += v * d.gains[c][1]
+		}
+		d.out = append(d.out, l, r)
+	}
+}
+
+// stereoGains returns how much each channel contributes to the left and the right output,
+// for the channel orders that FLAC defines. The LFE channel is dropped.
+func stereoGains(channelCount int) [][2]float32 {
+	const half = 0.7071 // -3 dB
+	var (
+		left   = [2]float32{1, 0}
+		right  = [2]float32{0, 1}
+		center = [2]float32{half, half}
+		lfe    = [2]float32{}
+		sideL  = [2]float32{half, 0}
+		sideR  = [2]float32{0, half}
+	)
+	switch channelCount {
+	case 1:
+		return [][2]float32{{1, 1}}
+	case 2:
+		return [][2]float32{left, right}
+	case 3:
+		return [][2]float32{left, right, center}
+	case 4:
+		return [][2]float32{left, right, sideL, sideR}
+	case 5:
+		return [][2]float32{left, right, center, sideL, sideR}
+	case 6:
+		return [][2]float32{left, right, center, lfe, sideL, sideR}
+	case 7:
+		return [][2]float32{left, right, center, lfe, center, sideL, sideR}
+	default:
+		return [][2]float32{left, right, center, lfe, sideL, sideR, sideL, sideR}
+	}
+}
@@ -0,0 +1,218 @@
+package flac
+
+import "fmt"
+
+// subframe types
+const (
+	subframeConstant = 0
+	subframeVerbatim = 1
+	subframeFixed    = 8  // 0b001xxx, xxx is the order
+	subframeLPC      = 32 // 0b1xxxxx, xxxxx is the order - 1
+)
+
+// readSubframe decodes the samples of one channel into out.
+func readSubframe(r *bitReader, out []int64, bitsPerSample uint) error {
+	if r.read(1) != 0 {
+		return fmt.Errorf("%w: subframe padding bit is set", ErrInvalidFrame)
+	}
+	subframeType := r.read(6)
+	var wasted uint
+	if r.read(1) == 1 {
+		wasted = uint(r.readUnary()) + 1
+		if wasted >= bitsPerSample {
+			return fmt.Errorf("%w: %d wasted bits of %d", ErrInvalidFrame, wasted, bitsPerSample)
+		}
+		bitsPerSample -= wasted
+	}
+
+	var err error
+	switch {
+	case subframeType == subframeConstant:
+		v := r.readSigned(bitsPerSample)
+		for i := range out {
+			out[i] = v
+		}
+	case subframeType == subframeVerbatim:
+		for i := range out {
+			out[i] = r.readSigned(bitsPerSample)
+		}
+	case subframeType >= subframeLPC:
+		err = readLPC(r, out, int(subframeType-subframeLPC)+1, bitsPerSample)
+	case subframeType >= subframeFixed && subframeType <= subframeFixed+4:
+		err = readFixed(r, out, int(subframeType-subframeFixed), bitsPerSample)
+	default:
+		err = fmt.Errorf("%w: reserved subframe type %#x", ErrInvalidFrame, subframeType)
+	}
+	if err != nil {
+		return err
+	}
+
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+// readWarmup reads the first samples, which are stored as they are.
+func readWarmup(r *bitReader, out []int64, order int, bitsPerSample uint) error {
+	if order > len(out) {
+		return fmt.Errorf("%w: predictor order %d is larger than the block size %d", ErrInvalidFrame, order, len(out))
+	}
+	for i := 0; i < order; i++ {
+		out[i] = r.readSigned(bitsPerSample)
+	}
+	return nil
+}
+
+// readFixed decodes a subframe with one of the fixed polynomial predictors.
+func readFixed(r *bitReader, out []int64, order int, bitsPerSample uint) error {
+	if err := readWarmup(r, out, order, bitsPerSample); err != nil {
+		return err
+	}
+	if err := readResidual(r, out, order); err != nil {
+		return err
+	}
+	switch order {
+	case 1:
+		for i := 1; i < len(out); i++ {
+			out[i] += out[i-1]
+		}
+	case 2:
+		for i := 2; i < len(out); i++ {
+			out[i] += 2*out[i-1] - out[i-2]
+		}
+	case 3:
+		for i := 3; i < len(out); i++ {
+			out[i] += 3*out[i-1] - 3*out[i-2] + out[i-3]
+		}
+	case 4:
+		for i := 4; i < len(out); i++ {
+			out[i] += 4*out[i-1] - 6*out[i-2] + 4*out[i-3] - out[i-4]
+		}
+	}
+	return nil
+}
+
+// readLPC decodes a subframe with a linear predictor whose coefficients are stored in the subframe.
+func readLPC(r *bitReader, out []int64, order int, bitsPerSample uint) error {
+	if err := readWarmup(r, out, order, bitsPerSample); err != nil {
+		return err
+	}
+	precision := uint(r.read(4)) + 1
+	if precision == 16 {
+		return fmt.Errorf("%w: invalid LPC coefficient precision", ErrInvalidFrame)
+	}
+	shift := r.readSigned(5)
+	if shift < 0 {
+		return fmt.Errorf("%w: negative LPC shift %d", ErrInvalidFrame, shift)
+	}
+	var coeffs [32]int64
+	for i := 0; i < order; i++ {
+		coeffs[i] = r.readSigned(precision)
+	}
+	if err := readResidual(r, out, order); err != nil {
+		return err
+	}
+	for i := order; i < len(out); i++ {
+		var sum int64
+		for j, c := range coeffs[:order] {
+			sum += c * out[i-1-j]
+		}
+		out[i] += sum >> shift
+	}
+	return nil
+}
+
+// readResidual reads the Rice coded prediction errors of the samples after the warmup into out.
+func readResidual(r *bitReader, out []int64, order int) error {
+	var paramBits uint
+	switch method := r.read(2); method {
+	case 0:
+		paramBits = 4
+	case 1:
+		paramBits = 5
+	default:
+		return fmt.Errorf("%w: reserved residual coding method %d", ErrInvalidFrame, method)
+	}
+	escape := uint64(1)<<paramBits - 1
+
+	partitionOrder := r.read(4)
+	partitions := 1 << partitionOrder
+	if len(out)%partitions != 0 || len(out)/partitions < order {
+		return fmt.Errorf("%w: %d residual partitions for %d samples", ErrInvalidFrame, partitions, len(out))
+	}
+	partitionSize := len(out) / partitions
+
+	i := order
+	for p := 0; p < partitions; p++ {
+		end := (p + 1) * partitionSize
+		param := r.read(paramBits)
+		if param == escape {
+			// the partition is stored without Rice coding
+			n := uint(r.read(5))
+			for ; i < end; i++ {
+				out[i] = r.readSigned(n)
+			}
+			continue
+		}
+		for ; i < end; i++ {
+			u := r.readUnary()<<param | r.read(uint(param))
+			// zigzag
+			out[i] = int64(u>>1) ^ -int64(u&1)
+		}
+		if r.truncated {
+			return ErrTruncated
+		}
+	}
+	return nil
+}
+
+// crc8 is the CRC of the frame header, with the polynomial x^8 + x^2 + x + 1.
+func crc8(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		crc = crc8Table[crc^b]
+	}
+	return crc
+}
+
+// crc16 is the CRC of a whole frame, with the polynomial x^16 + x^15 + x^2 + 1.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+var (
+	crc8Table  [256]uint8
+	crc16Table [256]uint16
+)
+
+func init() {
+	for i := range crc8Table {
+		crc := uint8(i)
+		for j := 0; j < 8; j++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+		crc8Table[i] = crc
+	}
+	for i := range crc16Table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+		crc16Table[i] = crc
+	}
+}
@@ -0,0 +1,54 @@
+package loaders
+
+import (
+	"bytes"
+
+	"github.com/Lundis/go-gameaudio/loaders/flac"
+	"github.com/Lundis/go-gameaudio/loaders/mp3"
+	"github.com/Lundis/go-gameaudio/loaders/oggvorbis"
+	"github.com/Lundis/go-gameaudio/loaders/wav"
+)
+
+// The versions of the built-in formats are increased when their decoders or the resampler change the output.
+func init() {
+	Register(Format{
+		Name:     "wav",
+		Version:  1,
+		MIMEType: "audio/wav",
+		Match: func(header []byte) bool {
+			return len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE"))
+		},
+		Decode: wav.LoadWav,
+	})
+	Register(Format{
+		Name:     "ogg",
+		Version:  1,
+		MIMEType: "audio/ogg",
+		Match: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("OggS"))
+		},
+		Decode: oggvorbis.Load,
+		NewStream: func(raw []byte, sampleRate int) (Stream, error) {
+			return oggvorbis.NewStream(bytes.NewReader(raw), sampleRate)
+		},
+	})
+	Register(Format{
+		Name:     "flac",
+		Version:  1,
+		MIMEType: "audio/flac",
+		Match: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("fLaC"))
+		},
+		Decode: flac.Load,
+	})
+	Register(Format{
+		Name:     "mp3",
+		Version:  1,
+		MIMEType: "audio/mpeg",
+		Match: func(header []byte) bool {
+			// the frame sync, and Layer III
+			return len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 && (header[1]>>1)&3 == 1
+		},
+		Decode: mp3.Load,
+	})
+}
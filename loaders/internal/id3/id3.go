@@ -0,0 +1,22 @@
+// Package id3 handles the ID3 tags that taggers put in front of audio files.
+package id3
+
+import "bytes"
+
+// SkipV2 skips an ID3v2 tag, which taggers put in front of MP3 and sometimes FLAC files.
+// Data without a complete tag is returned as it is.
+func SkipV2(data []byte) []byte {
+	if len(data) < 10 || !bytes.Equal(data[:3], []byte("ID3")) {
+		return data
+	}
+	// the size is stored in 7 bits per byte
+	size := 10 + (int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F))
+	if data[5]&0x10 != 0 {
+		// footer
+		size += 10
+	}
+	if size > len(data) {
+		return data
+	}
+	return data[size:]
+}
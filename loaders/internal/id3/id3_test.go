@@ -0,0 +1,27 @@
+package id3_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/loaders/internal/id3"
+)
+
+func TestSkipV2(t *testing.T) {
+	// a tag of 2 bytes
+	tagged := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x02ab"), "fLaC"...)
+	if rest := id3.SkipV2(tagged); string(rest) != "fLaC" {
+		t.Errorf("should have skipped the tag to fLaC but got %q", rest)
+	}
+	// and one of 0x80 bytes, which is stored as 0x01 0x00, with a footer
+	withFooter := append(append([]byte("ID3\x04\x00\x10\x00\x00\x01\x00"), make([]byte, 0x80+10)...), "fLaC"...)
+	if rest := id3.SkipV2(withFooter); string(rest) != "fLaC" {
+		t.Errorf("should have skipped the tag and its footer to fLaC but got %q", rest)
+	}
+
+	for _, data := range [][]byte{[]byte("fLaC"), []byte("ID3\x04\x00\x00\x00\x00\x01\x00short")} {
+		if rest := id3.SkipV2(data); !bytes.Equal(rest, data) {
+			t.Errorf("%q should be left as it is but got %q", data, rest)
+		}
+	}
+}
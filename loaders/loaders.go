@@ -0,0 +1,195 @@
+// Package loaders decodes audio files of any registered format, which it detects from the first bytes of the file.
+// WAV, Ogg Vorbis, FLAC and MP3 are registered from the start.
+package loaders
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Lundis/go-gameaudio/loaders/internal/id3"
+	"github.com/Lundis/go-gameaudio/loaders/loudness"
+)
+
+// ErrUnknownFormat is returned for data that no registered format matches.
+var ErrUnknownFormat = errors.New("loaders: unknown audio format")
+
+// Format is an audio file format that Decode can detect and decode.
+type Format struct {
+	// Name identifies the format in errors, e.g. "wav".
+	Name string
+
+	// MIMEType is the media type of the format, for browsers that decode the file themselves.
+	MIMEType string
+
+	// Version identifies the output of Decode in the cache. Increase it when a change to the decoder
+	// changes the decoded samples, so that cached entries of the old version are decoded again.
+	Version int
+
+	// Match returns whether header, the start of a file after any ID3v2 tag, is in this format.
+	Match func(header []byte) bool
+
+	// Decode decodes a whole file to interleaved stereo at sampleRate.
+	Decode func(raw []byte, sampleRate int) ([]float32, error)
+
+	// NewStream prepares a file to be decoded while it plays, at sampleRate. It is optional;
+	// formats without it are decoded by NewStream up front.
+	NewStream func(raw []byte, sampleRate int) (Stream, error)
+}
+
+// Stream is a file that is decoded while it plays. It has the methods of audio.StreamSource.
+type Stream interface {
+	// Read decodes the next interleaved stereo frames into buf, and returns the number of samples.
+	// At the end it returns io.EOF.
+	Read(buf []float32) (int, error)
+
+	// Seek moves to the given frame.
+	Seek(frame int) error
+
+	// Length returns the number of frames.
+	Length() int
+}
+
+var (
+	formatsLock sync.RWMutex
+	formats     []Format
+)
+
+// Register adds a format. Formats that are registered later are tried first,
+// so an application can replace the decoder of a built-in format.
+// Match and Decode are required.
+func Register(format Format) {
+	if format.Match == nil || format.Decode == nil {
+		panic("loaders: Register needs Match and Decode")
+	}
+	formatsLock.Lock()
+	defer formatsLock.Unlock()
+	formats = append(formats, format)
+}
+
+// Detect returns the format of raw.
+func Detect(raw []byte) (Format, error) {
+	header := id3.SkipV2(raw)
+	formatsLock.RLock()
+	defer formatsLock.RUnlock()
+	for i := len(formats) - 1; i >= 0; i-- {
+		if formats[i].Match(header) {
+			return formats[i], nil
+		}
+	}
+	return Format{}, ErrUnknownFormat
+}
+
+// Decode decodes a file of any registered format to interleaved stereo at sampleRate.
+func Decode(raw []byte, sampleRate int) ([]float32, error) {
+	format, err := Detect(raw)
+	if err != nil {
+		return nil, err
+	}
+	return decode(format, raw, sampleRate)
+}
+
+// decode decodes raw in format, through the cache if there is one.
+func decode(format Format, raw []byte, sampleRate int) ([]float32, error) {
+	cache := getCache()
+	var hash [sha256.Size]byte
+	if cache != nil {
+		hash = sha256.Sum256(raw)
+		if data, ok := cache.load(format, hash, sampleRate); ok {
+			return data, nil
+		}
+	}
+	data, err := format.Decode(raw, sampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", format.Name, err)
+	}
+	if cache != nil {
+		_ = cache.store(format, hash, sampleRate, data)
+	}
+	return data, nil
+}
+
+// NewStream prepares a file of any registered format to be decoded while it plays, at sampleRate.
+// Formats that can't be streamed are decoded here, and played from memory.
+func NewStream(raw []byte, sampleRate int) (Stream, error) {
+	format, err := Detect(raw)
+	if err != nil {
+		return nil, err
+	}
+	if format.NewStream != nil {
+		stream, err := format.NewStream(raw, sampleRate)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", format.Name, err)
+		}
+		return stream, nil
+	}
+	data, err := decode(format, raw, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryStream{data: data}, nil
+}
+
+// Loudness measures a file of any registered format at sampleRate, see loudness.Measure.
+// The whole file is decoded for it, which takes a while for music, so the result is kept in the cache if there is one.
+func Loudness(raw []byte, sampleRate int) (loudness.Loudness, error) {
+	format, err := Detect(raw)
+	if err != nil {
+		return loudness.Loudness{}, err
+	}
+	cache := getCache()
+	var hash [sha256.Size]byte
+	if cache != nil {
+		hash = sha256.Sum256(raw)
+		if l, ok := cache.loadLoudness(format, hash, sampleRate); ok {
+			return l, nil
+		}
+	}
+	stream, err := NewStream(raw, sampleRate)
+	if err != nil {
+		return loudness.Loudness{}, err
+	}
+	m := loudness.NewMeter(2, sampleRate)
+	buf := make([]float32, 8192)
+	for {
+		n, err := stream.Read(buf)
+		m.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return loudness.Loudness{}, fmt.Errorf("%s: %w", format.Name, err)
+		}
+	}
+	l := m.Loudness()
+	if cache != nil {
+		_ = cache.storeLoudness(format, hash, sampleRate, l)
+	}
+	return l, nil
+}
+
+// memoryStream plays decoded interleaved stereo.
+type memoryStream struct {
+	data []float32
+	pos  int
+}
+
+func (s *memoryStream) Read(buf []float32) (int, error) {
+	if s.pos >= len(s.data) {
+		return 0, io.EOF
+	}
+	n := copy(buf[:len(buf)&^1], s.data[s.pos:])
+	s.pos += n
+	return n, nil
+}
+
+func (s *memoryStream) Seek(frame int) error {
+	s.pos = min(max(frame, 0), s.Length()) * 2
+	return nil
+}
+
+func (s *memoryStream) Length() int {
+	return len(s.data) / 2
+}
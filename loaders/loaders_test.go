@@ -0,0 +1,138 @@
+package loaders_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/loaders"
+	"github.com/Lundis/go-gameaudio/loaders/flac"
+	"github.com/Lundis/go-gameaudio/loaders/mp3"
+	"github.com/Lundis/go-gameaudio/loaders/oggvorbis"
+	"github.com/Lundis/go-gameaudio/loaders/wav"
+)
+
+var files = []struct {
+	path   string
+	format string
+	load   func(raw []byte, sampleRate int) ([]float32, error)
+}{
+	{"wav/test_stereo.wav", "wav", wav.LoadWav},
+	{"oggvorbis/test_stereo.ogg", "ogg", oggvorbis.Load},
+	{"flac/test_stereo.flac", "flac", flac.Load},
+	// starts with an ID3v2 tag
+	{"mp3/test_stereo.mp3", "mp3", mp3.Load},
+}
+
+func TestDecode(t *testing.T) {
+	for _, f := range files {
+		t.Run(f.format, func(t *testing.T) {
+			raw, err := os.ReadFile(f.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			format, err := loaders.Detect(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format.Name != f.format {
+				t.Errorf("should have detected %s but got %s", f.format, format.Name)
+			}
+
+			data, err := loaders.Decode(raw, 44100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := f.load(raw, 44100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != len(want) {
+				t.Fatalf("should have decoded %d samples like the loader of the format but got %d", len(want), len(data))
+			}
+
+			// the stream plays the same frames, whether it is decoded up front or while it plays
+			stream, err := loaders.NewStream(raw, 44100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stream.Length() != len(want)/2 {
+				t.Errorf("the stream should have %d frames but has %d", len(want)/2, stream.Length())
+			}
+			streamed := readAll(t, stream)
+			if len(streamed) != len(want) {
+				t.Fatalf("should have streamed %d samples but got %d", len(want), len(streamed))
+			}
+			for i := range want {
+				if streamed[i] != want[i] {
+					t.Fatalf("sample %d should be %v but was %v", i, want[i], streamed[i])
+				}
+			}
+		})
+	}
+}
+
+func readAll(t *testing.T, stream loaders.Stream) []float32 {
+	var out []float32
+	buf := make([]float32, 1000)
+	for {
+		n, err := stream.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	for _, raw := range [][]byte{nil, []byte("MThd\x00\x00\x00\x06"), []byte("ID3\x04\x00\x00\x00\x00\x00\x00")} {
+		if _, err := loaders.Decode(raw, 44100); !errors.Is(err, loaders.ErrUnknownFormat) {
+			t.Errorf("%q should be an unknown format but got %v", raw, err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	errCorrupt := errors.New("corrupt")
+	loaders.Register(loaders.Format{
+		Name:  "test",
+		Match: func(header []byte) bool { return len(header) >= 4 && string(header[:4]) == "TEST" },
+		Decode: func(raw []byte, sampleRate int) ([]float32, error) {
+			if len(raw) < 5 {
+				return nil, errCorrupt
+			}
+			return make([]float32, 2*int(raw[4])), nil
+		},
+	})
+
+	data, err := loaders.Decode([]byte("TEST\x03"), 44100)
+	if err != nil || len(data) != 6 {
+		t.Errorf("should have decoded 3 frames of the registered format but got %d, %v", len(data)/2, err)
+	}
+	if _, err := loaders.Decode([]byte("TEST"), 44100); !errors.Is(err, errCorrupt) {
+		t.Errorf("should return the error of the decoder but got %v", err)
+	}
+	stream, err := loaders.NewStream([]byte("TEST\x03"), 44100)
+	if err != nil || stream.Length() != 3 {
+		t.Errorf("formats without streaming should be decoded into a stream, but got %v", err)
+	}
+}
+
+func TestRegisterOverrides(t *testing.T) {
+	// a header that the built-in WAV format matches too; the real test files have a RIFF size
+	raw := []byte("RIFF\x00\x00\x00\x00WAVE")
+	loaders.Register(loaders.Format{
+		Name:   "empty wav",
+		Match:  func(header []byte) bool { return bytes.HasPrefix(header, raw) },
+		Decode: func(raw []byte, sampleRate int) ([]float32, error) { return nil, nil },
+	})
+	format, err := loaders.Detect(raw)
+	if err != nil || format.Name != "empty wav" {
+		t.Errorf("the format that was registered last should win, but got %q, %v", format.Name, err)
+	}
+}
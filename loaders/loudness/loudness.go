@@ -0,0 +1,227 @@
+// Package loudness measures how loud audio is perceived, as EBU R128 does with ITU-R BS.1770:
+// the gated integrated loudness in LUFS and the true peak in dBTP. Sounds from different sources
+// can then be brought to the same loudness with Loudness.Gain.
+package loudness
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Loudness is the result of a measurement.
+type Loudness struct {
+	// Integrated is the loudness of the whole sound in LUFS, without the silence and quiet parts that the gates of
+	// BS.1770 leave out. It is -Inf for silence.
+	Integrated float64
+
+	// TruePeak is the highest level of the sound in dBTP, including the peaks between the samples that
+	// a digital to analog converter reconstructs. It is -Inf for silence.
+	TruePeak float64
+}
+
+// MaxTruePeak is the highest true peak that Gain raises a sound to, which EBU R128 recommends for distribution.
+const MaxTruePeak = -1
+
+// Gain returns the factor that brings the sound to the target loudness in LUFS, e.g. -23 for EBU R128 or -16 for
+// games on consoles. Quiet sounds with loud peaks are raised less, so that their true peak stays at MaxTruePeak.
+// Silence keeps its gain of 1.
+func (l Loudness) Gain(target float64) float32 {
+	if math.IsInf(l.Integrated, -1) || math.IsNaN(l.Integrated) {
+		return 1
+	}
+	gain := target - l.Integrated
+	if !math.IsInf(l.TruePeak, -1) && gain > 0 {
+		gain = max(min(gain, MaxTruePeak-l.TruePeak), 0)
+	}
+	return float32(math.Pow(10, gain/20))
+}
+
+// ReplayGain reads the loudness from the REPLAYGAIN_TRACK_GAIN and REPLAYGAIN_TRACK_PEAK tags
+// of a file, e.g. the Vorbis comments of oggvorbis.Metadata, which saves measuring it.
+// The gain is relative to the -18 LUFS reference of ReplayGain 2.0. Without a peak tag the true peak is taken to be
+// 0 dBTP, so that the gain never makes the file clip.
+func ReplayGain(comments map[string][]string) (Loudness, bool) {
+	tag := func(field string) (float64, bool) {
+		values := comments[field]
+		if len(values) == 0 {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(values[0]), "dB")), 64)
+		return v, err == nil && !math.IsInf(v, 0) && !math.IsNaN(v)
+	}
+	gain, ok := tag("REPLAYGAIN_TRACK_GAIN")
+	if !ok {
+		return Loudness{}, false
+	}
+	l := Loudness{Integrated: -18 - gain}
+	if peak, ok := tag("REPLAYGAIN_TRACK_PEAK"); ok && peak >= 0 {
+		l.TruePeak = 20 * math.Log10(peak)
+	}
+	return l, true
+}
+
+// Measure measures interleaved samples with the given number of channels, which are weighted equally.
+func Measure(samples []float32, channelCount, sampleRate int) Loudness {
+	m := NewMeter(channelCount, sampleRate)
+	m.Write(samples)
+	return m.Loudness()
+}
+
+// Meter measures a sound that is written to it in parts, e.g. while a stream is decoded.
+type Meter struct {
+	channelCount int
+	filters      []kWeighting
+	truePeak     truePeak
+
+	// the mean squares of the K-weighted channels of every 100 ms, of which BS.1770 measures blocks of 400 ms
+	subBlocks    []float64
+	subBlock     float64
+	subBlockPos  int
+	subBlockSize int
+
+	// energy is the sum of the squares of all frames, for sounds that are shorter than a block
+	energy float64
+	frames int
+}
+
+// NewMeter returns a meter for interleaved samples with the given number of channels at sampleRate.
+func NewMeter(channelCount, sampleRate int) *Meter {
+	m := &Meter{
+		channelCount: channelCount,
+		filters:      make([]kWeighting, channelCount),
+		truePeak:     newTruePeak(channelCount),
+		subBlockSize: max(sampleRate/10, 1),
+	}
+	for i := range m.filters {
+		m.filters[i] = newKWeighting(float64(sampleRate))
+	}
+	return m
+}
+
+// Write measures the next samples, which must be whole frames.
+func (m *Meter) Write(samples []float32) {
+	for i := 0; i+m.channelCount <= len(samples); i += m.channelCount {
+		var sum float64
+		for c := range m.channelCount {
+			v := float64(samples[i+c])
+			m.truePeak.write(c, v)
+			y := m.filters[c].process(v)
+			sum += y * y
+		}
+		m.truePeak.advance()
+		m.energy += sum
+		m.frames++
+		m.subBlock += sum
+		m.subBlockPos++
+		if m.subBlockPos == m.subBlockSize {
+			m.subBlocks = append(m.subBlocks, m.subBlock/float64(m.subBlockSize))
+			m.subBlock = 0
+			m.subBlockPos = 0
+		}
+	}
+}
+
+// blocks returns the mean squares of the blocks of 400 ms, which overlap by 75%.
+// A sound that is shorter than a block is a single block.
+func (m *Meter) blocks() []float64 {
+	if len(m.subBlocks) < 4 {
+		if m.frames == 0 {
+			return nil
+		}
+		return []float64{m.energy / float64(m.frames)}
+	}
+	blocks := make([]float64, len(m.subBlocks)-3)
+	for i := range blocks {
+		blocks[i] = (m.subBlocks[i] + m.subBlocks[i+1] + m.subBlocks[i+2] + m.subBlocks[i+3]) / 4
+	}
+	return blocks
+}
+
+// Loudness returns the measurement of everything that was written.
+func (m *Meter) Loudness() Loudness {
+	l := Loudness{
+		Integrated: math.Inf(-1),
+		TruePeak:   20 * math.Log10(m.truePeak.peak),
+	}
+	blocks := m.blocks()
+	// the absolute gate leaves out silence, and the relative gate the parts that are 10 LU quieter than the rest
+	gate := fromLUFS(-70)
+	for range 2 {
+		var sum float64
+		n := 0
+		for _, z := range blocks {
+			if z > gate {
+				sum += z
+				n++
+			}
+		}
+		if n == 0 {
+			return l
+		}
+		l.Integrated = toLUFS(sum / float64(n))
+		gate = max(gate, fromLUFS(l.Integrated-10))
+	}
+	return l
+}
+
+func toLUFS(meanSquare float64) float64 {
+	return -0.691 + 10*math.Log10(meanSquare)
+}
+
+func fromLUFS(lufs float64) float64 {
+	return math.Pow(10, (lufs+0.691)/10)
+}
+
+// biquad is a second order IIR filter in transposed direct form II.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting is the filter of BS.1770 that models the head: a high shelf of about +4 dB above 1.5 kHz,
+// and a high-pass at 38 Hz. The filters are derived for any sample rate from the analog prototypes that
+// the coefficients of the standard at 48 kHz come from.
+type kWeighting struct {
+	shelf, highPass biquad
+}
+
+func newKWeighting(sampleRate float64) kWeighting {
+	var k kWeighting
+
+	const shelfGain, shelfFreq, shelfQ = 3.999843853973347, 1681.974450955533, 0.7071752369554196
+	kk := math.Tan(math.Pi * shelfFreq / sampleRate)
+	vh := math.Pow(10, shelfGain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + kk/shelfQ + kk*kk
+	k.shelf = biquad{
+		b0: (vh + vb*kk/shelfQ + kk*kk) / a0,
+		b1: 2 * (kk*kk - vh) / a0,
+		b2: (vh - vb*kk/shelfQ + kk*kk) / a0,
+		a1: 2 * (kk*kk - 1) / a0,
+		a2: (1 - kk/shelfQ + kk*kk) / a0,
+	}
+
+	const highPassFreq, highPassQ = 38.13547087602444, 0.5003270373238773
+	kk = math.Tan(math.Pi * highPassFreq / sampleRate)
+	a0 = 1 + kk/highPassQ + kk*kk
+	k.highPass = biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (kk*kk - 1) / a0,
+		a2: (1 - kk/highPassQ + kk*kk) / a0,
+	}
+	return k
+}
+
+func (k *kWeighting) process(x float64) float64 {
+	return k.highPass.process(k.shelf.process(x))
+}
@@ -0,0 +1,115 @@
+package loudness_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/loaders/loudness"
+)
+
+// stereo returns a sine at freq in both channels, starting at phase.
+func stereo(freq, amplitude, phase float64, rate, frames int) []float32 {
+	out := make([]float32, 2*frames)
+	for i := range frames {
+		v := float32(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)+phase))
+		out[2*i], out[2*i+1] = v, v
+	}
+	return out
+}
+
+func TestIntegrated(t *testing.T) {
+	for _, rate := range []int{44100, 48000, 96000} {
+		// a 997 Hz sine at full scale in one channel is -3.01 LUFS, and so at -20 dBFS in both channels it is -20 LUFS
+		l := loudness.Measure(stereo(997, 0.1, 0, rate, 5*rate), 2, rate)
+		if math.Abs(l.Integrated+20) > 0.1 {
+			t.Errorf("%d Hz: should be -20 LUFS but is %.2f", rate, l.Integrated)
+		}
+	}
+
+	// the K-weighting doesn't count bass as loud
+	if l := loudness.Measure(stereo(30, 0.1, 0, 48000, 48000*5), 2, 48000); l.Integrated > -22 {
+		t.Errorf("30 Hz should be quieter than -22 LUFS but is %.2f", l.Integrated)
+	}
+}
+
+func TestGating(t *testing.T) {
+	const rate = 48000
+	tone := stereo(997, 0.1, 0, rate, 5*rate)
+
+	// silence doesn't make a sound quieter, except for the blocks that overlap the edges of the tone
+	withSilence := append(append(make([]float32, 2*5*rate), tone...), make([]float32, 2*5*rate)...)
+	if l := loudness.Measure(withSilence, 2, rate); math.Abs(l.Integrated+20) > 0.3 {
+		t.Errorf("silence should be gated out, but the loudness is %.2f LUFS", l.Integrated)
+	}
+
+	// and neither does a part that is much quieter than the rest
+	withQuiet := append(stereo(997, 0.001, 0, rate, 5*rate), tone...)
+	if l := loudness.Measure(withQuiet, 2, rate); math.Abs(l.Integrated+20) > 0.3 {
+		t.Errorf("the quiet part should be gated out, but the loudness is %.2f LUFS", l.Integrated)
+	}
+
+	if l := loudness.Measure(make([]float32, 2*rate), 2, rate); !math.IsInf(l.Integrated, -1) || !math.IsInf(l.TruePeak, -1) {
+		t.Errorf("silence should be -Inf but is %+v", l)
+	}
+
+	// a sound effect that is shorter than a block is measured as a whole
+	if l := loudness.Measure(tone[:2*rate/10], 2, rate); math.Abs(l.Integrated+20) > 0.5 {
+		t.Errorf("a short sound should be -20 LUFS but is %.2f", l.Integrated)
+	}
+}
+
+func TestMeterParts(t *testing.T) {
+	const rate = 44100
+	tone := stereo(440, 0.3, 0, rate, 3*rate)
+	m := loudness.NewMeter(2, rate)
+	for i := 0; i < len(tone); i += 2 * 1000 {
+		m.Write(tone[i:min(i+2*1000, len(tone))])
+	}
+	if whole := loudness.Measure(tone, 2, rate); m.Loudness() != whole {
+		t.Errorf("writing in parts should measure %+v but measured %+v", whole, m.Loudness())
+	}
+}
+
+func TestTruePeak(t *testing.T) {
+	const rate = 48000
+	// a quarter of the sample rate, with the samples halfway between the peaks, at 1/sqrt(2) of the amplitude
+	l := loudness.Measure(stereo(rate/4, 0.5, math.Pi/4, rate, rate), 2, rate)
+	if want := 20 * math.Log10(0.5); math.Abs(l.TruePeak-want) > 0.5 {
+		t.Errorf("the true peak should be %.2f dBTP but is %.2f", want, l.TruePeak)
+	}
+}
+
+func TestGain(t *testing.T) {
+	for _, test := range []struct {
+		loudness loudness.Loudness
+		target   float64
+		gain     float64
+	}{
+		{loudness.Loudness{Integrated: -30, TruePeak: -20}, -20, 10},
+		{loudness.Loudness{Integrated: -10, TruePeak: 0}, -20, -10},
+		// limited by the true peak
+		{loudness.Loudness{Integrated: -30, TruePeak: -5}, -20, 4},
+		{loudness.Loudness{Integrated: -30, TruePeak: 1}, -20, 0},
+		{loudness.Loudness{Integrated: math.Inf(-1), TruePeak: math.Inf(-1)}, -20, 0},
+	} {
+		if gain := 20 * math.Log10(float64(test.loudness.Gain(test.target))); math.Abs(gain-test.gain) > 1e-3 {
+			t.Errorf("%+v to %v LUFS should be %v dB but is %.3f dB", test.loudness, test.target, test.gain, gain)
+		}
+	}
+}
+
+func TestReplayGain(t *testing.T) {
+	l, ok := loudness.ReplayGain(map[string][]string{
+		"REPLAYGAIN_TRACK_GAIN": {"-6.50 dB"},
+		"REPLAYGAIN_TRACK_PEAK": {"0.5"},
+	})
+	if !ok || math.Abs(l.Integrated+11.5) > 1e-9 || math.Abs(l.TruePeak-20*math.Log10(0.5)) > 1e-9 {
+		t.Errorf("should have read -11.5 LUFS and -6 dBTP but got %+v, %v", l, ok)
+	}
+	if l, ok := loudness.ReplayGain(map[string][]string{"REPLAYGAIN_TRACK_GAIN": {"+2 dB"}}); !ok || l.Integrated != -20 || l.TruePeak != 0 {
+		t.Errorf("without a peak the true peak should be 0 dBTP, but got %+v, %v", l, ok)
+	}
+	if _, ok := loudness.ReplayGain(map[string][]string{"REPLAYGAIN_TRACK_GAIN": {"loud"}}); ok {
+		t.Error("a broken tag should be ignored")
+	}
+}
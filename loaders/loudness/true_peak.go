@@ -0,0 +1,57 @@
+package loudness
+
+import "math"
+
+// truePeakTaps is the length of each phase of the interpolation filter, as in Annex 2 of BS.1770.
+const truePeakTaps = 12
+
+// truePeakPhases interpolate the three points between two samples, for oversampling by 4.
+var truePeakPhases = func() (phases [3][truePeakTaps]float64) {
+	for p := range phases {
+		for j := range truePeakTaps {
+			// the distance of the interpolated point from sample j of the history, with the newest sample last
+			x := float64(truePeakTaps/2-1-j) + float64(p+1)/4
+			window := math.Cos(math.Pi * x / (truePeakTaps + 1))
+			phases[p][j] = sinc(x) * window * window
+		}
+	}
+	return
+}()
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// truePeak follows the highest absolute value of the samples oversampled by 4.
+type truePeak struct {
+	peak float64
+	// history has the last samples of each channel, the newest sample of a channel at pos
+	history []float64
+	pos     int
+}
+
+func newTruePeak(channelCount int) truePeak {
+	return truePeak{history: make([]float64, channelCount*truePeakTaps)}
+}
+
+// write adds the next sample of channel c.
+func (t *truePeak) write(c int, v float64) {
+	history := t.history[c*truePeakTaps : (c+1)*truePeakTaps]
+	history[t.pos] = v
+	t.peak = max(t.peak, math.Abs(v))
+	for p := range truePeakPhases {
+		var y float64
+		for j, w := range truePeakPhases[p] {
+			y += w * history[(t.pos+1+j)%truePeakTaps]
+		}
+		t.peak = max(t.peak, math.Abs(y))
+	}
+}
+
+// advance moves to the next frame, after write was called for every channel.
+func (t *truePeak) advance() {
+	t.pos = (t.pos + 1) % truePeakTaps
+}
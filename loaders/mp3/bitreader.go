@@ -0,0 +1,42 @@
+package mp3
+
+// bitReader reads big-endian bit fields from buf. Reading past the end yields zeros and sets truncated.
+type bitReader struct {
+	buf []byte
+	// pos is the position in bits
+	pos       int
+	truncated bool
+}
+
+// read returns the next n bits, n <= 32.
+func (r *bitReader) read(n int) uint32 {
+	var v uint32
+	for n > 0 {
+		byteIndex := r.pos >> 3
+		if byteIndex >= len(r.buf) {
+			r.truncated = true
+			r.pos += n
+			return v << n
+		}
+		bit := r.pos & 7
+		// take as many bits as possible from the current byte
+		take := min(8-bit, n)
+		b := uint32(r.buf[byteIndex]) >> (8 - bit - take) & (1<<take - 1)
+		v = v<<take | b
+		r.pos += take
+		n -= take
+	}
+	return v
+}
+
+func (r *bitReader) readBit() uint32 {
+	byteIndex := r.pos >> 3
+	if byteIndex >= len(r.buf) {
+		r.truncated = true
+		r.pos++
+		return 0
+	}
+	b := uint32(r.buf[byteIndex]>>(7-r.pos&7)) & 1
+	r.pos++
+	return b
+}
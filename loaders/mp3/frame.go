@@ -0,0 +1,216 @@
+package mp3
+
+// MPEG versions, in the order of sampleRates
+const (
+	mpeg1 = iota
+	mpeg2
+	mpeg25
+)
+
+// channel modes of the header
+const (
+	modeStereo      = 0
+	modeJointStereo = 1
+	modeDualChannel = 2
+	modeMono        = 3
+)
+
+// header is the 4 byte header of an MPEG audio frame.
+type header struct {
+	version     int
+	protected   bool
+	bitrate     int
+	sampleRate  int
+	rateIndex   int
+	padding     bool
+	mode        int
+	modeExt     int
+	frameSize   int
+	granules    int
+	channels    int
+	sideInfoLen int
+}
+
+// parseHeader parses the 4 bytes at the start of b, and returns false if they aren't a Layer III header
+// or describe a frame that can't be decoded.
+func parseHeader(b []byte) (header, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return header{}, false
+	}
+	var h header
+	switch (b[1] >> 3) & 3 {
+	case 0:
+		h.version = mpeg25
+	case 2:
+		h.version = mpeg2
+	case 3:
+		h.version = mpeg1
+	default:
+		return header{}, false
+	}
+	if (b[1]>>1)&3 != 1 {
+		// not Layer III
+		return header{}, false
+	}
+	h.protected = b[1]&1 == 0
+	bitrateIndex := int(b[2] >> 4)
+	h.rateIndex = int(b[2]>>2) & 3
+	if bitrateIndex == 0 || bitrateIndex == 15 || h.rateIndex == 3 {
+		// the free format isn't supported
+		return header{}, false
+	}
+	h.padding = (b[2]>>1)&1 == 1
+	h.mode = int(b[3] >> 6)
+	h.modeExt = int(b[3]>>4) & 3
+
+	h.sampleRate = sampleRates[h.version][h.rateIndex]
+	h.channels = 2
+	if h.mode == modeMono {
+		h.channels = 1
+	}
+	if h.version == mpeg1 {
+		h.bitrate = bitrates[0][bitrateIndex] * 1000
+		h.granules = 2
+		h.frameSize = 144 * h.bitrate / h.sampleRate
+		h.sideInfoLen = 17
+		if h.channels == 2 {
+			h.sideInfoLen = 32
+		}
+	} else {
+		h.bitrate = bitrates[1][bitrateIndex] * 1000
+		h.granules = 1
+		h.frameSize = 72 * h.bitrate / h.sampleRate
+		h.sideInfoLen = 9
+		if h.channels == 2 {
+			h.sideInfoLen = 17
+		}
+	}
+	if h.padding {
+		h.frameSize++
+	}
+	return h, true
+}
+
+// samplesPerFrame is the number of samples of each channel in the frame.
+func (h header) samplesPerFrame() int {
+	return h.granules * 576
+}
+
+// sideInfoOffset is where the side info starts, after the header and the CRC.
+func (h header) sideInfoOffset() int {
+	if h.protected {
+		return 6
+	}
+	return 4
+}
+
+// sameStream returns whether h can follow first in the same stream, which tells frames from
+// random bytes that happen to look like a header.
+func (h header) sameStream(first header) bool {
+	return h.version == first.version && h.sampleRate == first.sampleRate && h.channels == first.channels
+}
+
+// block types of the side info
+const (
+	blockNormal = 0
+	blockStart  = 1
+	blockShort  = 2
+	blockStop   = 3
+)
+
+// granuleInfo is the side info of one channel in one granule.
+type granuleInfo struct {
+	part23Length     int
+	bigValues        int
+	globalGain       int
+	scalefacCompress int
+	windowSwitching  bool
+	blockType        int
+	mixed            bool
+	tableSelect      [3]int
+	subblockGain     [3]int
+	region0Count     int
+	region1Count     int
+	preflag          bool
+	scalefacScale    bool
+	count1Table      int
+}
+
+// sideInfo is the side info of a frame.
+type sideInfo struct {
+	mainDataBegin int
+	// scfsi are the scalefactor selection flags of MPEG-1, by channel and group of bands
+	scfsi    [2][4]bool
+	granules [2][2]granuleInfo
+}
+
+func parseSideInfo(h header, b []byte) (sideInfo, bool) {
+	r := bitReader{buf: b}
+	var si sideInfo
+	if h.version == mpeg1 {
+		si.mainDataBegin = int(r.read(9))
+		if h.channels == 1 {
+			r.read(5)
+		} else {
+			r.read(3)
+		}
+		for ch := 0; ch < h.channels; ch++ {
+			for band := 0; band < 4; band++ {
+				si.scfsi[ch][band] = r.read(1) == 1
+			}
+		}
+	} else {
+		si.mainDataBegin = int(r.read(8))
+		r.read(h.channels) // private bits
+	}
+
+	for gr := 0; gr < h.granules; gr++ {
+		for ch := 0; ch < h.channels; ch++ {
+			g := &si.granules[gr][ch]
+			g.part23Length = int(r.read(12))
+			g.bigValues = int(r.read(9))
+			g.globalGain = int(r.read(8))
+			if h.version == mpeg1 {
+				g.scalefacCompress = int(r.read(4))
+			} else {
+				g.scalefacCompress = int(r.read(9))
+			}
+			g.windowSwitching = r.read(1) == 1
+			if g.windowSwitching {
+				g.blockType = int(r.read(2))
+				g.mixed = r.read(1) == 1
+				for i := 0; i < 2; i++ {
+					g.tableSelect[i] = int(r.read(5))
+				}
+				for i := 0; i < 3; i++ {
+					g.subblockGain[i] = int(r.read(3))
+				}
+				// the regions are implicit
+				g.region0Count = 7
+				if g.blockType == blockShort && !g.mixed {
+					g.region0Count = 8
+				}
+				g.region1Count = 20 - g.region0Count
+				if g.blockType == blockNormal {
+					// reserved
+					return sideInfo{}, false
+				}
+			} else {
+				for i := 0; i < 3; i++ {
+					g.tableSelect[i] = int(r.read(5))
+				}
+				g.region0Count = int(r.read(4))
+				g.region1Count = int(r.read(3))
+			}
+			if h.version == mpeg1 {
+				g.preflag = r.read(1) == 1
+			}
+			g.scalefacScale = r.read(1) == 1
+			g.count1Table = int(r.read(1))
+			if g.bigValues > 288 {
+				return sideInfo{}, false
+			}
+		}
+	}
+	return si, !r.truncated
+}
@@ -0,0 +1,83 @@
+package mp3
+
+// huffmanTree decodes codes bit by bit. Each node is a pair of children;
+// a child >= 0 is the next node, and a leaf is -1 - value.
+type huffmanTree [][2]int32
+
+func newHuffmanTree(codes []uint16, lengths []uint8) huffmanTree {
+	t := huffmanTree{{0, 0}}
+	for value, code := range codes {
+		node := 0
+		for i := int(lengths[value]) - 1; i >= 0; i-- {
+			bit := (code >> i) & 1
+			if i == 0 {
+				t[node][bit] = -1 - int32(value)
+				break
+			}
+			next := t[node][bit]
+			if next == 0 {
+				next = int32(len(t))
+				t = append(t, [2]int32{})
+				t[node][bit] = next
+			}
+			node = int(next)
+		}
+	}
+	return t
+}
+
+// decode returns the next value, or -1 if the bits aren't a code of the tree.
+func (t huffmanTree) decode(r *bitReader) int {
+	node := int32(0)
+	for {
+		node = t[node][r.readBit()]
+		if node < 0 {
+			return int(-1 - node)
+		}
+		if node == 0 || r.truncated {
+			return -1
+		}
+	}
+}
+
+// bigValueTable is a Huffman table of pairs of values, with the number of extra bits for large values.
+type bigValueTable struct {
+	tree    huffmanTree
+	size    int
+	linbits int
+}
+
+// bigValueTables are by table_select. Tables 4 and 14 don't exist, and table 0 is all zeros.
+var bigValueTables [32]bigValueTable
+
+var count1Trees [2]huffmanTree
+
+func init() {
+	linbits := [32]int{16: 1, 2, 3, 4, 6, 8, 10, 13, 4, 5, 6, 7, 8, 9, 11, 13}
+	for i := range bigValueTables {
+		// tables 16 to 23 and 24 to 31 share their codes, and only differ in linbits
+		code := huffmanCodes[min(i, len(huffmanCodes)-1)]
+		switch {
+		case i >= 24:
+			code = huffmanCodes[24]
+		case i >= 16:
+			code = huffmanCodes[16]
+		}
+		if code.size == 0 {
+			continue
+		}
+		bigValueTables[i] = bigValueTable{
+			tree:    newHuffmanTree(code.codes, code.lengths),
+			size:    code.size,
+			linbits: linbits[i],
+		}
+	}
+	count1Trees[0] = newHuffmanTree(count1Codes[:], count1Lengths[:])
+	b := make([]uint16, 16)
+	bLengths := make([]uint8, 16)
+	for i := range b {
+		b[i] = uint16(15 - i)
+		bLengths[i] = 4
+	}
+	count1Trees[1] = newHuffmanTree(b, bLengths)
+}
@@ -0,0 +1,590 @@
+package mp3
+
+import "math"
+
+// channelState is what a channel keeps between granules and frames.
+type channelState struct {
+	// scalefactors of long bands, and of short bands by window
+	scalefacL [22]int
+	scalefacS [13][3]int
+
+	// overlap is the second half of the previous IMDCT of each subband
+	overlap [32][18]float32
+	synth   synthesis
+}
+
+// granule is a channel of a granule while it is decoded.
+type granule struct {
+	quantized [576]int32
+	// nonzero is the number of lines that were decoded, all the lines after it are 0
+	nonzero int
+	xr      [576]float32
+
+	// isIllegal are the intensity stereo positions that mean "not intensity stereo" in MPEG-2, by band
+	isIllegalL [22]int
+	isIllegalS [13]int
+}
+
+func (g *granuleInfo) isShort() bool {
+	return g.windowSwitching && g.blockType == blockShort
+}
+
+// readScalefactors reads the scalefactors of MPEG-1.
+func (s *channelState) readScalefactors(r *bitReader, g *granuleInfo, scfsi [4]bool, gr int) {
+	slen := scalefactorLengths[g.scalefacCompress]
+	if g.isShort() {
+		first := 0
+		if g.mixed {
+			for sfb := 0; sfb < 8; sfb++ {
+				s.scalefacL[sfb] = int(r.read(slen[0]))
+			}
+			first = 3
+		}
+		for sfb := first; sfb < 12; sfb++ {
+			n := slen[0]
+			if sfb >= 6 {
+				n = slen[1]
+			}
+			for win := 0; win < 3; win++ {
+				s.scalefacS[sfb][win] = int(r.read(n))
+			}
+		}
+		s.scalefacS[12] = [3]int{}
+		return
+	}
+
+	// the bands are in four groups, which the second granule can copy from the first
+	groups := [5]int{0, 6, 11, 16, 21}
+	for group := 0; group < 4; group++ {
+		if gr == 1 && scfsi[group] {
+			continue
+		}
+		n := slen[0]
+		if group >= 2 {
+			n = slen[1]
+		}
+		for sfb := groups[group]; sfb < groups[group+1]; sfb++ {
+			s.scalefacL[sfb] = int(r.read(n))
+		}
+	}
+	s.scalefacL[21] = 0
+}
+
+// readScalefactorsLSF reads the scalefactors of MPEG-2 and MPEG-2.5.
+// The right channel of intensity stereo stores the positions, and their illegal values, differently.
+func (s *channelState) readScalefactorsLSF(r *bitReader, g *granuleInfo, intensityRight bool, out *granule) {
+	sfc := g.scalefacCompress
+	var slen [4]int
+	var row int
+	switch {
+	case !intensityRight && sfc < 400:
+		slen = [4]int{(sfc >> 4) / 5, (sfc >> 4) % 5, (sfc & 15) >> 2, sfc & 3}
+	case !intensityRight && sfc < 500:
+		sfc -= 400
+		slen = [4]int{(sfc >> 2) / 5, (sfc >> 2) % 5, sfc & 3, 0}
+		row = 1
+	case !intensityRight:
+		sfc -= 500
+		slen = [4]int{sfc / 3, sfc % 3, 0, 0}
+		row = 2
+		g.preflag = true
+	case sfc>>1 < 180:
+		sfc >>= 1
+		slen = [4]int{sfc / 36, (sfc % 36) / 6, (sfc % 36) % 6, 0}
+		row = 3
+	case sfc>>1 < 244:
+		sfc = sfc>>1 - 180
+		slen = [4]int{(sfc & 63) >> 4, (sfc & 15) >> 2, sfc & 3, 0}
+		row = 4
+	default:
+		sfc = sfc>>1 - 244
+		slen = [4]int{sfc / 3, sfc % 3, 0, 0}
+		row = 5
+	}
+	blockIndex := 0
+	if g.isShort() {
+		blockIndex = 1
+		if g.mixed {
+			blockIndex = 2
+		}
+	}
+
+	var values, illegal [54]int
+	n := 0
+	for group, count := range scalefactorCounts[row][blockIndex] {
+		for i := 0; i < count; i++ {
+			values[n] = int(r.read(slen[group]))
+			illegal[n] = 1<<slen[group] - 1
+			n++
+		}
+	}
+
+	switch {
+	case !g.isShort():
+		for sfb := 0; sfb < 21; sfb++ {
+			s.scalefacL[sfb] = values[sfb]
+			out.isIllegalL[sfb] = illegal[sfb]
+		}
+		s.scalefacL[21] = 0
+		out.isIllegalL[21] = out.isIllegalL[20]
+	default:
+		i := 0
+		first := 0
+		if g.mixed {
+			for sfb := 0; sfb < 6; sfb++ {
+				s.scalefacL[sfb] = values[sfb]
+				out.isIllegalL[sfb] = illegal[sfb]
+			}
+			i = 6
+			first = 3
+		}
+		for sfb := first; sfb < 12; sfb++ {
+			for win := 0; win < 3; win++ {
+				s.scalefacS[sfb][win] = values[i]
+				out.isIllegalS[sfb] = illegal[i]
+				i++
+			}
+		}
+		s.scalefacS[12] = [3]int{}
+		out.isIllegalS[12] = out.isIllegalS[11]
+	}
+}
+
+// readHuffman decodes the quantized lines of a granule up to the bit position end,
+// and returns false for data that isn't valid.
+func readHuffman(r *bitReader, g *granuleInfo, edges *bandEdges, end int, out *granule) bool {
+	var region1, region2 int
+	switch {
+	case g.isShort() && !g.mixed:
+		// 9 bands: the first 3 bands of the 3 windows
+		region1 = edges.short[3] * 3
+		region2 = 576
+	case g.windowSwitching:
+		region1 = edges.long[8]
+		region2 = 576
+	default:
+		region1 = edges.long[min(g.region0Count+1, 22)]
+		region2 = edges.long[min(g.region0Count+g.region1Count+2, 22)]
+	}
+
+	q := &out.quantized
+	bigEnd := g.bigValues * 2
+	for i := 0; i < bigEnd; i += 2 {
+		region := 0
+		if i >= region2 {
+			region = 2
+		} else if i >= region1 {
+			region = 1
+		}
+		table := &bigValueTables[g.tableSelect[region]]
+		if table.size == 0 {
+			if g.tableSelect[region] != 0 {
+				return false
+			}
+			q[i], q[i+1] = 0, 0
+			continue
+		}
+		v := table.tree.decode(r)
+		if v < 0 {
+			return false
+		}
+		x, y := int32(v/table.size), int32(v%table.size)
+		if x == 15 && table.linbits > 0 {
+			x += int32(r.read(table.linbits))
+		}
+		if x != 0 && r.readBit() == 1 {
+			x = -x
+		}
+		if y == 15 && table.linbits > 0 {
+			y += int32(r.read(table.linbits))
+		}
+		if y != 0 && r.readBit() == 1 {
+			y = -y
+		}
+		q[i], q[i+1] = x, y
+	}
+	if r.pos > end {
+		return false
+	}
+
+	i := bigEnd
+	tree := count1Trees[g.count1Table]
+	for i+4 <= 576 && r.pos < end {
+		v := tree.decode(r)
+		if v < 0 {
+			return false
+		}
+		quad := [4]int32{int32(v>>3) & 1, int32(v>>2) & 1, int32(v>>1) & 1, int32(v) & 1}
+		for j := range quad {
+			if quad[j] != 0 && r.readBit() == 1 {
+				quad[j] = -quad[j]
+			}
+		}
+		if r.pos > end {
+			// the last quadruple ran into the next granule, so it is padding
+			break
+		}
+		copy(q[i:], quad[:])
+		i += 4
+	}
+	clear(q[i:])
+	out.nonzero = i
+	return true
+}
+
+// pow43 is |i|^(4/3) for all the values of Huffman codes with linbits.
+var pow43 [8207]float32
+
+func init() {
+	for i := range pow43 {
+		pow43[i] = float32(math.Pow(float64(i), 4.0/3))
+	}
+}
+
+func requantizeLine(v int32, scale float32) float32 {
+	if v < 0 {
+		return -pow43[min(-v, int32(len(pow43)-1))] * scale
+	}
+	return pow43[min(v, int32(len(pow43)-1))] * scale
+}
+
+// requantize scales the quantized lines of the granule back.
+func (s *channelState) requantize(g *granuleInfo, edges *bandEdges, out *granule) {
+	gain := 0.25 * float64(g.globalGain-210)
+	multiplier := 0.5
+	if g.scalefacScale {
+		multiplier = 1
+	}
+	longBand := func(sfb int) {
+		sf := s.scalefacL[sfb]
+		if g.preflag {
+			sf += pretab[sfb]
+		}
+		scale := float32(math.Exp2(gain - multiplier*float64(sf)))
+		for i := edges.long[sfb]; i < min(edges.long[sfb+1], out.nonzero); i++ {
+			out.xr[i] = requantizeLine(out.quantized[i], scale)
+		}
+	}
+	shortBand := func(sfb int) {
+		width := edges.short[sfb+1] - edges.short[sfb]
+		start := edges.short[sfb] * 3
+		for win := 0; win < 3; win++ {
+			scale := float32(math.Exp2(gain - 2*float64(g.subblockGain[win]) - multiplier*float64(s.scalefacS[sfb][win])))
+			for i := start + win*width; i < min(start+(win+1)*width, out.nonzero); i++ {
+				out.xr[i] = requantizeLine(out.quantized[i], scale)
+			}
+		}
+	}
+
+	clear(out.xr[out.nonzero:])
+	switch {
+	case !g.isShort():
+		for sfb := 0; sfb < 22; sfb++ {
+			longBand(sfb)
+		}
+	case g.mixed:
+		for sfb := 0; edges.long[sfb] < mixedLongLines; sfb++ {
+			longBand(sfb)
+		}
+		for sfb := firstMixedShortBand(edges); sfb < 13; sfb++ {
+			shortBand(sfb)
+		}
+	default:
+		for sfb := 0; sfb < 13; sfb++ {
+			shortBand(sfb)
+		}
+	}
+}
+
+// mixedLongLines is the number of lines of long blocks in a mixed block, the first two subbands.
+const mixedLongLines = 36
+
+// firstMixedShortBand is the first short band of a mixed block.
+func firstMixedShortBand(edges *bandEdges) int {
+	sfb := 0
+	for edges.short[sfb]*3 < mixedLongLines {
+		sfb++
+	}
+	return sfb
+}
+
+// stereoBand is a scalefactor band, in the order of the lines in the granule.
+type stereoBand struct {
+	start, end int
+	// sfb is the long or short band, and win the window of a short band, -1 for long bands
+	sfb, win int
+}
+
+// stereoBands lists the bands of a granule before the short blocks are reordered.
+func stereoBands(g *granuleInfo, edges *bandEdges) []stereoBand {
+	var list []stereoBand
+	addShort := func(first int) {
+		for sfb := first; sfb < 13; sfb++ {
+			width := edges.short[sfb+1] - edges.short[sfb]
+			start := edges.short[sfb] * 3
+			for win := 0; win < 3; win++ {
+				list = append(list, stereoBand{start + win*width, start + (win+1)*width, sfb, win})
+			}
+		}
+	}
+	switch {
+	case !g.isShort():
+		for sfb := 0; sfb < 22; sfb++ {
+			list = append(list, stereoBand{edges.long[sfb], edges.long[sfb+1], sfb, -1})
+		}
+	case g.mixed:
+		for sfb := 0; edges.long[sfb] < mixedLongLines; sfb++ {
+			list = append(list, stereoBand{edges.long[sfb], edges.long[sfb+1], sfb, -1})
+		}
+		addShort(firstMixedShortBand(edges))
+	default:
+		addShort(0)
+	}
+	return list
+}
+
+// intensityRatios are the left and right gains of the MPEG-1 intensity stereo positions 0 to 6.
+var intensityRatios [7][2]float32
+
+func init() {
+	for pos := range intensityRatios {
+		if pos == 6 {
+			intensityRatios[pos] = [2]float32{1, 0}
+			continue
+		}
+		k := math.Tan(float64(pos) * math.Pi / 12)
+		intensityRatios[pos] = [2]float32{float32(k / (1 + k)), float32(1 / (1 + k))}
+	}
+}
+
+// stereo undoes joint stereo coding: middle/side, and intensity stereo, in which the right channel
+// only has the position of the sound between the speakers for the bands above its last nonzero line.
+func (d *decoder) stereo(h header, g *granuleInfo, left, right *granule) {
+	if h.mode != modeJointStereo {
+		return
+	}
+	ms := h.modeExt&2 != 0
+	intensity := h.modeExt&1 != 0
+	edges := &bands[h.version*3+h.rateIndex]
+	list := stereoBands(g, edges)
+
+	var intensityFrom [4]int // the first band of intensity stereo, for long bands and by window
+	for i := range intensityFrom {
+		intensityFrom[i] = len(list)
+	}
+	if intensity {
+		// the last band with a nonzero line is found separately for each window of short blocks
+		for i := range intensityFrom {
+			intensityFrom[i] = 0
+		}
+		for i, b := range list {
+			for line := b.start; line < b.end; line++ {
+				if right.quantized[line] != 0 {
+					intensityFrom[b.win+1] = i + 1
+					break
+				}
+			}
+		}
+		if g.mixed {
+			// the long bands are never coded with intensity stereo
+			for win := 1; win < 4; win++ {
+				intensityFrom[win] = max(intensityFrom[win], intensityFrom[0])
+			}
+			intensityFrom[0] = len(list)
+		}
+	}
+
+	const invSqrt2 = 1 / math.Sqrt2
+	for i, b := range list {
+		if intensity && i >= intensityFrom[b.win+1] {
+			if d.intensityStereo(h, g, b, list, right, left) {
+				continue
+			}
+		}
+		if ms {
+			for line := b.start; line < b.end; line++ {
+				m, s := left.xr[line], right.xr[line]
+				left.xr[line] = (m + s) * invSqrt2
+				right.xr[line] = (m - s) * invSqrt2
+			}
+		}
+	}
+}
+
+// intensityStereo applies the intensity stereo position of band b, and returns false if the position is illegal,
+// which means that the band is coded like the others.
+func (d *decoder) intensityStereo(h header, g *granuleInfo, b stereoBand, list []stereoBand, right, left *granule) bool {
+	s := &d.channels[1]
+	// the last band has no scalefactor, and uses the position of the band before
+	var pos, illegal int
+	if b.win < 0 {
+		sfb := min(b.sfb, 20)
+		pos = s.scalefacL[sfb]
+		illegal = right.isIllegalL[sfb]
+	} else {
+		sfb := min(b.sfb, 11)
+		pos = s.scalefacS[sfb][b.win]
+		illegal = right.isIllegalS[sfb]
+	}
+
+	var kl, kr float32
+	if h.version == mpeg1 {
+		if pos >= 7 {
+			return false
+		}
+		kl, kr = intensityRatios[pos][0], intensityRatios[pos][1]
+	} else {
+		if pos == illegal {
+			return false
+		}
+		i0 := math.Pow(2, -0.25)
+		if g.scalefacCompress&1 == 1 {
+			i0 = math.Sqrt2 / 2
+		}
+		kl, kr = 1, 1
+		if pos%2 == 1 {
+			kl = float32(math.Pow(i0, float64(pos+1)/2))
+		} else if pos > 0 {
+			kr = float32(math.Pow(i0, float64(pos)/2))
+		}
+	}
+	for line := b.start; line < b.end; line++ {
+		v := left.xr[line]
+		left.xr[line] = v * kl
+		right.xr[line] = v * kr
+	}
+	return true
+}
+
+// reorder interleaves the windows of short blocks, so that each subband holds its 3 windows line by line.
+func reorder(g *granuleInfo, edges *bandEdges, xr *[576]float32) {
+	if !g.isShort() {
+		return
+	}
+	first := 0
+	if g.mixed {
+		first = firstMixedShortBand(edges)
+	}
+	var tmp [576]float32
+	for sfb := first; sfb < 13; sfb++ {
+		width := edges.short[sfb+1] - edges.short[sfb]
+		start := edges.short[sfb] * 3
+		for win := 0; win < 3; win++ {
+			for j := 0; j < width; j++ {
+				tmp[start+3*j+win] = xr[start+win*width+j]
+			}
+		}
+	}
+	start := edges.short[first] * 3
+	copy(xr[start:], tmp[start:])
+}
+
+// aliasCoefficients are the butterflies of the alias reduction.
+var aliasCS, aliasCA [8]float32
+
+func init() {
+	c := [8]float64{-0.6, -0.535, -0.33, -0.185, -0.095, -0.041, -0.0142, -0.0037}
+	for i, ci := range c {
+		sq := math.Sqrt(1 + ci*ci)
+		aliasCS[i] = float32(1 / sq)
+		aliasCA[i] = float32(ci / sq)
+	}
+}
+
+// reduceAliasing undoes the aliasing between the subbands of long blocks.
+func reduceAliasing(g *granuleInfo, xr *[576]float32) {
+	subbands := 32
+	if g.isShort() {
+		if !g.mixed {
+			return
+		}
+		subbands = 2
+	}
+	for sb := 1; sb < subbands; sb++ {
+		for i := 0; i < 8; i++ {
+			lo, hi := 18*sb-1-i, 18*sb+i
+			a, b := xr[lo], xr[hi]
+			xr[lo] = a*aliasCS[i] - b*aliasCA[i]
+			xr[hi] = b*aliasCS[i] + a*aliasCA[i]
+		}
+	}
+}
+
+// imdct tables
+var (
+	imdctLong    [36][18]float32
+	imdctShort   [12][6]float32
+	imdctWindows [4][36]float32
+)
+
+func init() {
+	for i := range imdctLong {
+		for k := range imdctLong[i] {
+			imdctLong[i][k] = float32(math.Cos(math.Pi / 72 * float64((2*i+1+18)*(2*k+1))))
+		}
+	}
+	for i := range imdctShort {
+		for k := range imdctShort[i] {
+			imdctShort[i][k] = float32(math.Cos(math.Pi / 24 * float64((2*i+1+6)*(2*k+1))))
+		}
+	}
+	for i := 0; i < 36; i++ {
+		imdctWindows[blockNormal][i] = float32(math.Sin(math.Pi / 36 * (float64(i) + 0.5)))
+	}
+	for i := 0; i < 18; i++ {
+		imdctWindows[blockStart][i] = imdctWindows[blockNormal][i]
+		imdctWindows[blockStop][i+18] = imdctWindows[blockNormal][i+18]
+	}
+	for i := 18; i < 24; i++ {
+		imdctWindows[blockStart][i] = 1
+		imdctWindows[blockStop][i-6] = 1
+	}
+	for i := 24; i < 30; i++ {
+		imdctWindows[blockStart][i] = float32(math.Sin(math.Pi / 12 * (float64(i-18) + 0.5)))
+		imdctWindows[blockStop][i-18] = float32(math.Sin(math.Pi / 12 * (float64(i-24) + 0.5)))
+	}
+	for i := 0; i < 12; i++ {
+		imdctWindows[blockShort][i] = float32(math.Sin(math.Pi / 12 * (float64(i) + 0.5)))
+	}
+}
+
+// hybridSynthesis transforms the lines of each subband to 18 samples in time, and overlaps them with the previous granule.
+// The result is by sample and subband.
+func (s *channelState) hybridSynthesis(g *granuleInfo, xr *[576]float32, out *[18][32]float32) {
+	for sb := 0; sb < 32; sb++ {
+		blockType := blockNormal
+		if g.windowSwitching && !(g.mixed && sb < 2) {
+			blockType = g.blockType
+		}
+		in := xr[18*sb : 18*sb+18]
+		var raw [36]float32
+		if blockType == blockShort {
+			for win := 0; win < 3; win++ {
+				for i := 0; i < 12; i++ {
+					var sum float32
+					for k := 0; k < 6; k++ {
+						sum += in[3*k+win] * imdctShort[i][k]
+					}
+					raw[6+6*win+i] += sum * imdctWindows[blockShort][i]
+				}
+			}
+		} else {
+			for i := 0; i < 36; i++ {
+				var sum float32
+				for k := 0; k < 18; k++ {
+					sum += in[k] * imdctLong[i][k]
+				}
+				raw[i] = sum * imdctWindows[blockType][i]
+			}
+		}
+		for i := 0; i < 18; i++ {
+			v := raw[i] + s.overlap[sb][i]
+			s.overlap[sb][i] = raw[18+i]
+			// every other sample of the odd subbands is inverted
+			if sb%2 == 1 && i%2 == 1 {
+				v = -v
+			}
+			out[i][sb] = v
+		}
+	}
+}
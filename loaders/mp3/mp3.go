@@ -0,0 +1,190 @@
+// Package mp3 decodes MPEG-1 and MPEG-2 Layer III files in pure Go.
+package mp3
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Lundis/go-gameaudio/loaders/internal/id3"
+	"github.com/Lundis/go-gameaudio/loaders/resample"
+)
+
+var (
+	// ErrInvalidHeader is returned when no MPEG audio frames are found.
+	ErrInvalidHeader = errors.New("mp3: no MPEG audio frames found")
+	// ErrUnsupportedFormat is returned for MPEG audio that isn't Layer III, or uses the free bitrate format.
+	ErrUnsupportedFormat = errors.New("mp3: unsupported format")
+)
+
+func LoadFile(path string, expectedSampleRate int) ([]float32, error) {
+	rawData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to open: %w", path, err)
+	}
+
+	data, err := Load(rawData, expectedSampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// Load decodes an MP3 file to interleaved stereo at expectedSampleRate.
+// Mono is played on both sides.
+//
+// The encoder delay and padding in the LAME tag of the first frame are removed,
+// so that the samples are exactly those that were encoded, and loops are gapless.
+//
+// Corrupt frames are skipped or decoded as silence; the returned error wraps ErrInvalidHeader or ErrUnsupportedFormat.
+func Load(mp3Data []byte, expectedSampleRate int) ([]float32, error) {
+	data := id3.SkipV2(mp3Data)
+	pos, first, err := findFirstFrame(data)
+	if err != nil {
+		return nil, err
+	}
+
+	xing, hasXing := parseXing(first, data[pos:pos+first.frameSize])
+	if hasXing {
+		pos += first.frameSize
+	}
+	d := &decoder{}
+	for pos+4 <= len(data) {
+		h, ok := parseHeader(data[pos:])
+		if !ok || !h.sameStream(first) {
+			// resync on the next frame
+			pos++
+			continue
+		}
+		if pos+h.frameSize > len(data) {
+			break
+		}
+		d.decodeFrame(h, data[pos:pos+h.frameSize])
+		pos += h.frameSize
+	}
+	out := xing.trim(d.out, first.samplesPerFrame())
+	return resample.Stereo(out, first.sampleRate, expectedSampleRate), nil
+}
+
+// findFirstFrame returns the first frame that is followed by another frame of the same stream,
+// or ends the data.
+func findFirstFrame(data []byte) (int, header, error) {
+	for pos := 0; pos+4 <= len(data); pos++ {
+		h, ok := parseHeader(data[pos:])
+		if !ok {
+			if pos == 0 && isOtherMPEGAudio(data) {
+				return 0, header{}, fmt.Errorf("%w: Layer I, Layer II or free bitrate", ErrUnsupportedFormat)
+			}
+			continue
+		}
+		next := pos + h.frameSize
+		if next == len(data) {
+			return pos, h, nil
+		}
+		if nextHeader, ok := parseHeader(data[min(next, len(data)):]); ok && nextHeader.sameStream(h) {
+			return pos, h, nil
+		}
+	}
+	return 0, header{}, ErrInvalidHeader
+}
+
+// isOtherMPEGAudio returns whether data starts with a frame header that isn't supported.
+func isOtherMPEGAudio(data []byte) bool {
+	if len(data) < 4 || data[0] != 0xFF || data[1]&0xE0 != 0xE0 {
+		return false
+	}
+	version := (data[1] >> 3) & 3
+	layer := (data[1] >> 1) & 3
+	bitrateIndex := data[2] >> 4
+	rateIndex := (data[2] >> 2) & 3
+	if version == 1 || layer == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		// reserved values, not audio
+		return false
+	}
+	return layer != 1 || bitrateIndex == 0
+}
+
+// maxReservoir is how much main data is kept from previous frames; main_data_begin can point up to 511 bytes back.
+const maxReservoir = 4096
+
+type decoder struct {
+	channels [2]channelState
+	granules [2]granule
+	// reservoir is the main data of the previous frames, which the next frames can start in
+	reservoir []byte
+	// out is the decoded interleaved stereo
+	out []float32
+}
+
+// decodeFrame decodes one frame to d.out. Frames that can't be decoded are silent.
+func (d *decoder) decodeFrame(h header, frame []byte) {
+	start := len(d.out)
+	d.out = append(d.out, make([]float32, 2*h.samplesPerFrame())...)
+	out := d.out[start:]
+
+	mainStart := h.sideInfoOffset() + h.sideInfoLen
+	if len(frame) < mainStart {
+		return
+	}
+	si, ok := parseSideInfo(h, frame[h.sideInfoOffset():mainStart])
+	if !ok {
+		d.reservoir = d.reservoir[:0]
+		return
+	}
+	begin := len(d.reservoir) - si.mainDataBegin
+	d.reservoir = append(d.reservoir, frame[mainStart:]...)
+	defer func() {
+		if len(d.reservoir) > maxReservoir {
+			d.reservoir = append(d.reservoir[:0], d.reservoir[len(d.reservoir)-maxReservoir:]...)
+		}
+	}()
+	if begin < 0 {
+		// the frame starts in data we don't have, the first frame after a seek or corruption
+		return
+	}
+
+	edges := &bands[h.version*3+h.rateIndex]
+	r := bitReader{buf: d.reservoir[begin:]}
+	intensity := h.mode == modeJointStereo && h.modeExt&1 != 0
+	for gr := 0; gr < h.granules; gr++ {
+		for ch := 0; ch < h.channels; ch++ {
+			g := &si.granules[gr][ch]
+			s := &d.channels[ch]
+			decoded := &d.granules[ch]
+			end := r.pos + g.part23Length
+			if h.version == mpeg1 {
+				s.readScalefactors(&r, g, si.scfsi[ch], gr)
+			} else {
+				s.readScalefactorsLSF(&r, g, intensity && ch == 1, decoded)
+			}
+			if r.pos > end || !readHuffman(&r, g, edges, end, decoded) || r.truncated {
+				clear(decoded.quantized[:])
+				decoded.nonzero = 0
+			}
+			r.pos = end
+			s.requantize(g, edges, decoded)
+		}
+
+		if h.channels == 2 {
+			d.stereo(h, &si.granules[gr][1], &d.granules[0], &d.granules[1])
+		}
+
+		for ch := 0; ch < h.channels; ch++ {
+			g := &si.granules[gr][ch]
+			s := &d.channels[ch]
+			xr := &d.granules[ch].xr
+			reorder(g, edges, xr)
+			reduceAliasing(g, xr)
+			var subbands [18][32]float32
+			s.hybridSynthesis(g, xr, &subbands)
+			for t := range subbands {
+				s.synth.run(&subbands[t], out[2*(576*gr+32*t)+ch:], 2)
+			}
+		}
+	}
+	if h.channels == 1 {
+		for i := 0; i < len(out); i += 2 {
+			out[i+1] = out[i]
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package mp3_test
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/loaders/mp3"
+	"github.com/Lundis/go-gameaudio/loaders/wav"
+)
+
+// The test files have long blocks with a single nonzero line per channel, which decodes to a tone
+// at (line + 0.5) * sampleRate / 1152.
+func lineFrequency(line, sampleRate int) float64 {
+	return (float64(line) + 0.5) * float64(sampleRate) / 1152
+}
+
+// power is the power of one channel of interleaved stereo at frequency f, by the Goertzel algorithm.
+func power(data []float32, channel int, f float64, sampleRate int) float64 {
+	w := 2 * math.Pi * f / float64(sampleRate)
+	var s1, s2 float64
+	n := 0
+	for i := channel; i < len(data); i += 2 {
+		s1, s2 = float64(data[i])+2*math.Cos(w)*s1-s2, s1
+		n++
+	}
+	return (s1*s1 + s2*s2 - 2*math.Cos(w)*s1*s2) / float64(n*n)
+}
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		file   string
+		rate   int
+		frames int
+		// lines is the nonzero line of each channel
+		lines [2]int
+	}{
+		// the LAME tag trims the encoder delay and padding
+		{"test_mono.mp3", 44100, 44100, [2]int{52, 52}},
+		// has an ID3v2 tag
+		{"test_stereo.mp3", 44100, 30000, [2]int{52, 100}},
+		// MPEG-2 without a LAME tag, all 20 frames of 576 samples
+		{"test_lsf.mp3", 22050, 20 * 576, [2]int{52, 52}},
+	}
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			data, err := mp3.LoadFile(c.file, c.rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != 2*c.frames {
+				t.Fatalf("should have decoded %d frames but got %d", c.frames, len(data)/2)
+			}
+			// skip the fade in of the filterbanks
+			steady := data[len(data)/4 : len(data)*3/4]
+			for ch, line := range c.lines {
+				f := lineFrequency(line, c.rate)
+				tone := power(steady, ch, f, c.rate)
+				if tone < 1e-3 {
+					t.Errorf("channel %d should have a tone at %.0f Hz but its power is %g", ch, f, tone)
+				}
+				for _, other := range []float64{f / 2, f * 2, lineFrequency(c.lines[1-ch], c.rate)} {
+					if other == f {
+						continue
+					}
+					if p := power(steady, ch, other, c.rate); p > tone/100 {
+						t.Errorf("channel %d should have a tone at %.0f Hz, but has %g at %.0f Hz against %g", ch, f, p, other, tone)
+					}
+				}
+			}
+		})
+	}
+}
+
+// test_lame.mp3 is the first 24 frames of a file from LAME 3.98.4 (see LICENSE-test_lame): nearly mono music
+// in joint stereo with mid/side frames, short blocks on the transients and shared scalefactors.
+// test_lame.wav is the output of the go-mp3 decoder for it.
+func TestLoadLAME(t *testing.T) {
+	got, err := mp3.LoadFile("test_lame.mp3", 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := wav.LoadWavFile("test_lame.wav", 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("should have decoded %d frames but got %d", len(want)/2, len(got)/2)
+	}
+	// the reference is rounded to 16 bits, and the decoders round differently along the way
+	const tolerance = 4.0 / (1 << 15)
+	for i := range got {
+		if d := math.Abs(float64(got[i] - want[i])); d > tolerance {
+			t.Fatalf("sample %d of channel %d should be %f but was %f", i/2, i%2, want[i], got[i])
+		}
+	}
+}
+
+func TestLoadResampled(t *testing.T) {
+	data, err := mp3.LoadFile("test_lsf.mp3", 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames := len(data) / 2; frames < 2*20*576-1 || frames > 2*20*576+1 {
+		t.Errorf("should have resampled %d frames at 22050 Hz to about %d frames but got %d", 20*576, 2*20*576, frames)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	valid, err := os.ReadFile("test_mono.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := mp3.Load(valid[:len(valid)/2], 44100)
+	if err != nil {
+		t.Fatalf("should decode the frames of a half downloaded file, but got %v", err)
+	}
+	if len(data) == 0 || len(data) >= 2*44100 {
+		t.Errorf("should have decoded part of the %d frames but got %d", 44100, len(data)/2)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, mp3.ErrInvalidHeader},
+		{"not mp3", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), mp3.ErrInvalidHeader},
+		{"layer II", append([]byte{0xFF, 0xFD, 0x90, 0x00}, make([]byte, 413)...), mp3.ErrUnsupportedFormat},
+		{"free bitrate", append([]byte{0xFF, 0xFB, 0x00, 0x00}, make([]byte, 413)...), mp3.ErrUnsupportedFormat},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := mp3.Load(c.data, 44100)
+			if !errors.Is(err, c.want) {
+				t.Errorf("should have returned %v but got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func FuzzLoad(f *testing.F) {
+	for _, name := range []string{"test_mono.mp3", "test_stereo.mp3", "test_lsf.mp3", "test_lame.mp3"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		// the tags and the first frames
+		f.Add(data[:min(len(data), 2048)])
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		samples, err := mp3.Load(data, 44100)
+		if err != nil {
+			if !errors.Is(err, mp3.ErrInvalidHeader) && !errors.Is(err, mp3.ErrUnsupportedFormat) {
+				t.Errorf("unexpected error type: %v", err)
+			}
+			return
+		}
+		if len(samples)%2 != 0 {
+			t.Errorf("the samples should be stereo but %d were decoded", len(samples))
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package mp3
+
+import "math"
+
+// synthesis is the polyphase filterbank that turns 32 subbands into 32 samples.
+type synthesis struct {
+	v [1024]float32
+}
+
+var synthesisMatrix [64][32]float32
+
+func init() {
+	for i := range synthesisMatrix {
+		for k := range synthesisMatrix[i] {
+			synthesisMatrix[i][k] = float32(math.Cos(float64((16+i)*(2*k+1)) * math.Pi / 64))
+		}
+	}
+}
+
+// run filters one sample of each subband, and writes 32 samples to out, every stride elements.
+func (s *synthesis) run(subbands *[32]float32, out []float32, stride int) {
+	copy(s.v[64:], s.v[:960])
+	for i := 0; i < 64; i++ {
+		var sum float32
+		for k, v := range subbands {
+			sum += synthesisMatrix[i][k] * v
+		}
+		s.v[i] = sum
+	}
+	for j := 0; j < 32; j++ {
+		var sum float32
+		for i := 0; i < 8; i++ {
+			sum += s.v[128*i+j]*synthesisWindow[64*i+j] + s.v[128*i+96+j]*synthesisWindow[64*i+32+j]
+		}
+		out[j*stride] = sum
+	}
+}
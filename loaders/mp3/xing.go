@@ -0,0 +1,93 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// xingInfo is the content of the Xing or Info frame that encoders put in place of the first frame.
+// It has no audio.
+type xingInfo struct {
+	// frames is the number of audio frames, 0 if unknown
+	frames int
+	// hasLame is set when a LAME tag stores the encoder delay and padding in samples,
+	// which are trimmed for gapless playback
+	hasLame        bool
+	delay, padding int
+}
+
+// parseXing reads the Xing or Info tag from frame, and returns false if it has none.
+func parseXing(h header, frame []byte) (xingInfo, bool) {
+	start := h.sideInfoOffset() + h.sideInfoLen
+	if len(frame) < start+8 {
+		return xingInfo{}, false
+	}
+	b := frame[start:]
+	if !bytes.Equal(b[:4], []byte("Xing")) && !bytes.Equal(b[:4], []byte("Info")) {
+		return xingInfo{}, false
+	}
+	var x xingInfo
+	flags := binary.BigEndian.Uint32(b[4:8])
+	pos := 8
+	if flags&1 != 0 {
+		if len(b) < pos+4 {
+			return xingInfo{}, false
+		}
+		x.frames = int(binary.BigEndian.Uint32(b[pos:]))
+		pos += 4
+	}
+	if flags&2 != 0 {
+		// bytes
+		pos += 4
+	}
+	if flags&4 != 0 {
+		// table of contents for seeking
+		pos += 100
+	}
+	if flags&8 != 0 {
+		// quality
+		pos += 4
+	}
+
+	// the LAME tag starts with the name of the encoder, LAME or ffmpeg's Lavc
+	if len(b) >= pos+24 && isEncoderName(b[pos:pos+4]) {
+		x.hasLame = true
+		d := b[pos+21 : pos+24]
+		x.delay = int(d[0])<<4 | int(d[1])>>4
+		x.padding = int(d[1]&0xF)<<8 | int(d[2])
+	}
+	return x, true
+}
+
+func isEncoderName(b []byte) bool {
+	for _, c := range b {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// decoderDelay is the delay of the filterbanks of a Layer III decoder, which the LAME tag doesn't count.
+const decoderDelay = 529
+
+// trim cuts the encoder delay and padding from the decoded interleaved stereo.
+func (x xingInfo) trim(out []float32, samplesPerFrame int) []float32 {
+	if !x.hasLame {
+		return out
+	}
+	skip := 2 * (x.delay + decoderDelay)
+	frames := x.frames
+	if frames == 0 {
+		frames = len(out) / 2 / samplesPerFrame
+	}
+	total := 2 * (frames*samplesPerFrame - x.delay - x.padding)
+	if total <= 0 || skip >= len(out) {
+		return nil
+	}
+	if skip+total > len(out) {
+		// the end of the file is missing, or the tag lies
+		total = len(out) - skip
+	}
+	return out[skip : skip+total]
+}
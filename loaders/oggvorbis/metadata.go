@@ -0,0 +1,137 @@
+package oggvorbis
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// Metadata holds the Vorbis comments of a file.
+type Metadata struct {
+	// SampleRate is the rate of the file before it was resampled.
+	SampleRate int
+
+	// Title, Album and Artist are the TITLE, ALBUM and ARTIST comments.
+	Title  string
+	Album  string
+	Artist string
+
+	// Comments are all the comments, by upper case field name. A field can occur more than once.
+	Comments map[string][]string
+
+	// Loop is the loop region of the LOOPSTART and LOOPLENGTH or LOOPEND comments,
+	// or nil if the file has none.
+	// From ReadMetadata, its End is 0 if the loop runs until the end of the file.
+	Loop *Loop
+}
+
+// Loop is a region that is meant to be repeated, in frames at the sample rate that the file was loaded at.
+type Loop struct {
+	// Start is the first frame of the loop, End is the frame after the last one.
+	Start int
+	End   int
+}
+
+// LoadFileWithMetadata is like LoadWithMetadata but reads the file at path.
+func LoadFileWithMetadata(path string, expectedSampleRate int) ([]float32, Metadata, error) {
+	rawData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("%s: failed to open: %w", path, err)
+	}
+
+	data, meta, err := LoadWithMetadata(rawData, expectedSampleRate)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, meta, nil
+}
+
+// LoadWithMetadata is like Load, but also returns the Vorbis comments of the file.
+// The loop region is rescaled to expectedSampleRate.
+func LoadWithMetadata(oggData []byte, expectedSampleRate int) ([]float32, Metadata, error) {
+	meta, err := ReadMetadata(oggData)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	data, err := Load(oggData, expectedSampleRate)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	if meta.Loop != nil {
+		frames := len(data) / 2
+		rescale := func(frame int) int {
+			return min(int(int64(frame)*int64(expectedSampleRate)/int64(meta.SampleRate)), frames)
+		}
+		meta.Loop.Start = rescale(meta.Loop.Start)
+		if meta.Loop.End == 0 {
+			meta.Loop.End = frames
+		} else {
+			meta.Loop.End = rescale(meta.Loop.End)
+		}
+		if meta.Loop.Start >= meta.Loop.End {
+			meta.Loop = nil
+		}
+	}
+	return data, meta, nil
+}
+
+// ReadMetadata returns the Vorbis comments of a file without decoding the audio.
+// The loop region is at the sample rate of the file.
+func ReadMetadata(oggData []byte) (Metadata, error) {
+	r, err := oggvorbis.NewReader(bytes.NewReader(oggData))
+	if err != nil {
+		return Metadata{}, err
+	}
+	return metadataOf(r), nil
+}
+
+func metadataOf(r *oggvorbis.Reader) Metadata {
+	meta := Metadata{
+		SampleRate: r.SampleRate(),
+		Comments:   map[string][]string{},
+	}
+	for _, c := range r.CommentHeader().Comments {
+		field, value, ok := strings.Cut(c, "=")
+		if !ok {
+			continue
+		}
+		field = strings.ToUpper(field)
+		meta.Comments[field] = append(meta.Comments[field], value)
+	}
+	meta.Title = meta.comment("TITLE")
+	meta.Album = meta.comment("ALBUM")
+	meta.Artist = meta.comment("ARTIST")
+	meta.Loop = meta.loop()
+	return meta
+}
+
+// maxLoopFrame is far beyond any real file, and keeps broken tags from overflowing when the loop is rescaled.
+const maxLoopFrame = 1 << 30
+
+// comment returns the first value of the field, or "" if there is none.
+func (m *Metadata) comment(field string) string {
+	if values := m.Comments[field]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// loop reads the loop tags that game music tools write: LOOPSTART with either LOOPLENGTH or LOOPEND.
+// LOOPSTART alone loops until the end of the file.
+func (m *Metadata) loop() *Loop {
+	start, err := strconv.Atoi(m.comment("LOOPSTART"))
+	if err != nil || start < 0 || start > maxLoopFrame {
+		return nil
+	}
+	end := 0
+	if length, err := strconv.Atoi(m.comment("LOOPLENGTH")); err == nil && length > 0 && length <= maxLoopFrame {
+		end = start + length
+	} else if e, err := strconv.Atoi(m.comment("LOOPEND")); err == nil && e > start && e <= maxLoopFrame {
+		end = e
+	}
+	return &Loop{Start: start, End: end}
+}
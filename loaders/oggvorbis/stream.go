@@ -0,0 +1,137 @@
+package oggvorbis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Lundis/go-gameaudio/loaders/resample"
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// Stream decodes an Ogg Vorbis file while it is played, instead of keeping all of it in memory as float32.
+// It implements audio.StreamSource.
+//
+// Read and Seek must only be used by one goroutine at a time.
+type Stream struct {
+	r         *oggvorbis.Reader
+	rate      int
+	length    int
+	pos       int
+	resampler *resample.Stream
+	readErr   error
+	meta      Metadata
+}
+
+// NewStream prepares the Ogg Vorbis file in r to be decoded at expectedSampleRate.
+// Only the headers are read here. r must stay readable until the stream is no longer used;
+// a bytes.Reader of the compressed file is enough.
+func NewStream(r io.ReadSeeker, expectedSampleRate int) (*Stream, error) {
+	reader, err := oggvorbis.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	if reader.Channels() != 2 {
+		return nil, fmt.Errorf("number of channels must be 2 but was %d", reader.Channels())
+	}
+	if reader.Length() <= 0 {
+		return nil, errors.New("the length of the stream is unknown")
+	}
+	s := &Stream{
+		r:      reader,
+		rate:   expectedSampleRate,
+		length: int(reader.Length() * int64(expectedSampleRate) / int64(reader.SampleRate())),
+	}
+	s.meta = metadataOf(reader)
+	if l := s.meta.Loop; l != nil {
+		l.Start = s.rescale(l.Start)
+		if l.End == 0 {
+			l.End = s.length
+		} else {
+			l.End = s.rescale(l.End)
+		}
+		if l.Start >= l.End {
+			s.meta.Loop = nil
+		}
+	}
+	s.resetResampler()
+	return s, nil
+}
+
+// Metadata returns the Vorbis comments of the file, with the loop region at the expected sample rate.
+func (s *Stream) Metadata() Metadata {
+	return s.meta
+}
+
+// Length returns the number of frames at the expected sample rate.
+func (s *Stream) Length() int {
+	return s.length
+}
+
+// Seek moves to the given frame at the expected sample rate.
+func (s *Stream) Seek(frame int) error {
+	frame = min(max(frame, 0), s.length)
+	srcFrame := int64(frame) * int64(s.r.SampleRate()) / int64(s.rate)
+	if err := s.r.SetPosition(srcFrame); err != nil {
+		return err
+	}
+	s.pos = frame
+	s.readErr = nil
+	s.resetResampler()
+	return nil
+}
+
+// Read decodes the next interleaved stereo frames into buf, and returns the number of samples.
+// At the end of the file it returns io.EOF.
+func (s *Stream) Read(buf []float32) (int, error) {
+	frames := min(len(buf)/2, s.length-s.pos)
+	if frames <= 0 {
+		return 0, io.EOF
+	}
+	if s.resampler == nil {
+		n, err := s.readSource(buf[:frames*2])
+		s.pos += n / 2
+		if n == 0 && err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	s.resampler.Read(buf[:frames*2], s.fillFromSource)
+	s.pos += frames
+	return frames * 2, nil
+}
+
+// fillFromSource is called by the resampler. Past the end of the file it pads with silence.
+func (s *Stream) fillFromSource(buf []float32) {
+	n, _ := s.readSource(buf)
+	clear(buf[n:])
+}
+
+func (s *Stream) readSource(buf []float32) (int, error) {
+	read := 0
+	for read < len(buf) && s.readErr == nil {
+		n, err := s.r.Read(buf[read:])
+		read += n
+		if err != nil {
+			s.readErr = err
+		} else if n == 0 {
+			s.readErr = io.ErrNoProgress
+		}
+	}
+	if read < len(buf) {
+		return read, s.readErr
+	}
+	return read, nil
+}
+
+func (s *Stream) resetResampler() {
+	if s.r.SampleRate() == s.rate {
+		s.resampler = nil
+		return
+	}
+	s.resampler = resample.NewStream(s.r.SampleRate(), s.rate, 2)
+}
+
+func (s *Stream) rescale(frame int) int {
+	return min(int(int64(frame)*int64(s.rate)/int64(s.r.SampleRate())), s.length)
+}
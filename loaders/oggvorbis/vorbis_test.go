@@ -1,6 +1,10 @@
 package oggvorbis_test
 
 import (
+	"bytes"
+	"io"
+	"math"
+	"os"
 	"testing"
 
 	"github.com/Lundis/go-gameaudio/loaders/oggvorbis"
@@ -38,3 +42,108 @@ func BenchmarkLoadFile(b *testing.B) {
 		oggvorbis.LoadFile("Boardwalk-Arcade-2.ogg", 44100)
 	}
 }
+
+func TestMetadata(t *testing.T) {
+	meta, err := oggvorbis.ReadMetadata(mustRead(t, "test_tags.ogg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Test Tune" || meta.Album != "Tests" || meta.Artist != "go-gameaudio" {
+		t.Errorf("unexpected tags %+v", meta)
+	}
+	if meta.Loop == nil || *meta.Loop != (oggvorbis.Loop{Start: 1000, End: 3000}) {
+		t.Errorf("unexpected loop %+v", meta.Loop)
+	}
+
+	// the loop is rescaled with the samples
+	data, resampled, err := oggvorbis.LoadFileWithMetadata("test_tags.ogg", meta.SampleRate*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		t.Fatalf("no data")
+	}
+	if resampled.Loop == nil || *resampled.Loop != (oggvorbis.Loop{Start: 2000, End: 6000}) {
+		t.Errorf("unexpected loop after resampling %+v", resampled.Loop)
+	}
+}
+
+func TestNoMetadata(t *testing.T) {
+	meta, err := oggvorbis.ReadMetadata(mustRead(t, "test_stereo.ogg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "" || meta.Loop != nil {
+		t.Errorf("unexpected metadata %+v", meta)
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestStream(t *testing.T) {
+	raw := mustRead(t, "test_stereo.ogg")
+	meta, err := oggvorbis.ReadMetadata(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := oggvorbis.Load(raw, meta.SampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := oggvorbis.NewStream(bytes.NewReader(raw), meta.SampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Length() != len(want)/2 {
+		t.Errorf("the stream should be %d frames long but was %d", len(want)/2, s.Length())
+	}
+
+	var got []float32
+	buf := make([]float32, 1000)
+	for {
+		n, err := s.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("the stream should have decoded %d samples but decoded %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d should be %v but was %v", i, want[i], got[i])
+		}
+	}
+
+	// seeking lands on the same samples
+	if err := s.Seek(1000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(buf[:20]); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if math.Abs(float64(buf[i]-want[2000+i])) > 1e-6 {
+			t.Fatalf("after seeking, sample %d should be %v but was %v", i, want[2000+i], buf[i])
+		}
+	}
+
+	// and resampling keeps the length in step with Load
+	resampled, err := oggvorbis.NewStream(bytes.NewReader(raw), 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := int64(len(want)/2) * 44100 / int64(meta.SampleRate); int64(resampled.Length()) != l {
+		t.Errorf("the resampled stream should be %d frames long but was %d", l, resampled.Length())
+	}
+}
@@ -1,24 +1,28 @@
 package resample
 
-// Stereo resamples stereo interleaved float32 audio from srcRate to dstRate using linear interpolation.
+// Stereo resamples stereo interleaved float32 audio from srcRate to dstRate with QualityHigh.
 func Stereo(src []float32, srcRate, dstRate int) []float32 {
-	if srcRate == dstRate {
+	return Resample(src, 2, srcRate, dstRate, QualityHigh)
+}
+
+// Resample converts interleaved float32 audio with channelCount channels from srcRate to dstRate,
+// with a windowed sinc filter of the given quality. The result has srcFrames*dstRate/srcRate frames,
+// and isn't delayed: the first frame of both is at the same time.
+func Resample(src []float32, channelCount, srcRate, dstRate int, quality Quality) []float32 {
+	if srcRate == dstRate || channelCount <= 0 {
 		return src
 	}
-	srcFrames := len(src) / 2
+	srcFrames := len(src) / channelCount
 	dstFrames := int(int64(srcFrames) * int64(dstRate) / int64(srcRate))
-	dst := make([]float32, dstFrames*2)
-	for i := 0; i < dstFrames; i++ {
-		// position in source frames (fractional)
-		srcPos := float64(i) * float64(srcRate) / float64(dstRate)
-		lo := int(srcPos)
-		hi := lo + 1
-		frac := float32(srcPos - float64(lo))
-		if hi >= srcFrames {
-			hi = srcFrames - 1
-		}
-		dst[i*2] = src[lo*2]*(1-frac) + src[hi*2]*frac
-		dst[i*2+1] = src[lo*2+1]*(1-frac) + src[hi*2+1]*frac
-	}
+	dst := make([]float32, dstFrames*channelCount)
+
+	s := NewStreamWithQuality(srcRate, dstRate, channelCount, quality)
+	src = src[:srcFrames*channelCount]
+	s.Read(dst, func(buf []float32) {
+		// the end of the filter reads silence after the source
+		n := copy(buf, src)
+		clear(buf[n:])
+		src = src[n:]
+	})
 	return dst
 }
@@ -0,0 +1,134 @@
+package resample_test
+
+import (
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/loaders/resample"
+)
+
+// tone returns a mono sine at freq.
+func tone(freq float64, rate, frames int) []float32 {
+	out := make([]float32, frames)
+	for i := range out {
+		out[i] = float32(math.Sin(2 * math.Pi * freq * float64(i) / float64(rate)))
+	}
+	return out
+}
+
+// amplitude measures the amplitude of a sine at freq in mono samples, through a Hann window.
+func amplitude(samples []float32, freq float64, rate int) float64 {
+	w := 2 * math.Pi * freq / float64(rate)
+	var re, im float64
+	for i, v := range samples {
+		hann := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(samples)))
+		re += float64(v) * hann * math.Cos(w*float64(i))
+		im += float64(v) * hann * math.Sin(w*float64(i))
+	}
+	return 4 * math.Hypot(re, im) / float64(len(samples))
+}
+
+func decibels(a float64) float64 {
+	return 20 * math.Log10(a+1e-12)
+}
+
+// qualities are the promises of each quality: the part of the Nyquist frequency of the lower rate
+// that is flat within 0.1 dB, and the attenuation of aliasing into it.
+var qualities = []struct {
+	quality  resample.Quality
+	flat     float64
+	aliasing float64
+}{
+	{resample.QualityLow, 0.5, -50},
+	{resample.QualityMedium, 0.65, -70},
+	{resample.QualityHigh, 0.8, -90},
+}
+
+func TestPassbandRipple(t *testing.T) {
+	for _, q := range qualities {
+		for _, rates := range [][2]int{{48000, 44100}, {8000, 48000}} {
+			src, dst := rates[0], rates[1]
+			nyquist := float64(min(src, dst)) / 2
+			var minGain, maxGain float64
+			for f := 100.0; f < q.flat*nyquist; f += q.flat * nyquist / 10 {
+				out := resample.Resample(tone(f, src, src/2), 1, src, dst, q.quality)
+				gain := decibels(amplitude(out[dst/8:dst*3/8], f, dst))
+				minGain, maxGain = min(minGain, gain), max(maxGain, gain)
+			}
+			if minGain < -0.1 || maxGain > 0.1 {
+				t.Errorf("%v %d -> %d: the gain of the passband should be within 0.1 dB but is %.3f to %.3f dB", q.quality, src, dst, minGain, maxGain)
+			}
+		}
+	}
+}
+
+func TestAliasing(t *testing.T) {
+	for _, q := range qualities {
+		// downsampling folds the tones above the new Nyquist frequency back into the passband
+		src, dst := 48000, 22050
+		nyquist := float64(dst) / 2
+		var worst float64
+		for f := float64(dst) - q.flat*nyquist; f < float64(src)/2; f += 997 {
+			out := resample.Resample(tone(f, src, src/2), 1, src, dst, q.quality)
+			worst = max(worst, amplitude(out[dst/8:dst*3/8], float64(dst)-f, dst))
+		}
+		if decibels(worst) > q.aliasing {
+			t.Errorf("%v: aliasing of downsampling should be below %.0f dB but is %.1f dB", q.quality, q.aliasing, decibels(worst))
+		}
+
+		// upsampling must not leave the mirror images of the passband above the old Nyquist frequency
+		src, dst = 8000, 48000
+		nyquist = float64(src) / 2
+		worst = 0
+		for f := 100.0; f < q.flat*nyquist; f += 331 {
+			out := resample.Resample(tone(f, src, src/2), 1, src, dst, q.quality)
+			worst = max(worst, amplitude(out[dst/8:dst*3/8], float64(src)-f, dst))
+		}
+		if decibels(worst) > q.aliasing {
+			t.Errorf("%v: images of upsampling should be below %.0f dB but are %.1f dB", q.quality, q.aliasing, decibels(worst))
+		}
+	}
+}
+
+func TestResampleChannels(t *testing.T) {
+	// three channels with a different constant each
+	const channelCount = 3
+	src := make([]float32, 1000*channelCount)
+	for i := range src {
+		src[i] = float32(i%channelCount + 1)
+	}
+	out := resample.Resample(src, channelCount, 8000, 44100, resample.QualityMedium)
+	if frames := len(out) / channelCount; frames != 1000*44100/8000 {
+		t.Fatalf("should have %d frames but has %d", 1000*44100/8000, frames)
+	}
+	// away from the edges, where the filter reaches the silence around the source
+	for i := 200 * channelCount; i < len(out)-200*channelCount; i++ {
+		if want := float32(i%channelCount + 1); math.Abs(float64(out[i]-want)) > 1e-4 {
+			t.Fatalf("sample %d of channel %d should be %v but is %v", i/channelCount, i%channelCount, want, out[i])
+		}
+	}
+}
+
+func TestStereo(t *testing.T) {
+	src := []float32{1, 2, 3, 4}
+	if out := resample.Stereo(src, 44100, 44100); &out[0] != &src[0] {
+		t.Error("the same rate should return the source")
+	}
+	out := resample.Stereo(make([]float32, 2*48000), 48000, 44100)
+	if len(out) != 2*44100 {
+		t.Errorf("should have %d frames but has %d", 44100, len(out)/2)
+	}
+}
+
+func TestResampleMemory(t *testing.T) {
+	src := make([]float32, 2*10*44100)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	out := resample.Stereo(src, 44100, 48000)
+	runtime.ReadMemStats(&after)
+	// the result, and a buffer of a few hundred frames, but not a copy of the source
+	if allocated, result := after.TotalAlloc-before.TotalAlloc, uint64(4*len(out)); allocated > result+result/10 {
+		t.Errorf("should have allocated about %d bytes for the result but allocated %d", result, allocated)
+	}
+}
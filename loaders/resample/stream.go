@@ -0,0 +1,286 @@
+package resample
+
+import (
+	"math"
+)
+
+// Quality selects the windowed sinc kernel of a conversion. Higher qualities filter more sharply and
+// attenuate aliasing more, at the cost of more work per frame.
+type Quality int
+
+const (
+	// QualityLow attenuates aliasing by about 55 dB with 4 zero crossings per side, and is flat to 55% of the
+	// Nyquist frequency. Meant for real-time conversions of many voices, e.g. pitched sound effects.
+	QualityLow Quality = iota
+	// QualityMedium attenuates aliasing by about 75 dB with 8 zero crossings per side, and is flat to 70%.
+	QualityMedium
+	// QualityHigh attenuates aliasing by about 90 dB with 16 zero crossings per side, and is flat to 80%.
+	// It is used for loading assets and converting the mix to the device's rate.
+	QualityHigh
+)
+
+func (q Quality) String() string {
+	switch q {
+	case QualityLow:
+		return "low"
+	case QualityMedium:
+		return "medium"
+	case QualityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// sincResolution is the number of table entries per zero crossing. Values in between are interpolated.
+const sincResolution = 128
+
+// kernel is one side of a windowed sinc.
+type kernel struct {
+	// zeroCrossings is the number of zero crossings of the sinc on each side of the kernel
+	zeroCrossings int
+	// rolloff puts the cutoff a little below the Nyquist frequency, so that the transition band doesn't alias
+	rolloff float64
+	table   []float32
+}
+
+// newKernel tabulates a sinc with a Kaiser window. beta trades the width of the transition band for stopband attenuation.
+func newKernel(zeroCrossings int, beta, rolloff float64) *kernel {
+	table := make([]float32, zeroCrossings*sincResolution+2)
+	for i := range table {
+		x := float64(i) / sincResolution
+		if x > float64(zeroCrossings) {
+			break
+		}
+		table[i] = float32(sinc(x) * kaiser(x/float64(zeroCrossings), beta))
+	}
+	return &kernel{zeroCrossings: zeroCrossings, rolloff: rolloff, table: table}
+}
+
+var kernels = [...]*kernel{
+	QualityLow:    newKernel(4, 5, 0.85),
+	QualityMedium: newKernel(8, 7, 0.9),
+	QualityHigh:   newKernel(16, 9, 0.95),
+}
+
+func kernelOf(quality Quality) *kernel {
+	if quality < 0 || int(quality) >= len(kernels) {
+		return kernels[QualityHigh]
+	}
+	return kernels[quality]
+}
+
+// at returns the kernel at x zero crossings from its center, interpolating the table.
+func (k *kernel) at(x float64) float32 {
+	x = math.Abs(x) * sincResolution
+	i := int(x)
+	if i+1 >= len(k.table) {
+		return 0
+	}
+	f := float32(x - float64(i))
+	return k.table[i]*(1-f) + k.table[i+1]*f
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser is the Kaiser window at x in [-1, 1].
+func kaiser(x, beta float64) float64 {
+	return bessel0(beta*math.Sqrt(1-x*x)) / bessel0(beta)
+}
+
+// bessel0 is the zeroth order modified Bessel function of the first kind.
+func bessel0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > sum*1e-12; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+// maxPolyphaseWeights limits the size of the precomputed filters of a conversion,
+// 256 kB. Ratios that need more, like 44100 to 44101, evaluate the kernel for every frame instead.
+const maxPolyphaseWeights = 1 << 16
+
+// Stream converts a continuous stream of interleaved frames from one rate to another with a windowed sinc filter.
+// It keeps the filter state between calls, so it can sit between a mixer and an audio device,
+// or play a sound at another pitch by changing the source rate with SetSrcRate.
+type Stream struct {
+	srcRate      int
+	dstRate      int
+	channelCount int
+	kernel       *kernel
+
+	// scale stretches the kernel when downsampling, so that it cuts off at the destination's Nyquist frequency
+	scale float64
+	// span is the number of source frames on each side of an output frame that contribute to it
+	span int
+
+	// phases are the normalized weights of the 2*span source frames around an output frame,
+	// by frac/phaseStep. They are nil if the kernel is evaluated for every frame.
+	phases    [][]float32
+	phaseStep int
+	// scratch holds the weights of the current frame when there are no phases
+	scratch []float32
+
+	// in buffers source frames. The next output frame is at frame pos + frac/dstRate of it.
+	in   []float32
+	pos  int
+	frac int
+
+	chunk []float32
+}
+
+// NewStream creates a converter from srcRate to dstRate with QualityHigh.
+func NewStream(srcRate, dstRate, channelCount int) *Stream {
+	return NewStreamWithQuality(srcRate, dstRate, channelCount, QualityHigh)
+}
+
+// NewStreamWithQuality creates a converter from srcRate to dstRate with the given quality.
+func NewStreamWithQuality(srcRate, dstRate, channelCount int, quality Quality) *Stream {
+	s := &Stream{
+		dstRate:      dstRate,
+		channelCount: channelCount,
+		kernel:       kernelOf(quality),
+		chunk:        make([]float32, 256*channelCount),
+	}
+	s.setRates(srcRate)
+	// start with silence as the history of the first frame
+	s.in = make([]float32, s.span*channelCount)
+	s.pos = s.span
+	s.buildPhases()
+	return s
+}
+
+func (s *Stream) setRates(srcRate int) {
+	s.srcRate = srcRate
+	s.scale = s.kernel.rolloff
+	if s.dstRate < srcRate {
+		s.scale *= float64(s.dstRate) / float64(srcRate)
+	}
+	s.span = int(math.Ceil(float64(s.kernel.zeroCrossings)/s.scale)) + 1
+}
+
+// SetSrcRate changes the rate of the source frames from now on, e.g. to play them at a higher pitch.
+// Afterwards the kernel is evaluated for every frame instead of precomputed, so changing the rate often stays cheap.
+func (s *Stream) SetSrcRate(srcRate int) {
+	if srcRate == s.srcRate || srcRate <= 0 {
+		return
+	}
+	oldSpan := s.span
+	s.setRates(srcRate)
+	if grow := s.span - oldSpan; grow > 0 {
+		// a wider kernel needs more history than we kept, which was silence at the start anyway
+		s.in = append(make([]float32, grow*s.channelCount, len(s.in)+grow*s.channelCount), s.in...)
+		s.pos += grow
+	}
+	s.phases = nil
+}
+
+// buildPhases precomputes the filter of each phase, which the ratio of the rates makes a small set.
+func (s *Stream) buildPhases() {
+	step := gcd(s.srcRate, s.dstRate)
+	count := s.dstRate / step
+	if count*2*s.span > maxPolyphaseWeights {
+		return
+	}
+	s.phaseStep = step
+	s.phases = make([][]float32, count)
+	weights := make([]float32, count*2*s.span)
+	for p := range s.phases {
+		s.phases[p] = weights[p*2*s.span : (p+1)*2*s.span]
+		s.weights(float64(p*step)/float64(s.dstRate), s.phases[p])
+	}
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// weights computes the normalized weights of the 2*span source frames around an output frame
+// that is offset source frames after the frame pos.
+func (s *Stream) weights(offset float64, w []float32) {
+	var gain float64
+	for i := range w {
+		k := i - s.span + 1
+		w[i] = s.kernel.at((float64(k) - offset) * s.scale)
+		gain += float64(w[i])
+	}
+	// normalize, so that the kernel has unity gain at DC whatever the phase
+	if gain != 0 {
+		g := float32(1 / gain)
+		for i := range w {
+			w[i] *= g
+		}
+	}
+}
+
+// Read fills dst with converted frames. It calls read to get more source frames whenever it needs them.
+func (s *Stream) Read(dst []float32, read func(src []float32)) {
+	channelCount := s.channelCount
+	var w []float32
+	if s.phases == nil {
+		if cap(s.scratch) < 2*s.span {
+			s.scratch = make([]float32, 2*s.span)
+		}
+		w = s.scratch[:2*s.span]
+	}
+	for i := 0; i+channelCount <= len(dst); i += channelCount {
+		for len(s.in)/channelCount <= s.pos+s.span {
+			// drop what was converted before buffering more, so that one long Read doesn't keep its whole source
+			if s.pos-s.span >= len(s.chunk)/channelCount {
+				s.compact()
+			}
+			read(s.chunk)
+			s.in = append(s.in, s.chunk...)
+		}
+		if s.phases != nil {
+			w = s.phases[s.frac/s.phaseStep]
+		} else {
+			s.weights(float64(s.frac)/float64(s.dstRate), w)
+		}
+		s.convertFrame(dst[i:i+channelCount], w)
+
+		s.frac += s.srcRate
+		s.pos += s.frac / s.dstRate
+		s.frac %= s.dstRate
+	}
+	s.compact()
+}
+
+// compact drops the source frames that no future output frame needs.
+func (s *Stream) compact() {
+	if drop := s.pos - s.span; drop > 0 {
+		n := copy(s.in, s.in[drop*s.channelCount:])
+		s.in = s.in[:n]
+		s.pos -= drop
+	}
+}
+
+func (s *Stream) convertFrame(out []float32, w []float32) {
+	clear(out)
+	first := (s.pos - s.span + 1) * s.channelCount
+	if s.channelCount == 2 {
+		var l, r float32
+		in := s.in[first : first+2*len(w)]
+		for i, wi := range w {
+			l += in[2*i] * wi
+			r += in[2*i+1] * wi
+		}
+		out[0], out[1] = l, r
+		return
+	}
+	for i, wi := range w {
+		frame := s.in[first+i*s.channelCount : first+(i+1)*s.channelCount]
+		for c, v := range frame {
+			out[c] += v * wi
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package resample_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/loaders/resample"
+)
+
+// sineSource returns a read function for a stereo sine, with the right channel at half the amplitude.
+func sineSource(freq float64, rate int) func(buf []float32) {
+	n := 0
+	return func(buf []float32) {
+		for i := 0; i+1 < len(buf); i += 2 {
+			v := float32(math.Sin(2 * math.Pi * freq * float64(n) / float64(rate)))
+			buf[i] = v
+			buf[i+1] = v / 2
+			n++
+		}
+	}
+}
+
+func TestStreamSine(t *testing.T) {
+	for _, rates := range [][2]int{{44100, 48000}, {48000, 44100}, {22050, 48000}, {48000, 48000}} {
+		src, dst := rates[0], rates[1]
+		const freq = 1000
+		s := resample.NewStream(src, dst, 2)
+		read := sineSource(freq, src)
+
+		// convert in uneven blocks, like a device would ask for them
+		out := make([]float32, 0, dst*2)
+		for _, frames := range []int{100, 333, 1024, 7, 4096, 10000} {
+			buf := make([]float32, frames*2)
+			s.Read(buf, read)
+			out = append(out, buf...)
+		}
+
+		// compare against the ideal sine, which also shows that the filter doesn't delay the output
+		var maxErr float64
+		skip := dst / 100
+		for i := skip; i < len(out)/2; i++ {
+			want := math.Sin(2 * math.Pi * freq * float64(i) / float64(dst))
+			maxErr = max(maxErr, math.Abs(float64(out[2*i])-want), math.Abs(float64(out[2*i+1])-want/2))
+		}
+		if maxErr > 0.001 {
+			t.Errorf("%d -> %d: the output deviates from the ideal sine by %f", src, dst, maxErr)
+		}
+	}
+}
+
+func TestStreamRemovesAliasing(t *testing.T) {
+	// 20 kHz can't be represented at 22050 Hz and must be filtered out instead of folding back to 2 kHz
+	s := resample.NewStream(48000, 22050, 2)
+	read := sineSource(20000, 48000)
+	buf := make([]float32, 22050*2)
+	s.Read(buf, read)
+	var peak float64
+	for _, v := range buf[1000:] {
+		peak = max(peak, math.Abs(float64(v)))
+	}
+	if peak > 0.001 {
+		t.Errorf("the aliased tone should be below -60 dB but peaks at %f", peak)
+	}
+}
+
+func TestStreamPitch(t *testing.T) {
+	// doubling the source rate plays the sine an octave higher
+	const rate = 48000
+	s := resample.NewStreamWithQuality(rate, rate, 2, resample.QualityLow)
+	read := sineSource(500, rate)
+	buf := make([]float32, 2*rate/4)
+	s.Read(buf, read)
+	s.SetSrcRate(2 * rate)
+	out := make([]float32, 2*rate/2)
+	s.Read(out, read)
+
+	left := make([]float32, len(out)/2)
+	for i := range left {
+		left[i] = out[2*i]
+	}
+	steady := left[rate/10:]
+	if a := amplitude(steady, 1000, rate); a < 0.95 || a > 1.05 {
+		t.Errorf("the sine should be at 1000 Hz with amplitude 1 but has %f", a)
+	}
+	if a := amplitude(steady, 500, rate); a > 0.01 {
+		t.Errorf("no sine should be left at 500 Hz but has amplitude %f", a)
+	}
+}
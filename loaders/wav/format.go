@@ -0,0 +1,187 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+const (
+	formatPCM        = 1
+	formatIEEEFloat  = 3
+	formatExtensible = 0xFFFE
+)
+
+// Resampling from absurd rates would blow up corrupt files to gigabytes.
+const (
+	minSampleRate = 1000
+	maxSampleRate = 768000
+)
+
+// subformatSuffix is what follows the format code in the subformat GUID of WAVE_FORMAT_EXTENSIBLE.
+var subformatSuffix = []byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
+// speaker positions of the channel mask
+const (
+	speakerFrontLeft          = 0x1
+	speakerFrontRight         = 0x2
+	speakerFrontCenter        = 0x4
+	speakerLowFrequency       = 0x8
+	speakerBackLeft           = 0x10
+	speakerBackRight          = 0x20
+	speakerFrontLeftOfCenter  = 0x40
+	speakerFrontRightOfCenter = 0x80
+	speakerBackCenter         = 0x100
+	speakerSideLeft           = 0x200
+	speakerSideRight          = 0x400
+)
+
+// defaultChannelMasks are the speaker layouts assumed for files that don't specify one.
+var defaultChannelMasks = map[int]uint32{
+	1: speakerFrontCenter,
+	2: speakerFrontLeft | speakerFrontRight,
+	3: speakerFrontLeft | speakerFrontRight | speakerFrontCenter,
+	4: speakerFrontLeft | speakerFrontRight | speakerBackLeft | speakerBackRight,
+	5: speakerFrontLeft | speakerFrontRight | speakerFrontCenter | speakerBackLeft | speakerBackRight,
+	6: speakerFrontLeft | speakerFrontRight | speakerFrontCenter | speakerLowFrequency | speakerBackLeft | speakerBackRight,
+	8: speakerFrontLeft | speakerFrontRight | speakerFrontCenter | speakerLowFrequency | speakerBackLeft | speakerBackRight | speakerSideLeft | speakerSideRight,
+}
+
+// format is the content of the 'fmt ' chunk.
+type format struct {
+	code          int
+	channelCount  int
+	sampleRate    int
+	blockAlign    int
+	bitsPerSample int
+	channelMask   uint32
+}
+
+func parseFormat(buf []byte) (format, error) {
+	if len(buf) < 16 {
+		return format{}, fmt.Errorf("%w: 'fmt ' chunk is too short", ErrInvalidHeader)
+	}
+	f := format{
+		code:          int(binary.LittleEndian.Uint16(buf[0:])),
+		channelCount:  int(binary.LittleEndian.Uint16(buf[2:])),
+		sampleRate:    int(binary.LittleEndian.Uint32(buf[4:])),
+		blockAlign:    int(binary.LittleEndian.Uint16(buf[12:])),
+		bitsPerSample: int(binary.LittleEndian.Uint16(buf[14:])),
+	}
+	if f.code == formatExtensible {
+		if len(buf) < 40 {
+			return format{}, fmt.Errorf("%w: WAVE_FORMAT_EXTENSIBLE 'fmt ' chunk is too short", ErrInvalidHeader)
+		}
+		f.channelMask = binary.LittleEndian.Uint32(buf[20:])
+		guid := buf[24:40]
+		if !bytes.Equal(guid[2:], subformatSuffix) {
+			return format{}, fmt.Errorf("%w: unknown WAVE_FORMAT_EXTENSIBLE subformat %x", ErrUnsupportedFormat, guid)
+		}
+		f.code = int(binary.LittleEndian.Uint16(guid))
+	}
+
+	if f.channelCount == 0 {
+		return format{}, fmt.Errorf("%w: number of channels must not be 0", ErrInvalidHeader)
+	}
+	if f.sampleRate < minSampleRate || f.sampleRate > maxSampleRate {
+		return format{}, fmt.Errorf("%w: sample rate must be between %d and %d but was %d", ErrUnsupportedFormat, minSampleRate, maxSampleRate, f.sampleRate)
+	}
+	switch f.code {
+	case formatPCM:
+		switch f.bitsPerSample {
+		case 8, 16, 24, 32:
+		default:
+			return format{}, fmt.Errorf("%w: bits per sample of PCM must be 8, 16, 24 or 32 but was %d", ErrUnsupportedFormat, f.bitsPerSample)
+		}
+	case formatIEEEFloat:
+		switch f.bitsPerSample {
+		case 32, 64:
+		default:
+			return format{}, fmt.Errorf("%w: bits per sample of IEEE float must be 32 or 64 but was %d", ErrUnsupportedFormat, f.bitsPerSample)
+		}
+	default:
+		return format{}, fmt.Errorf("%w: format must be linear PCM or IEEE float but was %#x", ErrUnsupportedFormat, f.code)
+	}
+	if f.blockAlign < f.channelCount*f.bitsPerSample/8 {
+		return format{}, fmt.Errorf("%w: block align %d is too small for %d channels of %d bits", ErrInvalidHeader, f.blockAlign, f.channelCount, f.bitsPerSample)
+	}
+	if f.channelMask == 0 {
+		f.channelMask = defaultChannelMasks[f.channelCount]
+	}
+	return f, nil
+}
+
+// decode converts the frames in data to interleaved stereo float32 samples.
+func (f format) decode(data []byte) []float32 {
+	frames := len(data) / f.blockAlign
+	sampleSize := f.bitsPerSample / 8
+	gains := f.stereoGains()
+	out := make([]float32, frames*2)
+	for i := 0; i < frames; i++ {
+		frame := data[i*f.blockAlign:]
+		var l, r float32
+		for c := 0; c < f.channelCount; c++ {
+			v := f.sample(frame[c*sampleSize:])
+			l += v * gains[c][0]
+			r += v * gains[c][1]
+		}
+		out[2*i] = l
+		out[2*i+1] = r
+	}
+	return out
+}
+
+// sample decodes a single sample at the start of b.
+func (f format) sample(b []byte) float32 {
+	switch {
+	case f.code == formatIEEEFloat && f.bitsPerSample == 32:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	case f.code == formatIEEEFloat:
+		return float32(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	case f.bitsPerSample == 8:
+		// 8 bit PCM is unsigned
+		return float32(int(b[0])-128) / (1 << 7)
+	case f.bitsPerSample == 16:
+		return float32(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case f.bitsPerSample == 24:
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float32(v) / (1 << 23)
+	default:
+		return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+}
+
+// stereoGains returns how much each channel contributes to the left and the right output.
+// Mono is played on both sides; surround channels are folded down, and the LFE channel is dropped.
+func (f format) stereoGains() [][2]float32 {
+	const half = 0.7071 // -3 dB
+	gains := make([][2]float32, f.channelCount)
+	if f.channelCount == 1 {
+		gains[0] = [2]float32{1, 1}
+		return gains
+	}
+	mask := f.channelMask
+	for c := range gains {
+		// the channels are in the order of the bits of the mask
+		speaker := mask & -mask
+		mask &^= speaker
+		switch speaker {
+		case speakerFrontLeft:
+			gains[c] = [2]float32{1, 0}
+		case speakerFrontRight:
+			gains[c] = [2]float32{0, 1}
+		case speakerFrontCenter, speakerBackCenter:
+			gains[c] = [2]float32{half, half}
+		case speakerLowFrequency:
+		case speakerBackLeft, speakerSideLeft, speakerFrontLeftOfCenter:
+			gains[c] = [2]float32{half, 0}
+		case speakerBackRight, speakerSideRight, speakerFrontRightOfCenter:
+			gains[c] = [2]float32{0, half}
+		default:
+			// channels without a known position are spread evenly
+			gains[c] = [2]float32{half, half}
+		}
+	}
+	return gains
+}
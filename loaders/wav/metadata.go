@@ -0,0 +1,182 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/Lundis/go-gameaudio/loaders/resample"
+)
+
+// Metadata is what sound editors store next to the audio of a WAV file.
+// All positions are in frames at the sample rate that the file was loaded at.
+type Metadata struct {
+	// SampleRate is the rate of the file before it was resampled.
+	SampleRate int
+
+	// Loops are the loop regions of the 'smpl' chunk.
+	Loops []Loop
+
+	// Markers are the cue points of the 'cue ' chunk, named by the labels of the 'LIST' chunk.
+	Markers []Marker
+}
+
+// Loop is a region that is meant to be repeated.
+type Loop struct {
+	// Start is the first frame of the loop, End is the frame after the last one.
+	Start int
+	End   int
+
+	// PlayCount is how often the loop is played, 0 means forever.
+	PlayCount int
+}
+
+// Marker is a named position in the sound.
+type Marker struct {
+	Name     string
+	Position int
+
+	// Length is the length of the region that starts at the marker, 0 for a plain marker.
+	Length int
+}
+
+// LoadWavFileWithMetadata is like LoadWavWithMetadata but reads the file at path.
+func LoadWavFileWithMetadata(path string, wantedSampleRate int) ([]float32, Metadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return LoadWavWithMetadata(data, wantedSampleRate)
+}
+
+// LoadWavWithMetadata is like LoadWav, but also returns the loop points and markers of the file,
+// rescaled to wantedSampleRate.
+// Malformed metadata is skipped, so it never keeps the audio from loading.
+func LoadWavWithMetadata(rawData []byte, wantedSampleRate int) ([]float32, Metadata, error) {
+	chunks, err := readChunks(rawData)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	f, data, err := findAudio(chunks)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	samples := resample.Stereo(f.decode(data), f.sampleRate, wantedSampleRate)
+	frames := len(samples) / 2
+
+	meta := Metadata{SampleRate: f.sampleRate}
+	// cue points and labels are joined by the id of the cue point
+	labels := map[uint32]string{}
+	lengths := map[uint32]int{}
+	var cues []cuePoint
+	for _, c := range chunks {
+		switch c.id {
+		case "smpl":
+			meta.Loops = append(meta.Loops, parseLoops(c.body)...)
+		case "cue ":
+			cues = append(cues, parseCuePoints(c.body)...)
+		case "LIST":
+			parseLabels(c.body, labels, lengths)
+		}
+	}
+
+	rescale := func(frame int) int {
+		return min(int(int64(frame)*int64(wantedSampleRate)/int64(f.sampleRate)), frames)
+	}
+	for i := range meta.Loops {
+		l := &meta.Loops[i]
+		l.Start = rescale(l.Start)
+		l.End = rescale(l.End)
+	}
+	for _, cue := range cues {
+		name, ok := labels[cue.id]
+		if !ok {
+			name = fmt.Sprintf("cue %d", cue.id)
+		}
+		start := rescale(cue.position)
+		meta.Markers = append(meta.Markers, Marker{
+			Name:     name,
+			Position: start,
+			Length:   rescale(cue.position+lengths[cue.id]) - start,
+		})
+	}
+	return samples, meta, nil
+}
+
+// parseLoops reads the loops of a 'smpl' chunk.
+func parseLoops(buf []byte) []Loop {
+	const headerSize = 36
+	const loopSize = 24
+	if len(buf) < headerSize {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint32(buf[28:]))
+	count = min(count, (len(buf)-headerSize)/loopSize)
+	loops := make([]Loop, 0, count)
+	for i := 0; i < count; i++ {
+		l := buf[headerSize+i*loopSize:]
+		start := binary.LittleEndian.Uint32(l[8:])
+		end := binary.LittleEndian.Uint32(l[12:])
+		if end < start {
+			continue
+		}
+		loops = append(loops, Loop{
+			Start: int(start),
+			// the end in the file is the last frame of the loop
+			End:       int(end) + 1,
+			PlayCount: int(binary.LittleEndian.Uint32(l[20:])),
+		})
+	}
+	return loops
+}
+
+type cuePoint struct {
+	id       uint32
+	position int
+}
+
+// parseCuePoints reads the cue points of a 'cue ' chunk.
+func parseCuePoints(buf []byte) []cuePoint {
+	const pointSize = 24
+	if len(buf) < 4 {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint32(buf))
+	count = min(count, (len(buf)-4)/pointSize)
+	cues := make([]cuePoint, count)
+	for i := range cues {
+		p := buf[4+i*pointSize:]
+		cues[i] = cuePoint{
+			id: binary.LittleEndian.Uint32(p),
+			// the sample offset is the position in the 'data' chunk
+			position: int(binary.LittleEndian.Uint32(p[20:])),
+		}
+	}
+	return cues
+}
+
+// parseLabels reads the names and region lengths of the cue points from a 'LIST' chunk of type 'adtl'.
+func parseLabels(buf []byte, labels map[uint32]string, lengths map[uint32]int) {
+	if len(buf) < 4 || string(buf[:4]) != "adtl" {
+		return
+	}
+	// a truncated sub-chunk only loses the labels after it
+	chunks, _ := splitChunks(buf[4:], "")
+	for _, c := range chunks {
+		switch c.id {
+		case "labl":
+			if len(c.body) < 4 {
+				continue
+			}
+			id := binary.LittleEndian.Uint32(c.body)
+			text, _, _ := bytes.Cut(c.body[4:], []byte{0})
+			labels[id] = string(text)
+		case "ltxt":
+			if len(c.body) < 8 {
+				continue
+			}
+			lengths[binary.LittleEndian.Uint32(c.body)] = int(binary.LittleEndian.Uint32(c.body[4:]))
+		}
+	}
+}
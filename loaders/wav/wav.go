@@ -17,6 +17,8 @@ package wav
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
 
@@ -31,62 +33,98 @@ func LoadWavFile(path string, wantedSampleRate int) ([]float32, error) {
 	return LoadWav(data, wantedSampleRate)
 }
 
+var (
+	// ErrTruncated is returned when the file ends in the middle of the header or the audio data.
+	ErrTruncated = errors.New("wav: file is truncated")
+	// ErrInvalidHeader is returned when the file isn't a well-formed WAV file.
+	ErrInvalidHeader = errors.New("wav: invalid header")
+	// ErrUnsupportedFormat is returned for valid WAV files in a format that can't be decoded, like compressed audio.
+	ErrUnsupportedFormat = errors.New("wav: unsupported format")
+)
+
+// LoadWav decodes a WAV file to interleaved stereo at wantedSampleRate.
+// It supports 8 bit unsigned and 16, 24 and 32 bit signed PCM, 32 and 64 bit IEEE float, and WAVE_FORMAT_EXTENSIBLE.
+// Mono is played on both sides, and surround layouts are mixed down to stereo.
+//
+// Corrupt files never cause a panic; the returned error wraps ErrTruncated, ErrInvalidHeader or ErrUnsupportedFormat.
 func LoadWav(rawData []byte, wantedSampleRate int) ([]float32, error) {
+	chunks, err := readChunks(rawData)
+	if err != nil {
+		return nil, err
+	}
+	f, data, err := findAudio(chunks)
+	if err != nil {
+		return nil, err
+	}
+	return resample.Stereo(f.decode(data), f.sampleRate, wantedSampleRate), nil
+}
+
+// chunk is a RIFF chunk, without the padding byte.
+type chunk struct {
+	id   string
+	body []byte
+}
+
+// readChunks splits the file into its chunks.
+// A truncated chunk after the audio data only ends the list, so that damaged metadata doesn't lose the audio.
+func readChunks(rawData []byte) ([]chunk, error) {
+	if len(rawData) < 12 {
+		return nil, fmt.Errorf("%w: %d bytes are too short for a RIFF header", ErrTruncated, len(rawData))
+	}
 	if !bytes.Equal(rawData[0:4], []byte("RIFF")) {
-		return nil, fmt.Errorf("wav: invalid header: 'RIFF' not found")
+		return nil, fmt.Errorf("%w: 'RIFF' not found", ErrInvalidHeader)
 	}
 	if !bytes.Equal(rawData[8:12], []byte("WAVE")) {
-		return nil, fmt.Errorf("wav: invalid header: 'WAVE' not found")
+		return nil, fmt.Errorf("%w: 'WAVE' not found", ErrInvalidHeader)
 	}
+	return splitChunks(rawData[12:], "data")
+}
 
-	var sampleRate int
-	// Read chunks
-	headerSize := 12
-	for {
-		buf := rawData[headerSize : headerSize+8]
-		headerSize += 8
-		size := int(buf[4]) | int(buf[5])<<8 | int(buf[6])<<16 | int(buf[7])<<24
-		switch {
-		case bytes.Equal(buf[0:4], []byte("fmt ")):
-			// Size of 'fmt' header is usually 16, but can be more than 16.
-			if size < 16 {
-				return nil, fmt.Errorf("wav: invalid header: maybe non-PCM file?")
-			}
-			buf := rawData[headerSize : headerSize+size]
-			format := int(buf[0]) | int(buf[1])<<8
-			if format != 1 {
-				return nil, fmt.Errorf("wav: format must be linear PCM")
-			}
-			channelCount := int(buf[2]) | int(buf[3])<<8
-			switch channelCount {
-			case 1:
-				return nil, fmt.Errorf("WAV must be stereo")
-			case 2:
-				// OK
-			default:
-				return nil, fmt.Errorf("wav: number of channels must be 2 but was %d", channelCount)
+// splitChunks splits buf into chunks. Every iteration moves forward by at least the chunk header, so it ends.
+// If a chunk is truncated, it is an error unless a chunk with the id last was already read.
+// The chunks before the error are returned either way.
+func splitChunks(buf []byte, last string) ([]chunk, error) {
+	var chunks []chunk
+	haveLast := false
+	pos := 0
+	for len(buf)-pos >= 8 {
+		id := string(buf[pos : pos+4])
+		size := int64(binary.LittleEndian.Uint32(buf[pos+4:]))
+		pos += 8
+		if size > int64(len(buf)-pos) {
+			if haveLast {
+				break
 			}
-			bitsPerSample := int(buf[14]) | int(buf[15])<<8
-			if bitsPerSample != 16 {
-				return nil, fmt.Errorf("wav: bits per sample must be 16 but was %d", bitsPerSample)
-			}
-			sampleRate = int(buf[4]) | int(buf[5])<<8 | int(buf[6])<<16 | int(buf[7])<<24
-			headerSize += size
-		case bytes.Equal(buf[0:4], []byte("data")):
-			samples := convertInt16ToFloat32(rawData[headerSize : headerSize+size])
-			return resample.Stereo(samples, sampleRate, wantedSampleRate), nil
-		default:
-			headerSize += size
+			return chunks, fmt.Errorf("%w: the %q chunk needs %d bytes but only %d are left", ErrTruncated, id, size, len(buf)-pos)
 		}
+		chunks = append(chunks, chunk{id: id, body: buf[pos : pos+int(size)]})
+		haveLast = haveLast || id == last
+		// chunks are padded to an even size
+		pos += int(min(size+size&1, int64(len(buf)-pos)))
 	}
+	return chunks, nil
 }
 
-func convertInt16ToFloat32(i16Buf []byte) []float32 {
-	f32 := make([]float32, len(i16Buf)/2)
-	for i := 0; i < len(i16Buf); i += 2 {
-		vi16l := i16Buf[i]
-		vi16h := i16Buf[i+1]
-		f32[i/2] = float32(int16(vi16l)|int16(vi16h)<<8) / (1 << 15)
+// findAudio returns the format and the content of the 'data' chunk.
+func findAudio(chunks []chunk) (format, []byte, error) {
+	var f *format
+	for _, c := range chunks {
+		switch c.id {
+		case "fmt ":
+			parsed, err := parseFormat(c.body)
+			if err != nil {
+				return format{}, nil, err
+			}
+			f = &parsed
+		case "data":
+			if f == nil {
+				return format{}, nil, fmt.Errorf("%w: 'data' before 'fmt '", ErrInvalidHeader)
+			}
+			return *f, c.body, nil
+		}
+	}
+	if f == nil {
+		return format{}, nil, fmt.Errorf("%w: 'fmt ' not found", ErrTruncated)
 	}
-	return f32
+	return format{}, nil, fmt.Errorf("%w: 'data' not found", ErrTruncated)
 }
@@ -1,15 +1,25 @@
 package wav_test
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
 	"testing"
 
 	"github.com/Lundis/go-gameaudio/loaders/wav"
 )
 
 func TestLoadMono(t *testing.T) {
-	_, err := wav.LoadWavFile("test_mono.wav", 44100)
-	if err == nil {
-		t.Fatalf("should not load mono tracks without error")
+	data, err := wav.LoadWavFile("test_mono.wav", 44100)
+	if err != nil {
+		t.Fatalf("error loading mono wav: %s", err.Error())
+	}
+	for i := 0; i < len(data); i += 2 {
+		if data[i] != data[i+1] {
+			t.Fatalf("mono should be played on both sides, but frame %d is %v, %v", i/2, data[i], data[i+1])
+		}
 	}
 }
 
@@ -31,8 +41,240 @@ func TestLoad8khz(t *testing.T) {
 }
 
 func TestLoad8bit(t *testing.T) {
-	_, err := wav.LoadWavFile("test_8bit.wav", 44100)
-	if err == nil {
-		t.Fatalf("should not load non-16bit PCM tracks without error")
+	data, err := wav.LoadWavFile("test_8bit.wav", 44100)
+	if err != nil {
+		t.Fatalf("error loading 8 bit wav: %s", err.Error())
+	}
+	if len(data) == 0 {
+		t.Fatalf("no data")
+	}
+}
+
+// makeWav builds a WAV file with a 'fmt ' chunk of the given content.
+func makeWav(fmtChunk []byte, data []byte) []byte {
+	var b []byte
+	chunk := func(id string, content []byte) {
+		b = append(b, id...)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(content)))
+		b = append(b, content...)
+	}
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, "WAVE"...)
+	chunk("fmt ", fmtChunk)
+	chunk("data", data)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b
+}
+
+func makeFmt(code, channels, rate, bits int) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint16(b, uint16(code))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate*channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits))
+	return b
+}
+
+func makeExtensibleFmt(code, channels, rate, bits int, mask uint32) []byte {
+	b := makeFmt(0xFFFE, channels, rate, bits)
+	b = binary.LittleEndian.AppendUint16(b, 22)
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits))
+	b = binary.LittleEndian.AppendUint32(b, mask)
+	b = binary.LittleEndian.AppendUint16(b, uint16(code))
+	return append(b, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71)
+}
+
+func TestFormats(t *testing.T) {
+	f32 := func(v float32) []byte {
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(v))
+	}
+	f64 := func(v float64) []byte {
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))
+	}
+	cases := []struct {
+		name string
+		fmt  []byte
+		data []byte
+		want []float32
+	}{
+		{"8 bit", makeFmt(1, 2, 44100, 8), []byte{0xC0, 0x40}, []float32{0.5, -0.5}},
+		{"16 bit", makeFmt(1, 2, 44100, 16), []byte{0x00, 0x40, 0x00, 0xC0}, []float32{0.5, -0.5}},
+		{"24 bit", makeFmt(1, 2, 44100, 24), []byte{0x00, 0x00, 0x40, 0x00, 0x00, 0xC0}, []float32{0.5, -0.5}},
+		{"32 bit", makeFmt(1, 2, 44100, 32), []byte{0x00, 0x00, 0x00, 0x40, 0x00, 0x00, 0x00, 0xC0}, []float32{0.5, -0.5}},
+		{"float", makeFmt(3, 2, 44100, 32), append(f32(0.5), f32(-0.5)...), []float32{0.5, -0.5}},
+		{"double", makeFmt(3, 2, 44100, 64), append(f64(0.5), f64(-0.5)...), []float32{0.5, -0.5}},
+		{"mono", makeFmt(1, 1, 44100, 16), []byte{0x00, 0x40}, []float32{0.5, 0.5}},
+		{"extensible float", makeExtensibleFmt(3, 2, 44100, 32, 0x3), append(f32(0.5), f32(-0.5)...), []float32{0.5, -0.5}},
+		// 5.1: FL FR FC LFE BL BR
+		{"5.1", makeFmt(1, 6, 44100, 8), []byte{0xC0, 0x80, 0x80, 0xFF, 0x80, 0x80}, []float32{0.5, 0}},
+		// quad from the channel mask: FL FR BL BR
+		{"extensible quad", makeExtensibleFmt(1, 4, 44100, 8, 0x33), []byte{0x80, 0xC0, 0x80, 0x80}, []float32{0, 0.5}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, err := wav.LoadWav(makeWav(c.fmt, c.data), 44100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != len(c.want) {
+				t.Fatalf("should have decoded %v but got %v", c.want, data)
+			}
+			for i := range c.want {
+				if math.Abs(float64(data[i]-c.want[i])) > 1e-6 {
+					t.Fatalf("should have decoded %v but got %v", c.want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	if _, err := wav.LoadWav(makeWav(makeFmt(2, 2, 44100, 4), []byte{0, 0}), 44100); err == nil {
+		t.Error("should not load ADPCM without error")
+	}
+	ext := makeExtensibleFmt(1, 2, 44100, 16, 0x3)
+	ext[len(ext)-1] = 0
+	if _, err := wav.LoadWav(makeWav(ext, []byte{0, 0, 0, 0}), 44100); err == nil {
+		t.Error("should not load an unknown subformat without error")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	valid := makeWav(makeFmt(1, 2, 44100, 16), []byte{0, 0, 0, 0, 0, 0, 0, 0})
+	cases := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, wav.ErrTruncated},
+		{"not riff", append([]byte("RIFX"), valid[4:]...), wav.ErrInvalidHeader},
+		{"half downloaded", valid[:len(valid)-3], wav.ErrTruncated},
+		{"no data", valid[:36], wav.ErrTruncated},
+		{"data before fmt", append([]byte("RIFF\x00\x00\x00\x00WAVE"), "data\x00\x00\x00\x00"...), wav.ErrInvalidHeader},
+		{"adpcm", makeWav(makeFmt(2, 2, 44100, 4), []byte{0, 0}), wav.ErrUnsupportedFormat},
+		{"zero rate", makeWav(makeFmt(1, 2, 0, 16), []byte{0, 0, 0, 0}), wav.ErrUnsupportedFormat},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := wav.LoadWav(c.data, 44100)
+			if !errors.Is(err, c.want) {
+				t.Errorf("should have returned %v but got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestOddChunkPadding(t *testing.T) {
+	b := []byte("RIFF\x00\x00\x00\x00WAVE")
+	// an odd sized chunk is followed by a padding byte
+	b = append(b, "LIST\x03\x00\x00\x00abc\x00"...)
+	f := makeFmt(1, 1, 44100, 16)
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(f)))
+	b = append(b, f...)
+	b = append(b, "data\x02\x00\x00\x00\x00\x40"...)
+	data, err := wav.LoadWav(b, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || data[0] != 0.5 {
+		t.Errorf("should have decoded [0.5 0.5] but got %v", data)
+	}
+}
+
+func FuzzLoadWav(f *testing.F) {
+	for _, name := range []string{"test_stereo.wav", "test_mono.wav", "test_8bit.wav", "test_8khz.wav"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		// the start of the file is enough to reach every chunk type
+		f.Add(data[:min(len(data), 512)])
+	}
+	f.Add(makeWav(makeFmt(3, 2, 48000, 32), make([]byte, 16)))
+	f.Add(makeWav(makeExtensibleFmt(1, 6, 48000, 24, 0x3F), make([]byte, 36)))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		samples, err := wav.LoadWav(data, 44100)
+		if err != nil {
+			if !errors.Is(err, wav.ErrTruncated) && !errors.Is(err, wav.ErrInvalidHeader) && !errors.Is(err, wav.ErrUnsupportedFormat) {
+				t.Errorf("unexpected error type: %v", err)
+			}
+			return
+		}
+		if len(samples)%2 != 0 {
+			t.Errorf("the samples should be stereo but %d were decoded", len(samples))
+		}
+		withMeta, meta, err := wav.LoadWavWithMetadata(data, 44100)
+		if err != nil || len(withMeta) != len(samples) {
+			t.Errorf("LoadWavWithMetadata should decode the same samples as LoadWav (%v)", err)
+		}
+		for _, l := range meta.Loops {
+			if l.Start > l.End || l.End > len(samples)/2 {
+				t.Errorf("loop %+v is outside of the sound", l)
+			}
+		}
+	})
+}
+
+func appendChunk(b []byte, id string, content []byte) []byte {
+	b = append(b, id...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(content)))
+	b = append(b, content...)
+	if len(content)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestMetadata(t *testing.T) {
+	// 100 frames of mono at 22050 Hz, loaded at 44100 Hz
+	b := makeWav(makeFmt(1, 1, 22050, 8), bytes.Repeat([]byte{0x80}, 100))
+
+	smpl := make([]byte, 36)
+	binary.LittleEndian.PutUint32(smpl[28:], 1)
+	loop := make([]byte, 24)
+	binary.LittleEndian.PutUint32(loop[8:], 10)
+	binary.LittleEndian.PutUint32(loop[12:], 59)
+	b = appendChunk(b, "smpl", append(smpl, loop...))
+
+	cue := binary.LittleEndian.AppendUint32(nil, 2)
+	for _, p := range []struct{ id, pos uint32 }{{1, 20}, {2, 40}} {
+		point := make([]byte, 24)
+		binary.LittleEndian.PutUint32(point, p.id)
+		binary.LittleEndian.PutUint32(point[4:], p.pos)
+		copy(point[8:], "data")
+		binary.LittleEndian.PutUint32(point[20:], p.pos)
+		cue = append(cue, point...)
+	}
+	b = appendChunk(b, "cue ", cue)
+
+	adtl := []byte("adtl")
+	adtl = appendChunk(adtl, "labl", append(binary.LittleEndian.AppendUint32(nil, 1), "hit\x00"...))
+	ltxt := binary.LittleEndian.AppendUint32(nil, 2)
+	ltxt = binary.LittleEndian.AppendUint32(ltxt, 5)
+	adtl = appendChunk(adtl, "ltxt", append(ltxt, make([]byte, 12)...))
+	b = appendChunk(b, "LIST", adtl)
+	// a truncated chunk at the end doesn't lose the audio or the metadata before it
+	b = append(b, "junk\xff\x00\x00\x00"...)
+
+	data, meta, err := wav.LoadWavWithMetadata(b, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 400 {
+		t.Errorf("should have decoded 200 stereo frames but got %d samples", len(data))
+	}
+	if meta.SampleRate != 22050 {
+		t.Errorf("the original sample rate should be 22050 but was %d", meta.SampleRate)
+	}
+	if len(meta.Loops) != 1 || meta.Loops[0] != (wav.Loop{Start: 20, End: 120}) {
+		t.Errorf("unexpected loops %+v", meta.Loops)
+	}
+	want := []wav.Marker{{Name: "hit", Position: 40}, {Name: "cue 2", Position: 80, Length: 10}}
+	if len(meta.Markers) != len(want) || meta.Markers[0] != want[0] || meta.Markers[1] != want[1] {
+		t.Errorf("should have read the markers %+v but got %+v", want, meta.Markers)
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sync"
 
+	"github.com/Lundis/go-gameaudio/loaders/oggvorbis"
 	"golang.org/x/tools/godoc/vfs"
 )
 
@@ -33,3 +34,16 @@ func loadRegistry(fs vfs.Opener, path string) (registry []*PlayList, err error)
 	}
 	return
 }
+
+// fillFromMetadata fills in the fields that playlist.json leaves empty from the comments of the file.
+func (t *trackCommon) fillFromMetadata(meta oggvorbis.Metadata) {
+	if t.Name == "" {
+		t.Name = meta.Title
+	}
+	if t.Album == "" {
+		t.Album = meta.Album
+	}
+	if t.Author == "" {
+		t.Author = meta.Artist
+	}
+}
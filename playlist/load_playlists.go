@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/Lundis/go-gameaudio/audio"
+	"github.com/Lundis/go-gameaudio/loaders"
+	"github.com/Lundis/go-gameaudio/loaders/loudness"
 	"github.com/Lundis/go-gameaudio/loaders/oggvorbis"
 	"golang.org/x/tools/godoc/vfs"
 )
@@ -22,6 +24,9 @@ func LoadFolder(folder string) error {
 
 // Load loads playlists from a virtual filesystem.
 // At the root of the filesystem there must be a "playlist.json" file, which references any files to be loaded
+// in any format that loaders.NewStream recognizes.
+// Tracks with a "LoudnessTarget" are brought to that loudness. It is read from their ReplayGain tags, or else measured,
+// which decodes the whole track once; set a loaders cache to keep the measurement for the next launch.
 func Load(fileSystem vfs.Opener) error {
 	lock.Lock()
 	defer lock.Unlock()
@@ -38,7 +43,8 @@ func Load(fileSystem vfs.Opener) error {
 	type loadResult struct {
 		plIdx int
 		track *Track
-		sound *audio.Sound
+		sound *audio.StreamingSound
+		meta  oggvorbis.Metadata
 		err   error
 	}
 
@@ -60,17 +66,32 @@ func Load(fileSystem vfs.Opener) error {
 					resultCh <- loadResult{plIdx: plIdx, err: err}
 					return
 				}
-				mem, err := oggvorbis.Load(raw, audio.SampleRate())
+				// formats that can be streamed only keep the compressed file in memory, and are decoded while they play
+				stream, err := loaders.NewStream(raw, audio.SampleRate())
 				if err != nil {
 					log.Println("Failed to decompress music", track.Path, ":", err.Error())
 					resultCh <- loadResult{plIdx: plIdx, err: err}
 					return
 				}
-				resultCh <- loadResult{
+				result := loadResult{
 					plIdx: plIdx,
 					track: track,
-					sound: audio.NewSound(mem, track.Volume, audio.ChannelIdMusic),
 				}
+				if m, ok := stream.(interface{ Metadata() oggvorbis.Metadata }); ok {
+					result.meta = m.Metadata()
+				}
+				if track.LoudnessTarget != 0 {
+					// only this goroutine touches the track until it sends the result
+					track.loudness, err = measureLoudness(raw, result.meta, audio.SampleRate())
+					track.measured = err == nil
+					if err != nil {
+						log.Println("Failed to measure the loudness of music", track.Path, ":", err.Error())
+						resultCh <- loadResult{plIdx: plIdx, err: err}
+						return
+					}
+				}
+				result.sound = audio.NewStreamingSound(stream, track.volume(), audio.ChannelIdMusic)
+				resultCh <- result
 			}(i, track)
 		}
 	}
@@ -86,6 +107,8 @@ func Load(fileSystem vfs.Opener) error {
 			failedPlaylists[result.plIdx] = true
 		} else {
 			result.track.sound = result.sound
+			result.track.fillFromMetadata(result.meta)
+			result.track.loop = result.meta.Loop
 		}
 	}
 
@@ -101,3 +124,12 @@ func Load(fileSystem vfs.Opener) error {
 		time.Since(start).Seconds())
 	return nil
 }
+
+// measureLoudness reads the loudness of a track from its ReplayGain tags, or else measures it with loaders.Loudness,
+// which decodes the whole track unless the loaders cache has the measurement.
+func measureLoudness(raw []byte, meta oggvorbis.Metadata, sampleRate int) (loudness.Loudness, error) {
+	if l, ok := loudness.ReplayGain(meta.Comments); ok {
+		return l, nil
+	}
+	return loaders.Loudness(raw, sampleRate)
+}
@@ -7,6 +7,9 @@ import (
 	"syscall/js"
 	"time"
 
+	"github.com/Lundis/go-gameaudio/loaders"
+	"github.com/Lundis/go-gameaudio/loaders/loudness"
+	"github.com/Lundis/go-gameaudio/loaders/oggvorbis"
 	"golang.org/x/tools/godoc/vfs"
 )
 
@@ -18,7 +21,10 @@ func LoadFolder(folder string) error {
 }
 
 // Load loads playlists from a virtual filesystem.
-// At the root of the filesystem there must be a "playlist.json" file, which references any files to be loaded.
+// At the root of the filesystem there must be a "playlist.json" file, which references any files to be loaded
+// in any format that loaders.Detect recognizes.
+// Tracks with a "LoudnessTarget" are brought to the loudness of their ReplayGain tags, which they need on JS/WASM,
+// although the browser can't raise the volume of a track above 1.
 //
 // On JS/WASM, each track's raw bytes are placed in a Blob and a URL is created via
 // URL.createObjectURL. An HTMLAudioElement is then created for each track so that the
@@ -42,7 +48,27 @@ func Load(fileSystem vfs.Opener) error {
 				failedPlaylists[i] = true
 				continue
 			}
-			if err := initTrackAudioElement(track, raw); err != nil {
+			// the browser decodes the audio, so only the comments are read here; loop tags aren't supported
+			meta, err := oggvorbis.ReadMetadata(raw)
+			if err == nil {
+				track.fillFromMetadata(meta)
+			}
+			if track.LoudnessTarget != 0 {
+				// decoding the whole track in wasm would block the page, so only ReplayGain tags are used here
+				if l, ok := loudness.ReplayGain(meta.Comments); ok {
+					track.loudness = l
+					track.measured = true
+				} else {
+					log.Println("Track", track.Path, "has a LoudnessTarget but no ReplayGain tags, which the browser needs")
+				}
+			}
+			format, err := loaders.Detect(raw)
+			if err != nil {
+				log.Println("Failed to detect the format of track", track.Path, ":", err.Error())
+				failedPlaylists[i] = true
+				continue
+			}
+			if err := initTrackAudioElement(track, raw, format.MIMEType); err != nil {
 				log.Println("Failed to create audio element for track", track.Path, ":", err.Error())
 				failedPlaylists[i] = true
 			}
@@ -61,15 +87,15 @@ func Load(fileSystem vfs.Opener) error {
 	return nil
 }
 
-// initTrackAudioElement stores the raw ogg bytes in a Blob, registers a blob URL,
+// initTrackAudioElement stores the raw bytes in a Blob of the given media type, registers a blob URL,
 // and attaches an HTMLAudioElement to the track.
-func initTrackAudioElement(track *Track, raw []byte) error {
+func initTrackAudioElement(track *Track, raw []byte, mimeType string) error {
 	uint8Array := js.Global().Get("Uint8Array").New(len(raw))
 	js.CopyBytesToJS(uint8Array, raw)
 
 	blob := js.Global().Get("Blob").New(
 		[]any{uint8Array},
-		map[string]any{"type": "audio/ogg"},
+		map[string]any{"type": mimeType},
 	)
 	urlVal := js.Global().Get("URL").Call("createObjectURL", blob)
 	blobURL := urlVal.String()
@@ -0,0 +1,131 @@
+//go:build !js
+
+package playlist
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Lundis/go-gameaudio/audio"
+	"github.com/Lundis/go-gameaudio/audio/audiotest"
+	"github.com/Lundis/go-gameaudio/loaders"
+	"github.com/Lundis/go-gameaudio/loaders/oggvorbis"
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+// driver plays the music of the tests
+var driver *audiotest.Driver
+
+func TestMain(m *testing.M) {
+	d, err := audiotest.InitContext(&audio.NewContextOptions{SampleRate: 44100})
+	if err != nil {
+		panic(err)
+	}
+	driver = d
+	os.Exit(m.Run())
+}
+
+func TestLoad(t *testing.T) {
+	wav, err := os.ReadFile("../loaders/wav/test_stereo.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := mapfs.New(map[string]string{
+		"playlist.json": `[
+			{"Id": "menu", "Tracks": [
+				{"Path": "theme.wav", "Name": "Theme", "Volume": 0.5, "LoudnessTarget": -20},
+				{"Path": "theme.wav", "Name": "Plain", "Volume": 0.5}
+			]},
+			{"Id": "broken", "Tracks": [{"Path": "missing.wav", "Volume": 1}]}
+		]`,
+		"theme.wav": string(wav),
+	})
+	if err := Load(fs); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := playLists["broken"]; ok {
+		t.Error("a playlist with a missing track should not have loaded")
+	}
+	menu, ok := playLists["menu"]
+	if !ok || len(menu.Tracks) != 2 {
+		t.Fatalf("the WAV playlist should have loaded with 2 tracks but got %+v", menu)
+	}
+
+	normalized, plain := menu.Tracks[0], menu.Tracks[1]
+	if normalized.sound == nil || plain.sound == nil {
+		t.Fatal("the tracks should have been streamed through the loaders")
+	}
+	want, err := loaders.Loudness(wav, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !normalized.measured || normalized.Loudness() != want {
+		t.Errorf("the loudness should be %+v but is %+v", want, normalized.Loudness())
+	}
+	if gain := 0.5 * want.Gain(-20); normalized.volume() != gain {
+		t.Errorf("the track should play at %v but plays at %v", gain, normalized.volume())
+	}
+	if plain.measured || plain.volume() != 0.5 {
+		t.Errorf("a track without a LoudnessTarget should play at its Volume but plays at %v", plain.volume())
+	}
+}
+
+func TestLoadTags(t *testing.T) {
+	ogg, err := os.ReadFile("../loaders/oggvorbis/test_tags.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := mapfs.New(map[string]string{
+		"playlist.json": `[
+			{"Id": "level", "Tracks": [{"Path": "tune.ogg", "Volume": 1}]},
+			{"Id": "credits", "Tracks": [
+				{"Path": "tune.ogg", "Name": "Credits", "Author": "Someone else", "Volume": 1},
+				{"Path": "tune.ogg", "Volume": 1}
+			]}
+		]`,
+		"tune.ogg": string(ogg),
+	})
+	if err := Load(fs); err != nil {
+		t.Fatal(err)
+	}
+	level, ok := playLists["level"]
+	if !ok || len(level.Tracks) != 1 {
+		t.Fatalf("the Ogg playlist should have loaded with 1 track but got %+v", level)
+	}
+	track := level.Tracks[0]
+	if track.Name != "Test Tune" || track.Album != "Tests" || track.Author != "go-gameaudio" {
+		t.Errorf("the empty fields should have been filled from the tags but got %+v", track.trackCommon)
+	}
+	credits := playLists["credits"].Tracks[0]
+	if credits.Name != "Credits" || credits.Album != "Tests" || credits.Author != "Someone else" {
+		t.Errorf("only the empty fields should have been filled from the tags but got %+v", credits.trackCommon)
+	}
+	if want := (oggvorbis.Loop{Start: 1000, End: 3000}); track.loop == nil || *track.loop != want {
+		t.Fatalf("the track should loop %+v but loops %+v", want, track.loop)
+	}
+
+	// a single track repeats its loop region instead of all of it
+	Id("level").Play(false)
+	defer func() {
+		playlistStop(currentPlayList)
+		currentPlayList = nil
+	}()
+	buf := make([]float32, 2*256)
+	last := 0
+	for i := 0; i < 100; i++ {
+		// wait for the decoder to get ahead
+		time.Sleep(10 * time.Millisecond)
+		driver.Read(buf)
+		current, _ := trackSeconds(track)
+		pos := int(current*44100 + 0.5)
+		if pos < last {
+			if last > 3000 || pos < 1000 {
+				t.Fatalf("the track should have looped back from 3000 to 1000 but went from %d to %d", last, pos)
+			}
+			return
+		}
+		last = pos
+	}
+	t.Fatalf("the track should have looped back to 1000 but is at %d", last)
+}
@@ -3,6 +3,8 @@ package playlist
 import (
 	"log"
 	"math/rand"
+
+	"github.com/Lundis/go-gameaudio/loaders/loudness"
 )
 
 var playLists map[Id]*PlayList
@@ -22,6 +24,26 @@ type trackCommon struct {
 	Album  string
 	Author string
 	Volume float32
+	// LoudnessTarget is the integrated loudness in LUFS that the track is brought to before Volume applies,
+	// e.g. -16. 0 leaves the track as it is.
+	LoudnessTarget float64
+
+	loudness loudness.Loudness
+	measured bool
+}
+
+// Loudness returns the loudness of the track as it was measured, or read from its ReplayGain tags,
+// before any gain. It is only measured for tracks with a LoudnessTarget.
+func (t *trackCommon) Loudness() loudness.Loudness {
+	return t.loudness
+}
+
+// volume is the Volume of the track, with the gain that brings it to its LoudnessTarget.
+func (t *trackCommon) volume() float32 {
+	if t.LoudnessTarget == 0 || !t.measured {
+		return t.Volume
+	}
+	return t.Volume * t.loudness.Gain(t.LoudnessTarget)
 }
 
 func Pause() {
@@ -41,7 +41,7 @@ func playlistPlay(pl *PlayList) {
 	if !track.audioEl.Get("paused").Bool() {
 		return
 	}
-	track.audioEl.Set("volume", track.Volume*audio.ChannelIdMusic.Volume())
+	track.audioEl.Set("volume", min(track.volume()*audio.ChannelIdMusic.Volume(), 1))
 	if len(pl.Tracks) > 1 {
 		track.audioEl.Set("loop", false)
 		releaseEndedFn(track)
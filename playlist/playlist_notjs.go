@@ -6,12 +6,16 @@ import (
 	"time"
 
 	"github.com/Lundis/go-gameaudio/audio"
+	"github.com/Lundis/go-gameaudio/loaders/oggvorbis"
 )
 
 type Track struct {
 	trackCommon
-	sound        *audio.Sound
-	playingSound *audio.PlayingSound
+	sound        *audio.StreamingSound
+	playingSound *audio.StreamingSound
+
+	// loop is the loop region from the tags of the file, if any
+	loop *oggvorbis.Loop
 }
 
 func pauseMusic() {
@@ -37,6 +41,8 @@ func playlistPlay(pl *PlayList) {
 		if len(pl.Tracks) > 1 {
 			track.playingSound = track.sound.PlayFadeIn(time.Second / 2)
 			track.playingSound.OnEndCallback(pl.PlayNext)
+		} else if track.loop != nil {
+			track.playingSound = track.sound.PlayLoopRegion(track.loop.Start, track.loop.End)
 		} else {
 			track.playingSound = track.sound.PlayLoop(time.Second)
 		}
@@ -9,7 +9,8 @@ import (
 	"time"
 
 	"github.com/Lundis/go-gameaudio/audio"
-	"github.com/Lundis/go-gameaudio/loaders/wav"
+	"github.com/Lundis/go-gameaudio/loaders"
+	"github.com/Lundis/go-gameaudio/loaders/loudness"
 	"golang.org/x/tools/godoc/vfs"
 )
 
@@ -24,6 +25,10 @@ func LoadFolder(folder string) error {
 
 // Load loads sound effects from a virtual filesystem.
 // At the root of the filesystem there must be a "sfx.json" file, which references any files to be loaded
+// in any format that loaders.Decode recognizes.
+// The "Format" of an entry chooses how its variations are kept in memory: "float32" (the default), "int16" or "adpcm",
+// see audio.SampleFormat. An entry with a "LoudnessTarget" in LUFS measures each of its variants and brings them to
+// that loudness, see loudness.Loudness.Gain.
 func Load(fileSystem vfs.Opener) error {
 	lock.Lock()
 	defer lock.Unlock()
@@ -46,14 +51,19 @@ func Load(fileSystem vfs.Opener) error {
 					log.Println("Failed to read sound effect from disk", v.Path, ":", err.Error())
 					continue
 				}
-				mem, err = wav.LoadWav(raw, audio.SampleRate())
+				mem, err = loaders.Decode(raw, audio.SampleRate())
 				if err != nil {
 					log.Println("Failed to decompress sound effect", v.Path, ":", err.Error())
 					continue
 				}
 				cachedDiskReads[v.Path] = mem
 			}
-			v.sound = audio.NewSound(mem, e.Volume*v.Volume, audio.ChannelIdSfx)
+			volume := e.Volume * v.Volume
+			if e.LoudnessTarget != 0 {
+				v.loudness = loudness.Measure(mem, 2, audio.SampleRate())
+				volume *= v.loudness.Gain(e.LoudnessTarget)
+			}
+			v.sound = audio.NewSoundWithFormat(mem, volume, audio.ChannelIdSfx, e.Format)
 			e.Variations = append(e.Variations, v)
 		}
 		if len(e.Variations) > 0 {
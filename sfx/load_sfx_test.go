@@ -0,0 +1,87 @@
+package sfx
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/Lundis/go-gameaudio/audio"
+	"github.com/Lundis/go-gameaudio/audio/audiotest"
+	"github.com/Lundis/go-gameaudio/loaders"
+	"github.com/Lundis/go-gameaudio/loaders/loudness"
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+// driver plays the sound effects of the tests
+var driver *audiotest.Driver
+
+func TestMain(m *testing.M) {
+	d, err := audiotest.InitContext(&audio.NewContextOptions{SampleRate: 44100})
+	if err != nil {
+		panic(err)
+	}
+	driver = d
+	os.Exit(m.Run())
+}
+
+func TestLoad(t *testing.T) {
+	ogg, err := os.ReadFile("../loaders/oggvorbis/test_stereo.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := mapfs.New(map[string]string{
+		"sfx.json": `[
+			{"Id": "plain", "Volume": 1, "Variations": [{"Path": "hit.ogg", "Probability": 1, "Volume": 1}]},
+			{"Id": "normalized", "Volume": 1, "LoudnessTarget": -20,
+				"Variations": [{"Path": "hit.ogg", "Probability": 1, "Volume": 0.5}]},
+			{"Id": "compact", "Volume": 1, "Format": "adpcm", "Variations": [{"Path": "hit.ogg", "Probability": 1, "Volume": 1}]}
+		]`,
+		"hit.ogg": string(ogg),
+	})
+	if err := Load(fs); err != nil {
+		t.Fatal(err)
+	}
+	if len(loadedSfx) != 3 {
+		t.Fatalf("should have loaded 3 sound effects but loaded %d", len(loadedSfx))
+	}
+
+	// the Ogg file is decoded through the registry of loaders
+	data, err := loaders.Decode(ogg, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := loadedSfx["compact"].Variations[0].sound; s.Format() != audio.SampleFormatADPCM {
+		t.Errorf("the sound should be kept as adpcm but is %v", s.Format())
+	}
+	if s := loadedSfx["plain"].Variations[0].sound; s.Format() != audio.SampleFormatFloat32 {
+		t.Errorf("the sound should be kept as float32 by default but is %v", s.Format())
+	}
+
+	variant := loadedSfx["normalized"].Variations[0]
+	want := loudness.Measure(data, 2, 44100)
+	if variant.Loudness() != want {
+		t.Errorf("the loudness should be %+v but is %+v", want, variant.Loudness())
+	}
+	if (loadedSfx["plain"].Variations[0].Loudness() != loudness.Loudness{}) {
+		t.Error("sound effects without a LoudnessTarget should not be measured")
+	}
+
+	// the normalized sound plays with the gain and its volume
+	render := func(id Id) []float32 {
+		// long enough for the sound to end before the next one plays
+		buf := make([]float32, len(data)+2)
+		if !id.Play() {
+			t.Fatalf("%s should have played", id)
+		}
+		driver.Read(buf)
+		return buf
+	}
+	plain := render("plain")
+	normalized := render("normalized")
+	gain := 0.5 * want.Gain(-20)
+	for i := range plain {
+		if math.Abs(float64(normalized[i]-plain[i]*gain)) > 1e-6 {
+			t.Fatalf("sample %d should be %v with a gain of %v but is %v", i, plain[i]*gain, gain, normalized[i])
+		}
+	}
+}
@@ -2,6 +2,7 @@ package sfx
 
 import (
 	"github.com/Lundis/go-gameaudio/audio"
+	"github.com/Lundis/go-gameaudio/loaders/loudness"
 	"math/rand/v2"
 	"time"
 )
@@ -14,7 +15,11 @@ type Sfx struct {
 	ThrottlingMs int
 	Variations   []*SfxVariant
 	DebugMode    bool
-	lastPlayed   time.Time
+	Format       audio.SampleFormat
+	// LoudnessTarget is the integrated loudness in LUFS that each variant is brought to before the volumes apply,
+	// e.g. -16. 0 leaves the variants as they are.
+	LoudnessTarget float64
+	lastPlayed     time.Time
 }
 
 type SfxVariant struct {
@@ -23,9 +28,16 @@ type SfxVariant struct {
 	Volume       float32
 	ThrottlingMs int
 	sound        *audio.Sound
+	loudness     loudness.Loudness
 	lastPlayed   time.Time
 }
 
+// Loudness returns the loudness of the file of the variant before any gain.
+// It is only measured for the variants of sound effects with a LoudnessTarget.
+func (e *SfxVariant) Loudness() loudness.Loudness {
+	return e.loudness
+}
+
 func (e *Sfx) play(fadeInMs time.Duration) bool {
 	if len(e.Variations) == 0 {
 		return false